@@ -1,12 +1,20 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/zlrrr/flush-manager/internal/logger"
 	"github.com/zlrrr/flush-manager/internal/manager"
+	"github.com/zlrrr/flush-manager/internal/probe"
+	"github.com/zlrrr/flush-manager/internal/process"
+	"github.com/zlrrr/flush-manager/internal/watcher"
 )
 
 const (
@@ -15,13 +23,236 @@ const (
 )
 
 var (
-	command    = flag.String("command", defaultCommand, "Command to execute")
-	configFile = flag.String("config", defaultConfigFile, "Config file to watch for changes")
-	version    = flag.Bool("version", false, "Print version information")
+	command         = flag.String("command", defaultCommand, "Command to execute")
+	cmdline         = flag.String("cmdline", "", "Full command line to execute, split into command and arguments with shell-like quoting (instead of -command and trailing arguments)")
+	configFile      = flag.String("config", defaultConfigFile, "Config file to watch for changes")
+	requireConfig   = flag.Bool("require-config", false, "Fail at startup if the -config file does not exist, instead of running without reloads")
+	version         = flag.Bool("version", false, "Print version information")
+	logLevel        = flag.String("log-level", "debug", "Minimum level of logged messages: debug, info, warn or error")
+	quiet           = flag.Bool("quiet", false, "Only log errors; same as -log-level=error")
+	verbose         = flag.Bool("verbose", false, "Log everything including debug messages; same as -log-level=debug")
+	logPrefix       = flag.String("log-prefix", "flush-manager", "Name in the [name] prefix of every log line, to tell several instances apart")
+	logPID          = flag.Bool("log-pid", false, "Include the manager PID in the log prefix, as in [flush-manager:12345]")
+	healthAddr      = flag.String("health-addr", "", "Listen address for the /healthz and /ready endpoints (disabled if empty)")
+	lameDuck        = flag.Duration("lame-duck-period", 0, "How long to report not-ready before stopping the child on shutdown")
+	useShell        = flag.Bool("shell", false, "Run the trailing arguments, joined into one string, as a script via -shell-path -c")
+	shellPath       = flag.String("shell-path", "/bin/sh", "Shell used with -shell")
+	usePTY          = flag.Bool("pty", false, "Run the child attached to a pseudo-terminal")
+	resolveCmd      = flag.Bool("resolve-command", false, "Re-resolve the command binary before each (re)start and log upgrades")
+	watchSelf       = flag.Bool("watch-self", false, "Re-exec flush-manager when its own binary is updated on disk")
+	restartWindow   = flag.String("restart-window", "", "Daily HH:MM-HH:MM window, in local time, to which restarts for config changes are deferred; changes made meanwhile are applied with one restart")
+	execMode        = flag.Bool("exec-mode", false, "With -config \"\" and no other watched path, exec the child in place of flush-manager instead of supervising it")
+	watchBinary     = flag.Bool("watch-binary", false, "Restart the child when its binary, resolved through PATH, is updated on disk")
+	rollbackReload  = flag.Bool("rollback-on-failed-reload", false, "Restore the last config the child started healthy with when a reload fails, and start the child with it again")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "How long the child gets to stop gracefully before it is killed")
+	outputWait      = flag.Duration("output-wait-delay", time.Second, "How long the child's output is still forwarded after it exited, in case a process it started holds its stdout or stderr open")
+	postExitDelay   = flag.Duration("post-exit-delay", 0, "How long to wait before exiting after the child exited normally; a signal ends it early")
+	idleTimeout     = flag.Duration("idle-timeout", 0, "Stop the child when no config change occurred for this long, and start it on the next one (disabled if 0)")
+	idleExit        = flag.Bool("idle-exit", false, "Shut flush-manager down on -idle-timeout instead of only stopping the child")
+	httpShutdown    = flag.Duration("http-shutdown-timeout", 5*time.Second, "How long in-flight health server requests may take to finish on shutdown")
+	drainSentinel   = flag.String("drain-sentinel", "", "File that, while present, holds off stopping the child on shutdown or reload")
+	drainCallback   = flag.String("drain-callback-addr", "", "Address (host:port or unix:PATH) where the child can POST /drained once it finished flushing after SIGTERM, to be stopped right away")
+	tlsCert         = flag.String("tls-cert", "", "TLS certificate file for the health server")
+	tlsKey          = flag.String("tls-key", "", "TLS private key file for the health server")
+	tlsClientCA     = flag.String("tls-client-ca", "", "CA file used to require and verify client certificates on the health server")
+	authToken       = flag.String("auth-token", "", "Bearer token required on the health server's endpoints")
+	basicAuth       = flag.String("basic-auth", "", "user:pass required as HTTP basic auth on the health server's endpoints")
+	webhookURL      = flag.String("webhook-url", "", "URL receiving a JSON POST whenever the child is restarted or a config change is applied")
+	webhookSecret   = flag.String("webhook-secret", "", "Secret for the HMAC-SHA256 signature of webhook payloads, sent in the X-Flush-Manager-Signature header")
+	heartbeatFile   = flag.String("heartbeat-file", "", "File touched every -heartbeat-interval while the manager is responsive and the child running, for watchdogs checking its modification time")
+	heartbeatIntvl  = flag.Duration("heartbeat-interval", 10*time.Second, "How often -heartbeat-file is touched")
+	healthNoAuth    = flag.Bool("health-no-auth", false, "Leave /healthz and /ready unauthenticated for probes")
+	childStdin      = flag.String("child-stdin", "", "Source of the child's stdin: a file path (re-opened on every start), inherit or config (the -config file); /dev/null if empty")
+	childStdout     = flag.String("child-stdout", "inherit", "Destination of the child's stdout: a file path (reopened on -reopen-signal), inherit, logger or discard")
+	childStderr     = flag.String("child-stderr", "inherit", "Destination of the child's stderr: a file path (reopened on -reopen-signal), inherit, logger or discard")
+	teeOutput       = flag.Bool("tee-output", false, "Also keep the most recent child stdout and stderr in memory and serve it on /logs")
+	forceKill       = flag.String("force-kill-signal", "SIGKILL", "Signal sent when the child does not stop within -shutdown-timeout")
+	stdoutRate      = flag.Float64("child-stdout-rate-limit", 0, "Max lines per second of child stdout logged with -child-stdout=logger (0 = unlimited)")
+	stderrRate      = flag.Float64("child-stderr-rate-limit", 0, "Max lines per second of child stderr logged with -child-stderr=logger (0 = unlimited)")
+	sigtermAction   = flag.String("sigterm-action", "shutdown", "Reaction to SIGTERM: shutdown, or reload (restart the child and keep running; SIGINT still shuts down)")
+	reopenSignal    = flag.String("reopen-signal", "SIGUSR2", "Signal that makes the manager reopen child output files (SIGHUP, SIGUSR1 or SIGUSR2)")
+	memoryThreshold = flag.Int64("memory-restart-threshold", 0, "Restart the child gracefully when its resident memory exceeds this many bytes (disabled if 0; linux only)")
+	memoryInterval  = flag.Duration("memory-check-interval", 10*time.Second, "How often the child's memory is checked against -memory-restart-threshold")
+	detectOOM       = flag.Bool("detect-oom", false, "When the child is SIGKILLed, check /dev/kmsg to confirm an OOM kill")
+	watcherTest     = flag.Bool("watcher-self-test", false, "Check on startup that file events are delivered, and fall back to polling if not")
+	checksumFile    = flag.String("config-checksum-file", "", "File persisting the config fingerprint across manager restarts; a change found at startup restarts the child once")
+	watchMode       = flag.String("watch-mode", "auto", "How config changes are detected: auto (fsnotify with polling fallback), fsnotify or poll")
+	watchStrats     = flag.String("watch-strategies", "", "Comma-separated change detection strategies to run together, overriding -watch-mode: fsnotify, poll-stat, poll-hash")
+	pollInterval    = flag.Duration("poll-interval", 5*time.Second, "How often the config file is polled in the auto and poll watch modes")
+	changeTrigger   = flag.String("change-trigger", "any", "Which config changes count: any, append (the file grew) or replace (new file, rewrite or truncation)")
+	watchHoldOpen   = flag.Bool("watch-hold-open", false, "Keep the config file open and detect replacement by comparing it with the file at the path")
+	watchMetadata   = flag.Bool("watch-metadata", false, "Also reload when the config file's permissions or ownership change, not just its content")
+	maxConfigSize   = flag.Int64("max-config-size", 0, "Largest config file in bytes whose content is hashed; larger ones are compared by modification time (unlimited if 0)")
+	rejectOversized = flag.Bool("reject-oversized-config", false, "Ignore config changes that leave the file larger than -max-config-size instead of reloading")
+	watchSettle     = flag.Bool("watch-settle", false, "Report a config change only once the file, resolved through symlinks, is readable and unchanged for the debounce period (for ConfigMap updates)")
+	stabilityWindow = flag.Duration("stability-window", 0, "Report a config change only once the file's size and modification time stayed the same for this long (disabled if 0)")
+	dataMarker      = flag.Bool("data-marker", false, "Treat changes to a data entry next to a symlinked config file as a ConfigMap update, like ..data")
+	symlinkFollow   = flag.String("symlink-follow", "grandparent", "How a symlinked config file is followed: grandparent (also watch ConfigMap ..data updates) or poll-target (only react when the symlink target changes)")
+	onChange        = flag.String("on-change", "auto", "Reaction to a config change: restart, command (run -on-change-command only), both, or auto (command if -on-change-command is set, else restart)")
+	onChangeCmd     = flag.String("on-change-command", "", "Command run through /bin/sh -c on each config change, e.g. \"redis-cli CONFIG REWRITE\"")
+	onChangeTimeout = flag.Duration("on-change-timeout", 30*time.Second, "How long -on-change-command may run before it is killed")
+	initCommand     = flag.String("init-command", "", "Command run through /bin/sh -c before every (re)start of the child; it must exit 0 or the start fails")
+	initTimeout     = flag.Duration("init-timeout", time.Minute, "How long -init-command may run before it is killed and the start fails")
+	flushCommand    = flag.String("flush-command", "", "Command run through /bin/sh -c before the child is stopped for a restart, e.g. to flush its state")
+	flushTimeout    = flag.Duration("flush-timeout", 30*time.Second, "How long each -flush-command attempt may run before it is killed")
+	flushRetries    = flag.Int("flush-retries", 0, "How many times a failed -flush-command is retried")
+	flushRequired   = flag.Bool("flush-required", false, "Abort the restart and keep the child running if all -flush-command attempts fail")
+	noRestart       = flag.Bool("no-restart-on-config", false, "Only log and count config changes instead of restarting the child")
+	envFile         = flag.String("env-file", "", "Dotenv-style file of KEY=VALUE lines added to the child's environment")
+	argsFile        = flag.String("args-file", "", "File with child arguments, one per line, placed before any trailing args")
+	readinessTCP    = flag.String("readiness-tcp", "", "host:port that must accept connections before the child counts as ready")
+	readinessHTTP   = flag.String("readiness-http", "", "URL that must return 2xx/3xx before the child counts as ready")
+	readinessExec   = flag.String("readiness-exec", "", "Shell command that must exit 0 before the child counts as ready")
+	readinessFile   = flag.String("readiness-file", "", "Marker file the child must create before it counts as ready; removed before every (re)start")
+	readinessText   = flag.String("readiness-file-content", "", "Text the -readiness-file must contain")
+	readinessEvery  = flag.Duration("readiness-interval", time.Second, "Interval (and per-check timeout) of the readiness probe")
+	readinessTime   = flag.Duration("readiness-timeout", 30*time.Second, "How long a (re)started child may take to pass its readiness probe")
+	readinessPass   = flag.Int("readiness-success-threshold", 1, "Consecutive readiness probe passes required to count as ready")
+	readinessFail   = flag.Int("readiness-failure-threshold", 0, "Keep probing once ready and report not-ready after this many consecutive failures (disabled if 0)")
+	restartRetries  = flag.Int("restart-retries", 0, "How many times a failed start is retried before giving up")
+	restartBackoff  = flag.Duration("restart-backoff", time.Second, "Delay before the first retry of a failed start, doubled on each retry")
+	restartStrategy = flag.String("restart-backoff-strategy", "exponential", "How retry delays are chosen: exponential, or full-jitter (random up to the exponential delay)")
+	startLimitIntvl = flag.Duration("start-limit-interval", 0, "Window in which child starts are counted for -start-limit-burst (disabled if 0)")
+	startLimitBurst = flag.Int("start-limit-burst", 0, "Give up if the child is started more than this many times within -start-limit-interval")
+	circuitFailures = flag.Int("reload-circuit-failures", 0, "With -rollback-on-failed-reload, ignore config changes for -reload-circuit-cooldown after this many failed reloads in a row (disabled if 0)")
+	circuitCooldown = flag.Duration("reload-circuit-cooldown", 5*time.Minute, "How long config changes are ignored once -reload-circuit-failures is reached")
+	mirrorChildSig  = flag.Bool("mirror-child-signal", false, "When the child is killed by a signal, terminate flush-manager with the same signal after cleanup")
+	restartCodes    = flag.String("restart-exit-codes", "", "Comma-separated exit codes after which the child is started again instead of shutting down, e.g. 75 for transient errors")
+	fatalCodes      = flag.String("fatal-exit-codes", "", "Comma-separated exit codes that always shut flush-manager down, e.g. 78 for configuration errors")
+	exitAction      = flag.String("exit-action", "shutdown", "Reaction to other abnormal child exits: shutdown, restart or restart-with-backoff")
+	maxStartupTime  = flag.Duration("max-startup-time", 0, "Exit non-zero if the child is not started and ready within this time, counting init command, retries and readiness probe (disabled if 0)")
+	exitHistory     = flag.Int("exit-history", 10, "How many past child exits are kept for the /exits endpoint")
+	restartMaxDelay = flag.Duration("restart-max-backoff", 30*time.Second, "Upper bound on the delay between start retries")
 )
 
 const Version = "1.0.0"
 
+// killSignals are the signals accepted by -force-kill-signal
+var killSignals = map[string]syscall.Signal{
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"ABRT": syscall.SIGABRT,
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// reopenSignals are the signals accepted by -reopen-signal
+var reopenSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// watchFlags collects the repeatable -watch flag
+type watchFlags []manager.WatchSpec
+
+func (w *watchFlags) String() string {
+	return fmt.Sprint(*w)
+}
+
+func (w *watchFlags) Set(value string) error {
+	spec, err := parseWatch(value)
+	if err != nil {
+		return err
+	}
+	*w = append(*w, spec)
+	return nil
+}
+
+var watches watchFlags
+
+// listenFlags collects the repeatable -listen flag
+type listenFlags []string
+
+func (l *listenFlags) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listenFlags) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+var listens listenFlags
+
+func init() {
+	flag.Var(&watches, "watch", "Additional file to watch as PATH=ACTION, repeatable. ACTION is restart, signal[:NAME] (default SIGHUP) or command:COMMAND (run through /bin/sh -c)")
+	flag.Var(&listens, "listen", "Address the manager listens on and passes to the child as a socket-activation descriptor (host:port or unix:PATH), repeatable")
+}
+
+// parseWatch parses a -watch value
+func parseWatch(value string) (manager.WatchSpec, error) {
+	path, action, ok := strings.Cut(value, "=")
+	if !ok || path == "" {
+		return manager.WatchSpec{}, fmt.Errorf("expected PATH=ACTION, got %q", value)
+	}
+	name, arg, _ := strings.Cut(action, ":")
+
+	spec := manager.WatchSpec{Path: path}
+	var err error
+	if spec.Action, err = manager.ParseWatchAction(name); err != nil {
+		return manager.WatchSpec{}, err
+	}
+
+	switch spec.Action {
+	case manager.WatchSignal:
+		if arg != "" {
+			sig, ok := killSignals[strings.TrimPrefix(strings.ToUpper(arg), "SIG")]
+			if !ok {
+				return manager.WatchSpec{}, fmt.Errorf("unknown signal %q", arg)
+			}
+			spec.Signal = sig
+		}
+	case manager.WatchCommand:
+		if arg == "" {
+			return manager.WatchSpec{}, fmt.Errorf("command action for %s requires a command", path)
+		}
+		spec.Command = []string{"/bin/sh", "-c", arg}
+	}
+	return spec, nil
+}
+
+// parseExitCodes parses a comma-separated list of exit codes
+func parseExitCodes(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var codes []int
+	for _, field := range strings.Split(value, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("expected a list of exit codes, got %q", value)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// isFlagSet reports whether the flag name was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// countSet counts the non-empty values
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
 func main() {
 	flag.Parse()
 
@@ -30,19 +261,243 @@ func main() {
 		os.Exit(0)
 	}
 
+	level, err := logger.ParseLevel(*logLevel)
+	if err != nil {
+		logger.Fatal("Invalid -log-level: %v", err)
+	}
+	switch {
+	case *quiet && *verbose:
+		logger.Fatal("Only one of -quiet and -verbose may be set")
+	case *quiet:
+		level = logger.LevelError
+	case *verbose:
+		level = logger.LevelDebug
+	}
+	logger.SetLevel(level)
+	logger.SetPrefix(*logPrefix)
+	logger.SetIncludePID(*logPID)
+
 	logger.Info("=== Flush Manager v%s starting ===", Version)
 	logger.Info("PID: %d", os.Getpid())
 
 	// Get additional args to pass to the child process
+	childCommand := *command
 	args := flag.Args()
+	if *cmdline != "" {
+		if isFlagSet("command") || len(args) > 0 || *useShell {
+			logger.Fatal("-cmdline cannot be combined with -command, -shell or trailing arguments")
+		}
+		childCommand, args, err = manager.ParseCommandLine(*cmdline)
+		if err != nil {
+			logger.Fatal("Invalid -cmdline: %v", err)
+		}
+	}
+	if *useShell {
+		script := strings.Join(args, " ")
+		if script == "" {
+			script = *command
+		}
+		childCommand = *shellPath
+		args = []string{"-c", script}
+	}
 
 	config := manager.Config{
-		Command:        *command,
-		Args:           args,
-		ConfigFilePath: *configFile,
+		Command:              childCommand,
+		SignalGroup:          *useShell,
+		Args:                 args,
+		ConfigFilePath:       *configFile,
+		RequireConfigFile:    *requireConfig,
+		NoRestartOnConfig:    *noRestart,
+		Watches:              watches,
+		ListenSockets:        listens,
+		OnChangeTimeout:      *onChangeTimeout,
+		InitTimeout:          *initTimeout,
+		WatcherSelfTest:      *watcherTest,
+		WatcherHoldOpen:      *watchHoldOpen,
+		WatcherSettle:        *watchSettle,
+		ConfigChecksumFile:   *checksumFile,
+		DetectOOM:            *detectOOM,
+		ChildStdin:           *childStdin,
+		ChildStdout:          *childStdout,
+		ChildStdoutRateLimit: *stdoutRate,
+		ChildStderrRateLimit: *stderrRate,
+		ChildStderr:          *childStderr,
+		EnvFile:              *envFile,
+		ArgsFile:             *argsFile,
+		HealthAddr:           *healthAddr,
+		LameDuckPeriod:       *lameDuck,
+		AllocatePTY:          *usePTY,
+		ResolveCommand:       *resolveCmd,
+		WatchSelf:            *watchSelf,
+		ShutdownTimeout:      *shutdownTimeout,
+		HTTPShutdownTimeout:  *httpShutdown,
+		PostExitDelay:        *postExitDelay,
+		IdleTimeout:          *idleTimeout,
+		IdleExit:             *idleExit,
+		DrainSentinel:        *drainSentinel,
+		TLSCertFile:          *tlsCert,
+		TLSKeyFile:           *tlsKey,
+		TLSClientCAFile:      *tlsClientCA,
+		AuthToken:            *authToken,
+		HealthNoAuth:         *healthNoAuth,
+		ReadinessInterval:    *readinessEvery,
+		ReadinessTimeout:     *readinessTime,
+		RestartPolicy: manager.RestartPolicy{
+			MaxRetries:     *restartRetries,
+			InitialBackoff: *restartBackoff,
+			MaxBackoff:     *restartMaxDelay,
+		},
+		StartLimit: manager.StartLimit{
+			Interval: *startLimitIntvl,
+			Burst:    *startLimitBurst,
+		},
+		ReloadCircuitBreaker: manager.CircuitBreaker{
+			Failures: *circuitFailures,
+			Cooldown: *circuitCooldown,
+		},
+		ExitHistorySize:           *exitHistory,
+		MaxStartupTime:            *maxStartupTime,
+		MirrorChildSignal:         *mirrorChildSig,
+		MemoryRestartThreshold:    *memoryThreshold,
+		MemoryCheckInterval:       *memoryInterval,
+		WebhookURL:                *webhookURL,
+		WebhookSecret:             *webhookSecret,
+		HeartbeatFile:             *heartbeatFile,
+		HeartbeatInterval:         *heartbeatIntvl,
+		TeeOutput:                 *teeOutput,
+		WatchMetadata:             *watchMetadata,
+		MaxConfigSize:             *maxConfigSize,
+		RejectOversizedConfig:     *rejectOversized,
+		WatcherDataMarker:         *dataMarker,
+		FlushTimeout:              *flushTimeout,
+		FlushRetries:              *flushRetries,
+		FlushRequired:             *flushRequired,
+		DrainCallbackAddr:         *drainCallback,
+		WatchBinary:               *watchBinary,
+		WatcherStabilityWindow:    *stabilityWindow,
+		RollbackOnFailedReload:    *rollbackReload,
+		OutputWaitDelay:           *outputWait,
+		ReadinessSuccessThreshold: *readinessPass,
+		ReadinessFailureThreshold: *readinessFail,
 	}
 
-	logger.Info("Configuration: command=%s, config_file=%s, args=%v", *command, *configFile, args)
+	strategy, err := manager.ParseBackoffStrategy(*restartStrategy)
+	if err != nil {
+		logger.Fatal("Invalid -restart-backoff-strategy: %v", err)
+	}
+	config.RestartPolicy.Strategy = strategy
+
+	followMode, err := watcher.ParseSymlinkFollowMode(*symlinkFollow)
+	if err != nil {
+		logger.Fatal("Invalid -symlink-follow: %v", err)
+	}
+	config.SymlinkFollowMode = followMode
+
+	action, err := manager.ParseChangeAction(*onChange)
+	if err != nil {
+		logger.Fatal("Invalid -on-change: %v", err)
+	}
+	config.OnChange = action
+	if *restartWindow != "" {
+		window, err := manager.ParseRestartWindow(*restartWindow)
+		if err != nil {
+			logger.Fatal("Invalid -restart-window: %v", err)
+		}
+		config.RestartWindow = window
+	}
+	if *onChangeCmd != "" {
+		config.OnChangeCommand = []string{"/bin/sh", "-c", *onChangeCmd}
+	}
+	if *initCommand != "" {
+		config.InitCommand = []string{"/bin/sh", "-c", *initCommand}
+	}
+	if *flushCommand != "" {
+		config.FlushCommand = []string{"/bin/sh", "-c", *flushCommand}
+	}
+
+	mode, err := watcher.ParseWatchMode(*watchMode)
+	if err != nil {
+		logger.Fatal("Invalid -watch-mode: %v", err)
+	}
+	config.WatchMode = mode
+	if *watchStrats != "" {
+		strategies, err := watcher.ParseStrategies(*watchStrats)
+		if err != nil {
+			logger.Fatal("Invalid -watch-strategies: %v", err)
+		}
+		config.WatchStrategies = strategies
+	}
+
+	trigger, err := watcher.ParseChangeTrigger(*changeTrigger)
+	if err != nil {
+		logger.Fatal("Invalid -change-trigger: %v", err)
+	}
+	config.ChangeTrigger = trigger
+	config.PollInterval = *pollInterval
+
+	config.SigtermAction, err = manager.ParseSignalAction(*sigtermAction)
+	if err != nil {
+		logger.Fatal("Invalid -sigterm-action: %v", err)
+	}
+
+	exit, err := manager.ParseExitAction(*exitAction)
+	if err != nil {
+		logger.Fatal("Invalid -exit-action: %v", err)
+	}
+	config.DefaultExitAction = exit
+	if config.RestartOnExitCodes, err = parseExitCodes(*restartCodes); err != nil {
+		logger.Fatal("Invalid -restart-exit-codes: %v", err)
+	}
+	if config.FatalExitCodes, err = parseExitCodes(*fatalCodes); err != nil {
+		logger.Fatal("Invalid -fatal-exit-codes: %v", err)
+	}
+
+	killSig, ok := killSignals[strings.TrimPrefix(strings.ToUpper(*forceKill), "SIG")]
+	if !ok {
+		logger.Fatal("Invalid -force-kill-signal %q: expected one of SIGKILL, SIGTERM, SIGINT, SIGQUIT, SIGABRT, SIGHUP, SIGUSR1, SIGUSR2", *forceKill)
+	}
+	config.ForceKillSignal = killSig
+
+	sig, ok := reopenSignals[strings.TrimPrefix(strings.ToUpper(*reopenSignal), "SIG")]
+	if !ok {
+		logger.Fatal("Invalid -reopen-signal %q: expected SIGHUP, SIGUSR1 or SIGUSR2", *reopenSignal)
+	}
+	config.ReopenSignal = sig
+
+	switch {
+	case countSet(*readinessTCP, *readinessHTTP, *readinessExec, *readinessFile) > 1:
+		logger.Fatal("Only one of -readiness-tcp, -readiness-http, -readiness-exec and -readiness-file may be set")
+	case *readinessText != "" && *readinessFile == "":
+		logger.Fatal("-readiness-file-content requires -readiness-file")
+	case *readinessTCP != "":
+		config.ReadinessProbe = probe.TCP{Address: *readinessTCP}
+	case *readinessHTTP != "":
+		config.ReadinessProbe = probe.HTTP{URL: *readinessHTTP}
+	case *readinessExec != "":
+		config.ReadinessProbe = probe.Exec{Command: []string{"/bin/sh", "-c", *readinessExec}}
+	case *readinessFile != "":
+		config.ReadinessProbe = probe.File{Path: *readinessFile, Content: *readinessText}
+	}
+
+	if *basicAuth != "" {
+		user, password, ok := strings.Cut(*basicAuth, ":")
+		if !ok || user == "" {
+			logger.Fatal("Invalid -basic-auth %q: expected user:pass", *basicAuth)
+		}
+		config.BasicAuthUser = user
+		config.BasicAuthPassword = password
+	}
+
+	logger.Info("Configuration: command=%s, config_file=%s, args=%v", childCommand, *configFile, args)
+
+	if *execMode {
+		// The exec'd child keeps the manager's stdin
+		stdinInherited := config.ChildStdin == "" || config.ChildStdin == manager.StdinInherit
+		if config.ConfigFilePath == "" && len(config.Watches) == 0 && !config.WatchBinary && stdinInherited {
+			logger.Fatal("Failed to exec child: %v", manager.Exec(config))
+		}
+		logger.Info("Exec mode: changes are watched or stdin is redirected, supervising the child")
+	}
 
 	m, err := manager.New(config)
 	if err != nil {
@@ -50,8 +505,45 @@ func main() {
 	}
 
 	if err := m.Run(); err != nil {
+		if errors.Is(err, manager.ErrSelfUpdate) {
+			reexec()
+		}
+		var signaled *manager.ChildSignaledError
+		if errors.As(err, &signaled) {
+			mirrorSignal(signaled.Signal)
+		}
 		logger.Fatal("Manager error: %v", err)
 	}
 
 	logger.Info("Manager exiting normally")
 }
+
+// mirrorSignal terminates flush-manager with the signal that killed the child
+func mirrorSignal(sig syscall.Signal) {
+	logger.Info("Child was killed by signal %v, terminating with the same signal", sig)
+	if err := process.RaiseDefault(sig); err != nil {
+		logger.Error("Failed to mirror child signal: %v", err)
+	}
+	os.Exit(128 + int(sig))
+}
+
+// reexec replaces the current process with the updated flush-manager binary
+func reexec() {
+	exe, err := os.Executable()
+	if err != nil {
+		logger.Fatal("Failed to locate own executable for self-update: %v", err)
+	}
+
+	info, err := os.Stat(exe)
+	if err != nil {
+		logger.Fatal("Failed to stat updated executable %s: %v", exe, err)
+	}
+	if info.Mode()&0111 == 0 {
+		logger.Fatal("Updated executable %s is not executable", exe)
+	}
+
+	logger.Info("Re-executing %s %v", exe, os.Args[1:])
+	if err := syscall.Exec(exe, os.Args, os.Environ()); err != nil {
+		logger.Fatal("Failed to re-exec %s: %v", exe, err)
+	}
+}
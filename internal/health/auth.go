@@ -0,0 +1,89 @@
+package health
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Auth holds the credentials required to access the server's endpoints
+// Requests are accepted if they match either configured credential
+type Auth struct {
+	// BearerToken is accepted as "Authorization: Bearer <token>"
+	BearerToken string
+
+	// BasicUser and BasicPassword are accepted as HTTP basic auth
+	BasicUser     string
+	BasicPassword string
+
+	// ExemptProbes leaves /healthz and /ready unauthenticated, since
+	// orchestrator probes often cannot send credentials
+	ExemptProbes bool
+}
+
+// enabled reports whether any credential is configured
+func (a Auth) enabled() bool {
+	return a.BearerToken != "" || a.BasicUser != ""
+}
+
+// SetAuth protects the server's endpoints with the given credentials
+// It must be called before Start
+func (s *Server) SetAuth(auth Auth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = auth
+}
+
+// authenticate wraps next so requests without valid credentials get a 401
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	auth := s.auth
+	if !auth.enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.ExemptProbes && (r.URL.Path == "/healthz" || r.URL.Path == "/ready") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if auth.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if auth.BasicUser != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="flush-manager"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="flush-manager"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// authorized checks the request's credentials in constant time
+func (a Auth) authorized(r *http.Request) bool {
+	if a.BearerToken != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(token, a.BearerToken) {
+			return true
+		}
+	}
+
+	if a.BasicUser != "" {
+		if user, password, ok := r.BasicAuth(); ok {
+			// Evaluate both comparisons so timing doesn't reveal which one failed
+			userOK := secureEqual(user, a.BasicUser)
+			passwordOK := secureEqual(password, a.BasicPassword)
+			if userOK && passwordOK {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// secureEqual compares two secrets without leaking their contents through timing
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
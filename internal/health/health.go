@@ -0,0 +1,218 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// ReadyFunc reports whether the manager is currently ready to serve traffic
+type ReadyFunc func() bool
+
+// DetailFunc returns extra diagnostic text appended to the /ready body
+type DetailFunc func() string
+
+// Server exposes the manager's health endpoints over HTTP
+type Server struct {
+	addr     string
+	ready    ReadyFunc
+	detail   DetailFunc
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+	tls      *tls.Config
+	auth     Auth
+	mu       sync.Mutex
+	done     chan struct{} // closed once Serve returned
+}
+
+// NewServer creates a new health server listening on addr
+// The ready function is consulted on every /ready request
+func NewServer(addr string, ready ReadyFunc) *Server {
+	s := &Server{
+		addr:  addr,
+		ready: ready,
+		mux:   http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/ready", s.handleReady)
+
+	return s
+}
+
+// SetDetail sets a function whose output is appended to the /ready body
+func (s *Server) SetDetail(detail DetailFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detail = detail
+}
+
+// Handle registers an additional handler on the server's mux
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// EnableTLS serves over TLS using the given certificate and key. If clientCAFile
+// is set, clients must present a certificate signed by that CA (mTLS).
+// Certificates are loaded immediately so misconfiguration fails fast
+func (s *Server) EnableTLS(certFile, keyFile, clientCAFile string) error {
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("both TLS certificate and key are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate %s and key %s: %w", certFile, keyFile, err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA %s: %w", clientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	s.mu.Lock()
+	s.tls = config
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Start starts listening and serving in the background
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	scheme := "http"
+	if s.tls != nil {
+		listener = tls.NewListener(listener, s.tls)
+		scheme = "https"
+		if s.tls.ClientAuth == tls.RequireAndVerifyClientCert {
+			scheme = "https (mTLS)"
+		}
+	}
+
+	s.listener = listener
+	s.server = &http.Server{Handler: s.authenticate(s.mux), TLSConfig: s.tls}
+	logger.Info("Health server listening on %s over %s", listener.Addr(), scheme)
+
+	s.done = make(chan struct{})
+	go func(server *http.Server, listener net.Listener, done chan struct{}) {
+		defer close(done)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Health server error: %v", err)
+		}
+	}(s.server, listener, s.done)
+
+	return nil
+}
+
+// Addr returns the address the server is listening on, or the configured
+// address if the server has not been started
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Close stops the server immediately, interrupting in-flight requests
+func (s *Server) Close() error {
+	server, done := s.detach()
+	if server == nil {
+		return nil
+	}
+
+	logger.Debug("Closing health server")
+	err := server.Close()
+	<-done
+	return err
+}
+
+// Shutdown stops the server gracefully, letting in-flight requests finish
+// until ctx expires, after which the remaining connections are closed. It
+// returns once the listener is released
+func (s *Server) Shutdown(ctx context.Context) error {
+	server, done := s.detach()
+	if server == nil {
+		return nil
+	}
+
+	logger.Debug("Shutting down health server")
+	err := server.Shutdown(ctx)
+	if err != nil {
+		logger.Warn("Health server requests still in flight, closing: %v", err)
+		server.Close()
+	}
+	<-done
+	return err
+}
+
+// detach takes the running server out of s, returning it and the channel
+// closed once it stopped serving
+func (s *Server) detach() (*http.Server, chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	server, done := s.server, s.done
+	s.server = nil
+	s.listener = nil
+	s.done = nil
+	return server, done
+}
+
+// handleHealthz reports that the manager process is alive
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReady reports whether the manager is ready to serve traffic
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	detail := s.detail
+	s.mu.Unlock()
+
+	if s.ready != nil && s.ready() {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ready")
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "not ready")
+	}
+
+	if detail != nil {
+		if text := detail(); text != "" {
+			fmt.Fprintln(w, text)
+		}
+	}
+}
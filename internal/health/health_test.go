@@ -0,0 +1,332 @@
+package health
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_Endpoints(t *testing.T) {
+	var ready atomic.Bool
+
+	s := NewServer("127.0.0.1:0", ready.Load)
+	require.NoError(t, s.Start())
+	defer s.Close()
+
+	base := "http://" + s.Addr()
+
+	t.Run("healthz always ok", func(t *testing.T) {
+		code, body := get(t, base+"/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, "ok")
+	})
+
+	t.Run("ready reports not ready", func(t *testing.T) {
+		ready.Store(false)
+		code, _ := get(t, base+"/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	})
+
+	t.Run("ready reports ready", func(t *testing.T) {
+		ready.Store(true)
+		code, _ := get(t, base+"/ready")
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
+
+func TestServer_ReadyDetail(t *testing.T) {
+	s := NewServer("127.0.0.1:0", func() bool { return false })
+	s.SetDetail(func() string { return "probe tcp://db:5432: fail" })
+	require.NoError(t, s.Start())
+	defer s.Close()
+
+	code, body := get(t, "http://"+s.Addr()+"/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready\nprobe tcp://db:5432: fail\n", body)
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	start := func(t *testing.T, delay time.Duration) (*Server, chan struct{}) {
+		t.Helper()
+		s := NewServer("127.0.0.1:0", nil)
+		entered := make(chan struct{})
+		s.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			time.Sleep(delay)
+			fmt.Fprintln(w, "done")
+		}))
+		require.NoError(t, s.Start())
+		return s, entered
+	}
+
+	t.Run("in-flight request completes", func(t *testing.T) {
+		s, entered := start(t, 200*time.Millisecond)
+		addr := s.Addr()
+
+		result := make(chan string, 1)
+		go func() {
+			_, body := get(t, "http://"+addr+"/slow")
+			result <- body
+		}()
+		<-entered
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		assert.NoError(t, s.Shutdown(ctx))
+		assert.Equal(t, "done\n", <-result)
+
+		// The port is free again once Shutdown returned
+		listener, err := net.Listen("tcp", addr)
+		require.NoError(t, err)
+		listener.Close()
+	})
+
+	t.Run("timeout closes remaining connections", func(t *testing.T) {
+		s, entered := start(t, 2*time.Second)
+
+		go func() {
+			resp, err := http.Get("http://" + s.Addr() + "/slow")
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-entered
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		begin := time.Now()
+		assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+		assert.Less(t, time.Since(begin), time.Second)
+	})
+
+	t.Run("shutdown before start", func(t *testing.T) {
+		s := NewServer("127.0.0.1:0", nil)
+		assert.NoError(t, s.Shutdown(context.Background()))
+	})
+}
+
+func TestServer_Close(t *testing.T) {
+	t.Run("close before start", func(t *testing.T) {
+		s := NewServer("127.0.0.1:0", nil)
+		assert.NoError(t, s.Close())
+	})
+
+	t.Run("start fails on invalid address", func(t *testing.T) {
+		s := NewServer("invalid-address", nil)
+		assert.Error(t, s.Start())
+	})
+}
+
+// testCA is a throwaway certificate authority for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	writePEM(t, filepath.Join(ca.dir, "ca.pem"), "CERTIFICATE", der)
+	return ca
+}
+
+// issue signs a new leaf certificate and returns its cert and key file paths
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(ca.dir, name+".pem")
+	keyFile := filepath.Join(ca.dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+func TestServer_TLS(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+
+	t.Run("serves over https", func(t *testing.T) {
+		s := NewServer("127.0.0.1:0", nil)
+		require.NoError(t, s.EnableTLS(certFile, keyFile, ""))
+		require.NoError(t, s.Start())
+		defer s.Close()
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: ca.pool()},
+		}}
+		resp, err := client.Get("https://" + s.Addr() + "/healthz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("requires client certificate with client CA", func(t *testing.T) {
+		clientCert, clientKey := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+
+		s := NewServer("127.0.0.1:0", nil)
+		require.NoError(t, s.EnableTLS(certFile, keyFile, filepath.Join(ca.dir, "ca.pem")))
+		require.NoError(t, s.Start())
+		defer s.Close()
+
+		anonymous := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: ca.pool()},
+		}}
+		_, err := anonymous.Get("https://" + s.Addr() + "/healthz")
+		assert.Error(t, err)
+
+		pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		require.NoError(t, err)
+		authenticated := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: ca.pool(), Certificates: []tls.Certificate{pair}},
+		}}
+		resp, err := authenticated.Get("https://" + s.Addr() + "/healthz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("fails fast on bad certificate files", func(t *testing.T) {
+		s := NewServer("127.0.0.1:0", nil)
+		assert.Error(t, s.EnableTLS("/nonexistent/cert.pem", keyFile, ""))
+		assert.Error(t, s.EnableTLS(certFile, "", ""))
+		assert.Error(t, s.EnableTLS(certFile, keyFile, "/nonexistent/ca.pem"))
+	})
+}
+
+func TestServer_Auth(t *testing.T) {
+	request := func(t *testing.T, url string, setup func(*http.Request)) int {
+		t.Helper()
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err)
+		if setup != nil {
+			setup(req)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	bearer := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	basic := func(user, password string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, password) }
+	}
+
+	s := NewServer("127.0.0.1:0", func() bool { return true })
+	s.SetAuth(Auth{BearerToken: "secret", BasicUser: "admin", BasicPassword: "pass"})
+	require.NoError(t, s.Start())
+	defer s.Close()
+	base := "http://" + s.Addr()
+
+	t.Run("rejects missing credentials", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(t, base+"/ready", nil))
+		assert.Equal(t, http.StatusUnauthorized, request(t, base+"/healthz", nil))
+	})
+
+	t.Run("rejects wrong credentials", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(t, base+"/ready", bearer("wrong")))
+		assert.Equal(t, http.StatusUnauthorized, request(t, base+"/ready", basic("admin", "wrong")))
+		assert.Equal(t, http.StatusUnauthorized, request(t, base+"/ready", basic("other", "pass")))
+	})
+
+	t.Run("accepts bearer token", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(t, base+"/ready", bearer("secret")))
+	})
+
+	t.Run("accepts basic auth", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(t, base+"/ready", basic("admin", "pass")))
+	})
+
+	t.Run("probes exempt when configured", func(t *testing.T) {
+		exempt := NewServer("127.0.0.1:0", func() bool { return true })
+		exempt.SetAuth(Auth{BearerToken: "secret", ExemptProbes: true})
+		exempt.Handle("/metrics", http.NotFoundHandler())
+		require.NoError(t, exempt.Start())
+		defer exempt.Close()
+		base := "http://" + exempt.Addr()
+
+		assert.Equal(t, http.StatusOK, request(t, base+"/healthz", nil))
+		assert.Equal(t, http.StatusOK, request(t, base+"/ready", nil))
+		assert.Equal(t, http.StatusUnauthorized, request(t, base+"/metrics", nil))
+	})
+}
@@ -3,36 +3,199 @@ package logger
 import (
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-const prefix = "[flush-manager]"
+// defaultPrefix is the name every message is prefixed with by default
+const defaultPrefix = "flush-manager"
+
+// The prefix is formatted as [name] or [name:pid]; prefix holds the result
+var (
+	prefixMu   sync.Mutex
+	prefixName = defaultPrefix
+	prefixPID  bool
+	prefix     atomic.Pointer[string]
+)
+
+// SetPrefix replaces flush-manager in the [flush-manager] prefix of every
+// following message with name, to tell several instances apart in one log
+func SetPrefix(name string) {
+	prefixMu.Lock()
+	defer prefixMu.Unlock()
+	prefixName = name
+	formatPrefix()
+}
+
+// SetIncludePID makes the prefix include the manager PID, as in
+// [flush-manager:12345]
+func SetIncludePID(include bool) {
+	prefixMu.Lock()
+	defer prefixMu.Unlock()
+	prefixPID = include
+	formatPrefix()
+}
+
+// formatPrefix updates prefix and the level loggers from the prefix settings
+func formatPrefix() {
+	p := "[" + prefixName
+	if prefixPID {
+		p += ":" + strconv.Itoa(os.Getpid())
+	}
+	p += "]"
+	prefix.Store(&p)
+
+	infoLogger.SetPrefix(p + " INFO: ")
+	warnLogger.SetPrefix(p + " WARN: ")
+	errorLogger.SetPrefix(p + " ERROR: ")
+	debugLogger.SetPrefix(p + " DEBUG: ")
+}
+
+// Level is the minimum severity of messages that are logged
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+}
+
+// ParseLevel parses a level name as returned by String
+func ParseLevel(name string) (Level, error) {
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		if l.String() == name {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", name)
+}
+
+// level is the current Level; everything is logged by default
+var level atomic.Int32
+
+// SetLevel makes messages below l be dropped. Fatal always logs
+func SetLevel(l Level) {
+	level.Store(int32(l))
+}
+
+// enabled reports whether messages of severity l are logged
+func enabled(l Level) bool {
+	return l >= Level(level.Load())
+}
+
+// fields are appended to every message as key=value, e.g. the child
+// generation, so all lines of one restart can be filtered for
+var (
+	fieldsMu sync.Mutex
+	fields   = map[string]string{}
+	suffix   atomic.Pointer[string] // fields formatted for output
+)
+
+// SetField appends key=value to every following message, replacing the
+// value of an existing key
+func SetField(key, value string) {
+	fieldsMu.Lock()
+	defer fieldsMu.Unlock()
+	fields[key] = value
+	formatFields()
+}
+
+// Field returns the value appended for key, or "" if it is not set
+func Field(key string) string {
+	fieldsMu.Lock()
+	defer fieldsMu.Unlock()
+	return fields[key]
+}
+
+// ClearField stops appending key to messages
+func ClearField(key string) {
+	fieldsMu.Lock()
+	defer fieldsMu.Unlock()
+	delete(fields, key)
+	formatFields()
+}
+
+// formatFields updates suffix from fields, sorted by key
+func formatFields() {
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		fmt.Fprintf(&b, " %s=%s", key, fields[key])
+	}
+	s := b.String()
+	suffix.Store(&s)
+}
+
+// output logs a message with the fields appended
+func output(l *log.Logger, format string, v []interface{}) {
+	if s := suffix.Load(); s != nil && *s != "" {
+		l.Print(fmt.Sprintf(format, v...) + *s)
+		return
+	}
+	l.Printf(format, v...)
+}
 
 var (
 	infoLogger  *log.Logger
+	warnLogger  *log.Logger
 	errorLogger *log.Logger
 	debugLogger *log.Logger
 )
 
 func init() {
-	infoLogger = log.New(os.Stdout, prefix+" INFO: ", log.Ldate|log.Ltime)
-	errorLogger = log.New(os.Stderr, prefix+" ERROR: ", log.Ldate|log.Ltime)
-	debugLogger = log.New(os.Stdout, prefix+" DEBUG: ", log.Ldate|log.Ltime)
+	infoLogger = log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	warnLogger = log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	errorLogger = log.New(os.Stderr, "", log.Ldate|log.Ltime)
+	debugLogger = log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	formatPrefix()
 }
 
 // Info logs an info message
 func Info(format string, v ...interface{}) {
-	infoLogger.Printf(format, v...)
+	if enabled(LevelInfo) {
+		output(infoLogger, format, v)
+	}
+}
+
+// Warn logs a warning message
+func Warn(format string, v ...interface{}) {
+	if enabled(LevelWarn) {
+		output(warnLogger, format, v)
+	}
 }
 
 // Error logs an error message
 func Error(format string, v ...interface{}) {
-	errorLogger.Printf(format, v...)
+	if enabled(LevelError) {
+		output(errorLogger, format, v)
+	}
 }
 
 // Debug logs a debug message
 func Debug(format string, v ...interface{}) {
-	debugLogger.Printf(format, v...)
+	if enabled(LevelDebug) {
+		output(debugLogger, format, v)
+	}
 }
 
 // Infof logs an info message (alias for compatibility)
@@ -40,6 +203,11 @@ func Infof(format string, v ...interface{}) {
 	Info(format, v...)
 }
 
+// Warnf logs a warning message (alias for compatibility)
+func Warnf(format string, v ...interface{}) {
+	Warn(format, v...)
+}
+
 // Errorf logs an error message (alias for compatibility)
 func Errorf(format string, v ...interface{}) {
 	Error(format, v...)
@@ -52,17 +220,17 @@ func Debugf(format string, v ...interface{}) {
 
 // Fatal logs an error message and exits
 func Fatal(format string, v ...interface{}) {
-	errorLogger.Printf(format, v...)
+	output(errorLogger, format, v)
 	os.Exit(1)
 }
 
 // Printf logs to stdout with prefix
 func Printf(format string, v ...interface{}) {
-	fmt.Printf(prefix+" "+format, v...)
+	fmt.Printf(*prefix.Load()+" "+format, v...)
 }
 
 // Println logs to stdout with prefix
 func Println(v ...interface{}) {
-	fmt.Print(prefix + " ")
+	fmt.Print(*prefix.Load() + " ")
 	fmt.Println(v...)
 }
@@ -0,0 +1,97 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		parsed, err := ParseLevel(l.String())
+		require.NoError(t, err)
+		assert.Equal(t, l, parsed)
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestSetLevel(t *testing.T) {
+	defer SetLevel(LevelDebug)
+
+	assert.True(t, enabled(LevelDebug), "everything is logged by default")
+
+	SetLevel(LevelError)
+	assert.False(t, enabled(LevelDebug))
+	assert.False(t, enabled(LevelInfo))
+	assert.False(t, enabled(LevelWarn))
+	assert.True(t, enabled(LevelError))
+
+	SetLevel(LevelInfo)
+	assert.False(t, enabled(LevelDebug))
+	assert.True(t, enabled(LevelWarn))
+}
+
+func TestSetField(t *testing.T) {
+	var buf bytes.Buffer
+	infoLogger.SetOutput(&buf)
+	defer infoLogger.SetOutput(os.Stdout)
+	defer ClearField("gen")
+	defer ClearField("app")
+
+	Info("no fields")
+	assert.Contains(t, buf.String(), "no fields\n")
+
+	buf.Reset()
+	SetField("gen", "7")
+	SetField("app", "redis")
+	Info("started %d%%", 100)
+	assert.Contains(t, buf.String(), "started 100% app=redis gen=7\n")
+
+	buf.Reset()
+	SetField("gen", "8")
+	ClearField("app")
+	Info("restarted")
+	assert.Contains(t, buf.String(), "restarted gen=8\n")
+	assert.Equal(t, "8", Field("gen"))
+	assert.Empty(t, Field("app"))
+}
+
+func TestSetPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	warnLogger.SetOutput(&buf)
+	defer warnLogger.SetOutput(os.Stdout)
+	defer SetPrefix(defaultPrefix)
+	defer SetIncludePID(false)
+
+	Warn("default")
+	assert.Contains(t, buf.String(), "[flush-manager] WARN: ")
+
+	buf.Reset()
+	SetPrefix("fm-redis")
+	Warn("renamed")
+	assert.Contains(t, buf.String(), "[fm-redis] WARN: ")
+
+	buf.Reset()
+	SetIncludePID(true)
+	Warn("with pid")
+	assert.Contains(t, buf.String(), fmt.Sprintf("[fm-redis:%d] WARN: ", os.Getpid()))
+
+	// Safe to change while other goroutines log
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			Warn("concurrent %d", i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		SetPrefix(fmt.Sprintf("fm-%d", i))
+	}
+	<-done
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readArgsFile reads child arguments from a file, one argument per line
+// Blank lines and lines starting with # are skipped. Surrounding whitespace is
+// trimmed, so an argument with leading/trailing spaces or a leading # must be
+// quoted: "double quotes" support Go escapes, 'single quotes' are literal
+func readArgsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open args file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var args []string
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		arg, err := unquoteArg(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		args = append(args, arg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read args file %s: %w", path, err)
+	}
+
+	return args, nil
+}
+
+// unquoteArg strips one level of quoting from an args file line
+func unquoteArg(line string) (string, error) {
+	switch line[0] {
+	case '"':
+		arg, err := strconv.Unquote(line)
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted argument %s", line)
+		}
+		return arg, nil
+	case '\'':
+		if len(line) < 2 || line[len(line)-1] != '\'' {
+			return "", fmt.Errorf("unterminated single-quoted argument %s", line)
+		}
+		return line[1 : len(line)-1], nil
+	default:
+		return line, nil
+	}
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// newBinaryWatch watches the child's executable for WatchBinary. A copied
+// binary is written in many chunks, so changes are only reported once it is
+// unchanged for the debounce window, and a chmod after the copy counts too
+func newBinaryWatch(config Config) (*pathWatch, error) {
+	path, err := exec.LookPath(config.Command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve child binary: %w", err)
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, fmt.Errorf("failed to resolve child binary: %w", err)
+	}
+
+	config.WatcherSettle = true
+	config.WatchMetadata = true
+	fw, err := newConfigWatcher(path, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher for %s: %w", path, err)
+	}
+	return &pathWatch{spec: WatchSpec{Path: path, Action: WatchRestart}, fw: fw, binary: true}, nil
+}
+
+// binaryExecutable reports whether the binary at path can replace the
+// running child, logging why not
+func binaryExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.Warn("Child binary %s changed but cannot be read, not restarting: %v", path, err)
+		return false
+	}
+	if !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+		logger.Warn("Child binary %s changed but is not an executable file (%v), not restarting", path, info.Mode())
+		return false
+	}
+	return true
+}
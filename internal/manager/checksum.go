@@ -0,0 +1,135 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// configFingerprint returns the SHA-256 of the config file's content
+func configFingerprint(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadFingerprint reads a persisted fingerprint, returning "" if there is none
+func loadFingerprint(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum file %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// saveFingerprint replaces the checksum file atomically, so a crash never
+// leaves a truncated fingerprint behind
+func saveFingerprint(path, fingerprint string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write checksum file %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(fingerprint + "\n")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write checksum file %s: %w", path, err)
+	}
+	return nil
+}
+
+// configChangedSinceLastRun reports whether the config differs from the
+// fingerprint persisted by a previous run. Without a checksum file, a
+// persisted fingerprint or a readable config it reports false
+func (m *Manager) configChangedSinceLastRun() bool {
+	if m.config.ConfigChecksumFile == "" {
+		return false
+	}
+
+	persisted, err := loadFingerprint(m.config.ConfigChecksumFile)
+	if err != nil {
+		logger.Warn("Ignoring persisted config fingerprint: %v", err)
+		return false
+	}
+	if persisted == "" {
+		return false
+	}
+
+	current, err := configFingerprint(m.configPath())
+	if err != nil {
+		logger.Debug("Cannot fingerprint config file: %v", err)
+		return false
+	}
+	if current == persisted {
+		logger.Debug("Config unchanged since the last run (sha256 %s)", current)
+		return false
+	}
+	logger.Info("Config changed since the last run (sha256 %s -> %s)", persisted, current)
+	return true
+}
+
+// ConfigFingerprint returns the SHA-256 of the config file the running child
+// was started with, updated on every (re)start. A controller can compare it
+// with the file's current hash to tell whether a change is still pending. It
+// is empty before the first start and if the config file was unreadable
+func (m *Manager) ConfigFingerprint() string {
+	if fingerprint := m.fingerprint.Load(); fingerprint != nil {
+		return *fingerprint
+	}
+	return ""
+}
+
+// currentFingerprint returns the fingerprint of the config file as it is now,
+// or "" if it cannot be read
+func (m *Manager) currentFingerprint() string {
+	path := m.configPath()
+	if path == "" {
+		return ""
+	}
+	fingerprint, err := configFingerprint(path)
+	if err != nil {
+		logger.Debug("Cannot fingerprint config file: %v", err)
+		return ""
+	}
+	return fingerprint
+}
+
+// persistFingerprint records the fingerprint of the config the child was
+// started with
+func (m *Manager) persistFingerprint() {
+	if m.config.ConfigChecksumFile == "" {
+		return
+	}
+
+	fingerprint := m.ConfigFingerprint()
+	if fingerprint == "" {
+		return
+	}
+	if err := saveFingerprint(m.config.ConfigChecksumFile, fingerprint); err != nil {
+		logger.Error("Failed to persist config fingerprint: %v", err)
+	}
+}
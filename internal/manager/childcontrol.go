@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// errManagerShutDown is returned by control calls once the manager shut down
+var errManagerShutDown = errors.New("manager is shut down")
+
+// childRequest asks the run loop to stop or start the child
+type childRequest struct {
+	start bool
+	done  chan error
+}
+
+// StopChild stops the child and keeps it stopped while the manager keeps
+// running, e.g. for maintenance. Watching, metrics and the health server
+// carry on, but changes, reload signals and the idle timer leave the child
+// alone until StartChild. It returns once the child exited
+func (m *Manager) StopChild(ctx context.Context) error {
+	return m.controlChild(ctx, false)
+}
+
+// StartChild starts the child stopped by StopChild again, with the current
+// args and config. It returns once the child started and, if configured,
+// passed its readiness probe. A start failure shuts the manager down, as it
+// would after an idle stop
+func (m *Manager) StartChild(ctx context.Context) error {
+	return m.controlChild(ctx, true)
+}
+
+// controlChild hands a request to the run loop, which owns the child, and
+// waits for its outcome
+func (m *Manager) controlChild(ctx context.Context, start bool) error {
+	req := childRequest{start: start, done: make(chan error, 1)}
+	select {
+	case m.childControl <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.ctx.Done():
+		return errManagerShutDown
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// keptStopped reports whether a change has to leave the child alone since
+// StopChild stopped it
+func (m *Manager) keptStopped() bool {
+	if !m.childStopped.Load() {
+		return false
+	}
+	logger.Info("Child stopped by request, the change applies once it is started")
+	return true
+}
+
+// onChildControl stops or starts the child for StopChild and StartChild. It
+// reports whether the run loop has to return, and with which error
+func (m *Manager) onChildControl(req childRequest) (bool, error) {
+	if req.start {
+		return m.startStoppedChild(req.done)
+	}
+	return m.stopChildOnRequest(req.done)
+}
+
+// stopChildOnRequest stops the child until StartChild
+func (m *Manager) stopChildOnRequest(done chan<- error) (bool, error) {
+	if m.childStopped.Load() {
+		done <- nil
+		return false, nil
+	}
+
+	logger.Info("Stopping child on request, the manager keeps running")
+	m.ready.Store(false)
+	if m.idle.Swap(false) {
+		// Already down after the idle timeout; now it stays down
+		m.childStopped.Store(true)
+		done <- nil
+		return false, nil
+	}
+	if m.waitForDrain() {
+		logger.Info("Stop interrupted by signal, shutting down...")
+		done <- errManagerShutDown
+		return true, m.shutdown(false)
+	}
+
+	// Set before stopping, so heartbeat and memory checks leave it alone
+	m.childStopped.Store(true)
+	if err := m.stopChild("StopChild"); err != nil {
+		logger.Error("Failed to stop child: %v", err)
+		done <- err
+		return true, m.abortStartup(err)
+	}
+	// Consume the exit so the run loop doesn't take it for a crash
+	<-m.exitChan
+	logger.Info("Child stopped, waiting for StartChild")
+	done <- nil
+	return false, nil
+}
+
+// startStoppedChild starts the child stopped by StopChild
+func (m *Manager) startStoppedChild(done chan<- error) (bool, error) {
+	if !m.childStopped.Load() {
+		done <- nil
+		return false, nil
+	}
+
+	logger.Info("Starting child on request...")
+	m.reloadArgs()
+	if err := m.startChild(false); err != nil {
+		done <- err
+		if errors.Is(err, errInterrupted) {
+			return true, m.shutdown(false)
+		}
+		logger.Error("Failed to start stopped child: %v", err)
+		return true, m.abortStartup(err)
+	}
+
+	m.childStopped.Store(false)
+	m.ready.Store(true)
+	m.persistFingerprint()
+	m.resetIdleTimer()
+	logger.Info("Child process started on request")
+	done <- nil
+	return false, nil
+}
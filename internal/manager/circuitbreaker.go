@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// CircuitBreaker stops acting on config changes after repeated failed
+// reloads, so a broken config pushed again and again does not turn into a
+// restart storm. It needs RollbackOnFailedReload, which keeps a healthy
+// child running after a failed reload. A zero value disables it
+type CircuitBreaker struct {
+	// Failures is the number of failed reloads in a row that opens the circuit
+	Failures int
+
+	// Cooldown is how long config changes are ignored once the circuit is
+	// open (default 5m)
+	Cooldown time.Duration
+}
+
+// defaultCircuitCooldown is used when CircuitBreaker.Cooldown is not set
+const defaultCircuitCooldown = 5 * time.Minute
+
+func (b CircuitBreaker) enabled() bool {
+	return b.Failures > 0
+}
+
+// reloadFailed counts a reload that failed and was rolled back, opening the
+// circuit once there were CircuitBreaker.Failures of them in a row. After
+// the cooldown, a single further failure opens it again
+func (m *Manager) reloadFailed() {
+	breaker := m.config.ReloadCircuitBreaker
+	if !breaker.enabled() {
+		return
+	}
+	m.reloadFailures++
+	if m.reloadFailures < breaker.Failures {
+		logger.Warn("Reload failed %d time(s) in a row, the reload circuit opens after %d", m.reloadFailures, breaker.Failures)
+		return
+	}
+
+	until := time.Now().Add(breaker.Cooldown)
+	m.circuitOpenUntil.Store(&until)
+	m.circuitTimer = time.NewTimer(breaker.Cooldown)
+	reloadCircuitOpenGauge.Set(1)
+	logger.Error("Reload failed %d times in a row, reload circuit open: ignoring config changes until %s, the child keeps running with the last known-good config",
+		m.reloadFailures, until.Format(time.DateTime))
+}
+
+// reloadSucceeded resets the failure count after a child started healthy
+// with the current config, closing the circuit if it was open
+func (m *Manager) reloadSucceeded() {
+	m.reloadFailures = 0
+	if m.circuitOpenUntil.Load() == nil {
+		return
+	}
+	m.closeCircuit()
+	m.circuitPending = false
+	logger.Info("Reload succeeded, reload circuit closed")
+}
+
+// circuitOpen reports whether config changes are to be ignored because the
+// reload circuit is open, remembering the change for when it closes
+func (m *Manager) circuitOpen() bool {
+	until := m.circuitOpenUntil.Load()
+	if until == nil {
+		return false
+	}
+	m.circuitPending = true
+	logger.Warn("Reload circuit open until %s, ignoring config change", until.Format(time.DateTime))
+	return true
+}
+
+// circuitCooledDown fires once the open reload circuit's cooldown expired
+func (m *Manager) circuitCooledDown() <-chan time.Time {
+	if m.circuitTimer == nil {
+		return nil
+	}
+	return m.circuitTimer.C
+}
+
+// halfOpenCircuit closes the circuit after the cooldown and applies the
+// config changes ignored meanwhile. It reports whether the run loop has to
+// return, and with which error
+func (m *Manager) halfOpenCircuit() (bool, error) {
+	m.closeCircuit()
+	pending := m.circuitPending
+	m.circuitPending = false
+	if !pending {
+		logger.Info("Reload circuit cooldown expired, acting on config changes again")
+		return false, nil
+	}
+	logger.Info("Reload circuit cooldown expired, applying the config changes made meanwhile")
+	return m.onConfigChange()
+}
+
+// closeCircuit marks the reload circuit closed
+func (m *Manager) closeCircuit() {
+	m.circuitOpenUntil.Store(nil)
+	if m.circuitTimer != nil {
+		m.circuitTimer.Stop()
+		m.circuitTimer = nil
+	}
+	reloadCircuitOpenGauge.Set(0)
+}
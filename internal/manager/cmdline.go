@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseCommandLine splits a full command line into the command and its
+// arguments, following the POSIX shell's word splitting and quoting rules:
+//
+//   - Unquoted spaces, tabs and newlines separate words
+//   - 'single quotes' keep everything literal, including backslashes
+//   - "double quotes" keep everything literal except \", \\, \$ and \`,
+//     which stand for the escaped character; other backslashes are kept
+//   - An unquoted backslash escapes the next character
+//   - A backslash-newline, quoted with double quotes or not, is removed
+//   - Adjacent quoted and unquoted parts form one word, so a"b c"d is "ab cd",
+//     and empty quotes are an empty argument
+//
+// Nothing is expanded: $VAR, globs, pipes and redirections are plain text.
+// Use -shell for those
+func ParseCommandLine(s string) (string, []string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false // a word was started, possibly empty as in ""
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case c == '\\':
+			if i+1 == len(s) {
+				return "", nil, errors.New("command line ends with a backslash")
+			}
+			i++
+			if s[i] != '\n' {
+				word.WriteByte(s[i])
+				inWord = true
+			}
+
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated single quote at offset %d", i)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+
+		case c == '"':
+			start := i
+			for i++; ; i++ {
+				if i == len(s) {
+					return "", nil, fmt.Errorf("unterminated double quote at offset %d", start)
+				}
+				if s[i] == '"' {
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			inWord = true
+
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	if len(words) == 0 {
+		return "", nil, errors.New("command line is empty")
+	}
+	if words[0] == "" {
+		return "", nil, errors.New("command must not be empty")
+	}
+	return words[0], words[1:], nil
+}
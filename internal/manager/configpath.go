@@ -0,0 +1,158 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/watcher"
+)
+
+// configWatcher returns the current config file watcher
+func (m *Manager) configWatcher() watcher.FileWatcher {
+	m.watcherMu.Lock()
+	defer m.watcherMu.Unlock()
+	return m.fileWatcher
+}
+
+// configPath returns the path of the watched config file
+func (m *Manager) configPath() string {
+	m.watcherMu.Lock()
+	defer m.watcherMu.Unlock()
+	return m.config.ConfigFilePath
+}
+
+// startConfigWatcher starts the current config file watcher from Run
+func (m *Manager) startConfigWatcher() error {
+	m.watcherMu.Lock()
+	defer m.watcherMu.Unlock()
+
+	ctx, cancel := context.WithCancel(m.watchCtx)
+	if err := m.fileWatcher.Start(ctx); err != nil {
+		cancel()
+		return err
+	}
+	m.watching = true
+	m.stopWatcher = cancel
+
+	if m.config.ConfigFilePath != "" {
+		logger.Info("Watching config file: %s", m.config.ConfigFilePath)
+		if m.config.NoRestartOnConfig {
+			logger.Warn("Restarts on config change are disabled, changes will only be logged")
+		}
+	}
+	return nil
+}
+
+// watcherOptions returns the config file watcher options from config
+func watcherOptions(config Config) watcher.Options {
+	return watcher.Options{
+		SelfTest:          config.WatcherSelfTest,
+		SymlinkFollowMode: config.SymlinkFollowMode,
+		Mode:              config.WatchMode,
+		Strategies:        config.WatchStrategies,
+		PollInterval:      config.PollInterval,
+		HoldOpen:          config.WatcherHoldOpen,
+		Settle:            config.WatcherSettle,
+		StabilityWindow:   config.WatcherStabilityWindow,
+		DataMarker:        config.WatcherDataMarker,
+		WatchMetadata:     config.WatchMetadata,
+		MaxConfigSize:     config.MaxConfigSize,
+		RejectOversized:   config.RejectOversizedConfig,
+		Trigger:           config.ChangeTrigger,
+	}
+}
+
+// newConfigWatcher creates the config file watcher. In auto watch mode, or
+// with watch strategies that include polling, it falls back to polling when
+// the inotify limits are exhausted or the filesystem does not support it
+func newConfigWatcher(path string, config Config) (watcher.FileWatcher, error) {
+	opts := watcherOptions(config)
+	fw, err := watcher.NewFileWatcherWithOptions(path, opts)
+	if err != nil && (errors.Is(err, watcher.ErrWatchLimit) || errors.Is(err, watcher.ErrWatchUnsupported)) && canFallBackToPolling(opts) {
+		if errors.Is(err, watcher.ErrWatchLimit) {
+			logger.Warn("Cannot use fsnotify for %s (%v), falling back to polling", path, err)
+		} else {
+			logger.Info("Cannot use fsnotify for %s on a %s filesystem (%v), falling back to polling",
+				path, watcher.FilesystemType(filepath.Dir(path)), err)
+		}
+		opts.Mode = watcher.WatchPoll
+		opts.Strategies = slices.DeleteFunc(slices.Clone(opts.Strategies), func(s watcher.Strategy) bool {
+			return s == watcher.StrategyFsnotify
+		})
+		fw, err = watcher.NewFileWatcherWithOptions(path, opts)
+	}
+	return fw, err
+}
+
+// canFallBackToPolling reports whether opts still detect changes without
+// fsnotify
+func canFallBackToPolling(opts watcher.Options) bool {
+	if len(opts.Strategies) > 0 {
+		return slices.Contains(opts.Strategies, watcher.StrategyPollStat) ||
+			slices.Contains(opts.Strategies, watcher.StrategyPollHash)
+	}
+	return opts.Mode == watcher.WatchAuto
+}
+
+// SetConfigPath switches the watched config file to path without touching the
+// running child. If no file existed at the old path but one exists at the new
+// path, the child is restarted to pick it up
+func (m *Manager) SetConfigPath(path string) error {
+	m.watcherMu.Lock()
+	defer m.watcherMu.Unlock()
+
+	if m.watchCtx.Err() != nil {
+		return errManagerShutDown
+	}
+	if m.config.RequireConfigFile {
+		if err := checkConfigFile(path); err != nil {
+			return err
+		}
+	}
+
+	fw, err := newConfigWatcher(path, m.config)
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	// Each watcher gets its own context so the old one's polling stops too
+	stop := func() {}
+	if m.watching {
+		ctx, cancel := context.WithCancel(m.watchCtx)
+		if err := fw.Start(ctx); err != nil {
+			cancel()
+			fw.Close()
+			return fmt.Errorf("failed to start file watcher: %w", err)
+		}
+		stop = cancel
+	}
+
+	// A no-op watcher (missing file) has no changes channel
+	appeared := m.fileWatcher.Changes() == nil && fw.Changes() != nil
+
+	old := m.fileWatcher
+	if m.stopWatcher != nil {
+		m.stopWatcher()
+	}
+	m.fileWatcher = fw
+	m.stopWatcher = stop
+	m.config.ConfigFilePath = path
+	if err := old.Close(); err != nil {
+		logger.Error("Error closing previous file watcher: %v", err)
+	}
+	logger.Info("Now watching config file: %s", path)
+
+	// Wake the run loop so it waits on the new watcher, keeping any
+	// still-pending "appeared" notification
+	select {
+	case pending := <-m.watcherSwapped:
+		appeared = appeared || pending
+	default:
+	}
+	m.watcherSwapped <- appeared
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// drainCallback serves POST /drained on DrainCallbackAddr. A child that
+// finished flushing after the stop signal calls it, so the manager stops it
+// right away instead of waiting for it to exit or for the stop timeout
+type drainCallback struct {
+	listener net.Listener
+	server   *http.Server
+	done     chan struct{}
+}
+
+// startDrainCallback listens on addr, host:port for TCP or unix:PATH for a
+// Unix socket, and calls drained on every POST /drained
+func startDrainCallback(addr string, drained func() bool) (*drainCallback, error) {
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", path
+		// A socket file left behind by a previous run would fail the listen
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /drained", func(w http.ResponseWriter, r *http.Request) {
+		if !drained() {
+			http.Error(w, "no stop in progress", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "stopping child")
+	})
+
+	d := &drainCallback{listener: listener, server: &http.Server{Handler: mux}, done: make(chan struct{})}
+	logger.Info("Drain callback listening on %s", listener.Addr())
+	go func() {
+		defer close(d.done)
+		if err := d.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Drain callback server error: %v", err)
+		}
+	}()
+	return d, nil
+}
+
+// Addr returns the address the callback listens on
+func (d *drainCallback) Addr() string {
+	return d.listener.Addr().String()
+}
+
+// Close stops serving, once the child is stopped and cannot call anymore
+func (d *drainCallback) Close() {
+	if err := d.server.Close(); err != nil {
+		logger.Error("Error closing drain callback: %v", err)
+	}
+	<-d.done
+}
+
+// childDrained is called by the drain callback; it lets a stop in progress
+// proceed without waiting for the child to exit
+func (m *Manager) childDrained() bool {
+	if !m.processManager.Drained() {
+		logger.Warn("Drain callback called while no stop is in progress, ignoring it")
+		return false
+	}
+	logger.Info("Child reported it finished draining")
+	return true
+}
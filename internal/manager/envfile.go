@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readEnvFile reads KEY=VALUE lines from a dotenv-style file
+// Blank lines and lines starting with # are skipped and an optional "export "
+// prefix is allowed. Values are trimmed and may be quoted like args file lines:
+// "double quotes" support Go escapes, 'single quotes' are literal
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, err := parseEnvLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		env = append(env, key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file %s: %w", path, err)
+	}
+
+	return env, nil
+}
+
+// parseEnvLine splits an env file line into its key and unquoted value
+func parseEnvLine(line string) (string, string, error) {
+	line = strings.TrimPrefix(line, "export ")
+
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected KEY=VALUE, got %q", line)
+	}
+
+	key = strings.TrimSpace(key)
+	if !isEnvKey(key) {
+		return "", "", fmt.Errorf("invalid variable name %q", key)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return key, "", nil
+	}
+	value, err := unquoteArg(value)
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// isEnvKey reports whether key is a valid shell variable name
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
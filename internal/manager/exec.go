@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// Exec replaces the current process with the child, with its args and env
+// files applied, for callers that have nothing to watch and want no
+// supervisor left in the process tree. Nothing else in config applies, as
+// no manager remains to act on it. Exec only returns on failure
+func Exec(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath(config.Command)
+	if err != nil {
+		return fmt.Errorf("failed to resolve command: %w", err)
+	}
+	args, err := childArgs(config)
+	if err != nil {
+		return err
+	}
+	env := os.Environ()
+	if config.EnvFile != "" {
+		extra, err := readEnvFile(config.EnvFile)
+		if err != nil {
+			return err
+		}
+		env = append(env, extra...)
+	}
+
+	logger.Info("Executing %s %v in place of flush-manager", path, args)
+	if err := syscall.Exec(path, append([]string{config.Command}, args...), env); err != nil {
+		return fmt.Errorf("failed to exec %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/process"
+)
+
+// defaultExitHistorySize is how many child exits are kept by default
+const defaultExitHistorySize = 10
+
+// ExitRecord describes one past exit of the child
+type ExitRecord struct {
+	// Time is when the child exited
+	Time time.Time
+
+	// Reason tells a restart by the manager apart from the child exiting
+	// on its own
+	Reason process.ExitReason
+
+	// Status is the exit code or signal
+	Status process.ExitStatus
+
+	// Uptime is how long the child ran before it exited
+	Uptime time.Duration
+}
+
+// String describes the exit on one line
+func (r ExitRecord) String() string {
+	return fmt.Sprintf("%s %s: %v after %v", r.Time.Format(time.RFC3339), r.Reason, r.Status, r.Uptime.Round(time.Millisecond))
+}
+
+// exitHistory keeps the most recent child exits in a ring buffer
+type exitHistory struct {
+	mu      sync.Mutex
+	records []ExitRecord
+	next    int
+	full    bool
+}
+
+func newExitHistory(size int) *exitHistory {
+	if size <= 0 {
+		size = defaultExitHistorySize
+	}
+	return &exitHistory{records: make([]ExitRecord, size)}
+}
+
+// add records exit, overwriting the oldest record once the buffer is full
+func (h *exitHistory) add(exit process.Exit) {
+	if exit.StartedAt.IsZero() {
+		// Not an exit of a started child
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records[h.next] = ExitRecord{
+		Time:   exit.ExitedAt,
+		Reason: exit.Reason,
+		Status: exit.Status,
+		Uptime: exit.Uptime(),
+	}
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the recorded exits, oldest first
+func (h *exitHistory) list() []ExitRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]ExitRecord(nil), h.records[:h.next]...)
+	}
+	return append(append([]ExitRecord(nil), h.records[h.next:]...), h.records[:h.next]...)
+}
+
+// handleExits serves the exit history, oldest first, one exit per line
+func (m *Manager) handleExits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	records := m.exitHistory.list()
+	if len(records) == 0 {
+		fmt.Fprintln(w, "no exits recorded")
+		return
+	}
+	for _, record := range records {
+		fmt.Fprintln(w, record)
+	}
+}
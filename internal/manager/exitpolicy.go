@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/process"
+)
+
+// ExitAction selects what happens when the child exits on its own
+type ExitAction int
+
+const (
+	// ExitShutdown shuts the manager down along with the child
+	ExitShutdown ExitAction = iota
+	// ExitRestart starts the child again, subject to RestartPolicy and
+	// StartLimit
+	ExitRestart
+	// ExitRestartWithBackoff starts the child again after a RestartPolicy
+	// backoff delay, which grows while the child keeps exiting soon after
+	// being started
+	ExitRestartWithBackoff
+)
+
+// ExitHandler decides how to react to the child exiting on its own, for
+// embeddings that need supervision logic beyond exit code lists. code is the
+// exit code, or -1 if the child was killed by a signal; err is the error
+// returned by waiting for it, nil for a clean exit
+type ExitHandler func(reason process.ExitReason, code int, err error) ExitAction
+
+func (a ExitAction) String() string {
+	switch a {
+	case ExitShutdown:
+		return "shutdown"
+	case ExitRestart:
+		return "restart"
+	case ExitRestartWithBackoff:
+		return "restart-with-backoff"
+	default:
+		return fmt.Sprintf("ExitAction(%d)", int(a))
+	}
+}
+
+// ParseExitAction parses an action name as returned by String
+func ParseExitAction(name string) (ExitAction, error) {
+	for _, a := range []ExitAction{ExitShutdown, ExitRestart, ExitRestartWithBackoff} {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown exit action %q (expected shutdown, restart or restart-with-backoff)", name)
+}
+
+// exitAction decides how to react to the child exit result with status.
+// ExitHandler decides if set. Otherwise listed exit codes take precedence;
+// other abnormal exits use DefaultExitAction, and a clean exit shuts down
+// unless 0 is listed
+func (m *Manager) exitAction(result exitResult, status process.ExitStatus) ExitAction {
+	if m.config.ExitHandler != nil {
+		code := -1
+		if status.Kind == process.ExitedWithCode {
+			code = status.Code
+		}
+		action := m.config.ExitHandler(result.reason, code, result.err)
+		logger.Debug("Exit handler chose %v for child that %v", action, status)
+		return action
+	}
+
+	if status.Kind == process.ExitedWithCode {
+		switch {
+		case slices.Contains(m.config.RestartOnExitCodes, status.Code):
+			return ExitRestart
+		case slices.Contains(m.config.FatalExitCodes, status.Code):
+			return ExitShutdown
+		case status.Code == 0:
+			return ExitShutdown
+		}
+	}
+	return m.config.DefaultExitAction
+}
+
+// restartAfterExit starts the child again after it exited on its own,
+// waiting for a backoff delay first if requested. It reports whether the run
+// loop has to return, and with which error
+func (m *Manager) restartAfterExit(result exitResult, status process.ExitStatus, backoff bool) (bool, error) {
+	exitRestartsTotal.Inc()
+	m.ready.Store(false)
+	if backoff {
+		if err := m.exitBackoff(result.uptime, status); err != nil {
+			return true, m.shutdown(false)
+		}
+	} else {
+		m.exitBackoffs = 0
+		logger.Warn("Child process %v, starting it again...", status)
+	}
+	m.reloadArgs()
+	if err := m.startChild(false); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return true, m.shutdown(false)
+		}
+		logger.Error("Failed to start child after it exited: %v", err)
+		return true, m.abortStartup(err)
+	}
+	m.ready.Store(true)
+	m.persistFingerprint()
+	logger.Info("Child process started again after exit")
+	m.notifyWebhook(webhookRestart, fmt.Sprintf("child process %v", status))
+	return false, nil
+}
+
+// exitBackoff waits before starting the child again after it exited after
+// running for uptime. The delay grows with each exit in a row, and starts
+// over once the child ran longer than the maximum backoff. It returns
+// errInterrupted if the manager is stopped meanwhile
+func (m *Manager) exitBackoff(uptime time.Duration, status process.ExitStatus) error {
+	policy := m.config.RestartPolicy
+	if uptime > policy.backoff(math.MaxInt32) {
+		m.exitBackoffs = 0
+	}
+	m.exitBackoffs++
+	delay := policy.backoff(m.exitBackoffs)
+	logger.Warn("Child process %v after %v, starting it again in %v...", status, uptime, delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case sig := <-m.sigChan:
+		logger.Info("Received signal: %v during exit backoff", sig)
+		m.shutdownSignal(sig)
+		return errInterrupted
+	case <-m.ctx.Done():
+		return errInterrupted
+	}
+}
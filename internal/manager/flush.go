@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// defaultFlushTimeout bounds each flush command attempt by default
+const defaultFlushTimeout = 30 * time.Second
+
+// flushRetryDelay is the pause between flush command attempts
+const flushRetryDelay = time.Second
+
+// errFlushFailed is returned by startChild when FlushCommand failed with
+// FlushRequired, so the restart was aborted and the child left running
+var errFlushFailed = errors.New("flush command failed")
+
+// runFlushCommand runs FlushCommand before the running child is stopped for
+// a restart, so it can flush and sync its state. Each attempt is bounded by
+// FlushTimeout, and failed ones are retried FlushRetries times. It returns an
+// error only if all attempts failed and FlushRequired is set
+func (m *Manager) runFlushCommand() error {
+	command := m.config.FlushCommand
+	if len(command) == 0 {
+		return nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= m.config.FlushRetries+1; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(flushRetryDelay):
+			case <-m.opCtx().Done():
+				return errInterrupted
+			}
+		}
+
+		logger.Info("Running flush command (attempt %d/%d): %v", attempt, m.config.FlushRetries+1, command)
+		var elapsed time.Duration
+		elapsed, err = m.runLogged(command, m.config.FlushTimeout, "flush")
+		if err == nil {
+			logger.Info("Flush command succeeded in %v", elapsed)
+			return nil
+		}
+		flushFailuresTotal.Inc()
+		logger.Warn("Flush command failed after %v: %v", elapsed, err)
+	}
+
+	if !m.config.FlushRequired {
+		logger.Warn("Flush command failed %d times, restarting anyway", m.config.FlushRetries+1)
+		return nil
+	}
+	logger.Error("Flush command failed %d times, aborting the restart", m.config.FlushRetries+1)
+	return fmt.Errorf("%w: %w", errFlushFailed, err)
+}
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// defaultHeartbeatInterval is how often the heartbeat file is touched by
+// default
+const defaultHeartbeatInterval = 10 * time.Second
+
+// startHeartbeat touches HeartbeatFile once and returns the channel on which
+// the run loop touches it again, nil if no heartbeat file is set
+func (m *Manager) startHeartbeat() (<-chan time.Time, func()) {
+	if m.config.HeartbeatFile == "" {
+		return nil, func() {}
+	}
+
+	logger.Info("Touching heartbeat file %s every %v", m.config.HeartbeatFile, m.config.HeartbeatInterval)
+	m.heartbeat()
+	ticker := time.NewTicker(m.config.HeartbeatInterval)
+	return ticker.C, ticker.Stop
+}
+
+// heartbeat touches HeartbeatFile if the child is running. Since the run
+// loop does it, a wedged run loop or a stopped child leaves the file stale
+func (m *Manager) heartbeat() {
+	if m.idle.Load() || m.childStopped.Load() || m.processManager.PID() == 0 {
+		logger.Debug("Child not running, skipping heartbeat")
+		return
+	}
+	if err := touchFile(m.config.HeartbeatFile, time.Now()); err != nil {
+		logger.Error("Failed to touch heartbeat file: %v", err)
+	}
+}
+
+// touchFile sets the modification time of path to t, creating it if needed
+func touchFile(path string, t time.Time) error {
+	err := os.Chtimes(path, t, t)
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return os.Chtimes(path, t, t)
+}
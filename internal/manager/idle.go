@@ -0,0 +1,78 @@
+package manager
+
+import (
+	"errors"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// startIdleTimer arms the idle timer if IdleTimeout is set. It returns the
+// channel the run loop waits on, nil if idle stopping is disabled
+func (m *Manager) startIdleTimer() <-chan time.Time {
+	if m.config.IdleTimeout <= 0 {
+		return nil
+	}
+	m.idleTimer = time.NewTimer(m.config.IdleTimeout)
+	return m.idleTimer.C
+}
+
+// resetIdleTimer restarts the idle window after a config change
+func (m *Manager) resetIdleTimer() {
+	if m.idleTimer != nil {
+		m.idleTimer.Reset(m.config.IdleTimeout)
+	}
+}
+
+// idleStop stops the child after IdleTimeout passed without a config change,
+// or shuts the manager down with IdleExit. It reports whether the run loop
+// has to return, and with which error
+func (m *Manager) idleStop() (bool, error) {
+	if m.config.IdleExit {
+		logger.Info("No config change for %v, shutting down", m.config.IdleTimeout)
+		m.setShutdownReason(ShutdownReason{Cause: ShutdownIdle})
+		return true, m.shutdown(false)
+	}
+	if m.childStopped.Load() {
+		// Already stopped by StopChild, which StartChild has to undo
+		return false, nil
+	}
+
+	logger.Info("No config change for %v, stopping child until the next one", m.config.IdleTimeout)
+	m.ready.Store(false)
+	if m.waitForDrain() {
+		logger.Info("Idle stop interrupted by signal, shutting down...")
+		return true, m.shutdown(false)
+	}
+
+	m.idle.Store(true)
+	if err := m.stopChild("idle"); err != nil {
+		logger.Error("Failed to stop idle child: %v", err)
+		return true, m.abortStartup(err)
+	}
+	// Consume the exit so the run loop doesn't take it for a crash
+	<-m.exitChan
+	logger.Info("Child stopped, waiting for a config change")
+	return false, nil
+}
+
+// wake starts the idle-stopped child again after a config change. It
+// reports whether the run loop has to return, and with which error
+func (m *Manager) wake() (bool, error) {
+	logger.Info("Config change detected, starting idle-stopped child...")
+	m.reloadArgs()
+	if err := m.startChild(false); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return true, m.shutdown(false)
+		}
+		logger.Error("Failed to start idle-stopped child: %v", err)
+		return true, m.abortStartup(err)
+	}
+
+	m.idle.Store(false)
+	m.ready.Store(true)
+	m.persistFingerprint()
+	m.resetIdleTimer()
+	logger.Info("Child process started after idle stop")
+	return false, nil
+}
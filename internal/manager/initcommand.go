@@ -0,0 +1,31 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// defaultInitTimeout bounds the init command by default
+const defaultInitTimeout = time.Minute
+
+// runInitCommand runs InitCommand before a (re)start of the child, like an
+// init container. The child must not be started if it fails
+func (m *Manager) runInitCommand() error {
+	command := m.config.InitCommand
+	if len(command) == 0 {
+		return nil
+	}
+
+	logger.Info("Running init command: %v", command)
+	elapsed, err := m.runLogged(command, m.config.InitTimeout, "init")
+	if err != nil {
+		initCommandsTotal.With("failure").Inc()
+		logger.Error("Init command failed after %v: %v", elapsed, err)
+		return fmt.Errorf("init command failed: %w", err)
+	}
+	initCommandsTotal.With("success").Inc()
+	logger.Info("Init command succeeded in %v", elapsed)
+	return nil
+}
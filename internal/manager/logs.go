@@ -0,0 +1,13 @@
+package manager
+
+import "net/http"
+
+// logBufferSize is how much recent child output is kept for /logs
+const logBufferSize = 256 << 10
+
+// handleLogs serves the most recent output of the child, stdout and stderr
+// interleaved as written, oldest first
+func (m *Manager) handleLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(m.logs.Bytes())
+}
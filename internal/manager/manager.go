@@ -2,13 +2,21 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/zlrrr/flush-manager/internal/health"
 	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/metrics"
+	"github.com/zlrrr/flush-manager/internal/probe"
 	"github.com/zlrrr/flush-manager/internal/process"
 	"github.com/zlrrr/flush-manager/internal/watcher"
 )
@@ -18,152 +26,1275 @@ type Config struct {
 	Command        string
 	Args           []string
 	ConfigFilePath string
+
+	// RequireConfigFile makes New and SetConfigPath fail if the config file
+	// does not exist, instead of using a no-op watcher. It catches a
+	// mistyped path that would otherwise silently never reload
+	RequireConfigFile bool
+
+	// ChildStdout and ChildStderr route the child's output streams: a file
+	// path (appended to, reopened on ReopenSignal), or "inherit" (default),
+	// "logger" or "discard"
+	ChildStdout string
+	ChildStderr string
+
+	// ChildStdin is what the child reads as stdin: a file path, "inherit"
+	// (the manager's own stdin) or "config" (ConfigFilePath). Files are
+	// re-opened on every (re)start. Empty means /dev/null; ignored with
+	// AllocatePTY
+	ChildStdin string
+
+	// ChildStdoutRateLimit and ChildStderrRateLimit cap the lines per second
+	// of a stream routed to the logger; excess lines are dropped and counted.
+	// 0 means unlimited
+	ChildStdoutRateLimit float64
+	ChildStderrRateLimit float64
+
+	// TeeOutput also copies both output streams into an in-memory buffer of
+	// the most recent output, served on /logs, in addition to ChildStdout
+	// and ChildStderr
+	TeeOutput bool
+
+	// ForceKillSignal is sent to the child when it does not stop within
+	// ShutdownTimeout (default SIGKILL). It must be a terminating signal
+	ForceKillSignal syscall.Signal
+
+	// SigtermAction selects the reaction to SIGTERM (default
+	// SignalShutdown). With SignalReload, SIGTERM restarts the child like a
+	// config change and only SIGINT shuts the manager down
+	SigtermAction SignalAction
+
+	// ReopenSignal makes the manager reopen child output files, for logrotate
+	// (default SIGUSR2). It must not be a shutdown signal
+	ReopenSignal syscall.Signal
+
+	// DetectOOM checks the kernel log to confirm a suspected OOM kill of
+	// the child
+	DetectOOM bool
+
+	// MemoryRestartThreshold restarts the child gracefully once its resident
+	// set size exceeds this many bytes, a lightweight alternative to the OOM
+	// killer for processes that leak slowly (disabled if 0). Linux only
+	MemoryRestartThreshold int64
+
+	// MemoryCheckInterval is how often the child's memory is checked
+	// against MemoryRestartThreshold (default 10s)
+	MemoryCheckInterval time.Duration
+
+	// HeartbeatFile, if set, is touched every HeartbeatInterval while the
+	// run loop is responsive and the child running, for watchdogs that
+	// check a file's modification time. It goes stale once the manager or
+	// child is wedged, stopped or shutting down
+	HeartbeatFile string
+
+	// HeartbeatInterval is how often HeartbeatFile is touched (default 10s)
+	HeartbeatInterval time.Duration
+
+	// ConfigChecksumFile persists the fingerprint of the config the child
+	// runs with. If the config differs from it at startup, the child is
+	// restarted once, so a change made while the manager was down is not
+	// missed
+	ConfigChecksumFile string
+
+	// WatcherSelfTest checks on startup that file events are actually
+	// delivered, falling back to polling the config file if not
+	WatcherSelfTest bool
+
+	// SymlinkFollowMode selects how a symlinked config file is followed
+	// (default watcher.WatchGrandparent)
+	SymlinkFollowMode watcher.SymlinkFollowMode
+
+	// WatchMode selects fsnotify, polling or both to detect config changes
+	// (default watcher.WatchAuto)
+	WatchMode watcher.WatchMode
+
+	// WatchStrategies runs several change detection strategies together on
+	// the config file, overriding WatchMode; a change seen by more than one
+	// of them is reported once
+	WatchStrategies []watcher.Strategy
+
+	// PollInterval is how often the config file is polled (default 5s)
+	PollInterval time.Duration
+
+	// ChangeTrigger selects which config changes count: any (default),
+	// appends only or replacements only
+	ChangeTrigger watcher.ChangeTrigger
+
+	// WatcherHoldOpen keeps the config file open to detect atomic replaces
+	// by file identity rather than modification time
+	WatcherHoldOpen bool
+
+	// WatcherSettle reports a config change only once the config path
+	// resolves to a readable file that stopped changing, so a half-applied
+	// ConfigMap update does not trigger a reload
+	WatcherSettle bool
+
+	// WatcherStabilityWindow reports a config change only once the config
+	// file's size and modification time stayed the same for this long, so
+	// a half-written file does not trigger a reload (disabled if 0)
+	WatcherStabilityWindow time.Duration
+
+	// WatcherDataMarker also treats changes to an entry named data next to a
+	// symlinked config file as a ConfigMap update, not just ..data
+	WatcherDataMarker bool
+
+	// WatchMetadata also treats a change of the config file's permissions
+	// or ownership as a config change, even if its content is unchanged
+	WatchMetadata bool
+
+	// MaxConfigSize, if set, is the largest config file in bytes whose
+	// content is hashed; a larger one is compared by its stat instead
+	MaxConfigSize int64
+
+	// RejectOversizedConfig ignores config changes that leave the file
+	// larger than MaxConfigSize, instead of reloading
+	RejectOversizedConfig bool
+
+	// InitCommand runs to completion before every (re)start of the child and
+	// must exit 0, e.g. for migrations or waiting on a dependency. A failure
+	// counts as a failed start, retried according to RestartPolicy
+	InitCommand []string
+
+	// InitTimeout bounds InitCommand (default 1m)
+	InitTimeout time.Duration
+
+	// FlushCommand runs before the running child is stopped for a restart,
+	// e.g. to make it flush and sync its state. It does not run when the
+	// child is stopped for shutdown or has exited on its own
+	FlushCommand []string
+
+	// FlushTimeout bounds each attempt of FlushCommand (default 30s)
+	FlushTimeout time.Duration
+
+	// FlushRetries is how many times a failed FlushCommand is retried
+	FlushRetries int
+
+	// FlushRequired aborts the restart, leaving the child running, when all
+	// attempts of FlushCommand failed. Otherwise the restart goes ahead
+	FlushRequired bool
+
+	// EnvFile is a dotenv-style file of KEY=VALUE lines added to the child's
+	// environment. Like ArgsFile, it is re-read on every config-triggered
+	// restart
+	EnvFile string
+
+	// OnChange selects the reaction to a config change (default ChangeAuto)
+	OnChange ChangeAction
+
+	// OnChangeCommand is run on each config change with ChangeCommand or
+	// ChangeCommandAndRestart, its output logged
+	OnChangeCommand []string
+
+	// OnChangeTimeout bounds OnChangeCommand (default 30s). The run loop
+	// waits for the command, so signals are handled once it finished
+	OnChangeTimeout time.Duration
+
+	// Watches are additional paths to watch, each with its own reaction to
+	// changes, for children that reload different files differently
+	Watches []WatchSpec
+
+	// WatchBinary restarts the child when its executable, resolved through
+	// PATH, changes on disk, for upgrades deployed by copying a new binary
+	WatchBinary bool
+
+	// RestartWindow defers restarts for config file and watched path
+	// changes until the window opens, applying all changes made meanwhile
+	// with one restart. Restarts after the child exited are not deferred
+	RestartWindow RestartWindow
+
+	// RollbackOnFailedReload caches the config file each time a child
+	// started with it passed its readiness probe. When a restart for a
+	// config change fails, the cached config is written back and the child
+	// started with it, instead of the manager giving up
+	RollbackOnFailedReload bool
+
+	// ReloadCircuitBreaker stops acting on config changes for a cooldown
+	// after repeated failed reloads, see CircuitBreaker
+	ReloadCircuitBreaker CircuitBreaker
+
+	// OnTransition, if set, is called with every lifecycle state change of
+	// the child, such as for a test to assert on the sequence of states. It
+	// is called from the run loop and must not block
+	OnTransition func(Transition)
+
+	// ChangeSources are custom change detection backends, such as an HTTP
+	// endpoint or a message bus. Their events are handled like changes of
+	// ConfigFilePath. The manager starts them in Run and closes them on
+	// shutdown
+	ChangeSources []watcher.ChangeSource
+
+	// NoRestartOnConfig keeps watching ConfigFilePath but only logs and counts
+	// changes instead of restarting the child
+	NoRestartOnConfig bool
+
+	// ArgsFile is read for child arguments, one per line, which come before
+	// Args. It is re-read on every config-triggered restart
+	ArgsFile string
+
+	// ListenSockets are addresses the manager binds once, host:port for TCP
+	// or unix:PATH for a Unix socket, and passes to every child following
+	// systemd's socket activation convention: descriptors 3 onwards in
+	// order, with LISTEN_FDS and LISTEN_PID set. The child is started
+	// through /bin/sh to set LISTEN_PID. The sockets stay open across
+	// restarts, so no connection is refused while the child is replaced
+	ListenSockets []string
+
+	// HealthAddr is the listen address of the health server (e.g. ":8080")
+	// The health server is disabled when empty
+	HealthAddr string
+
+	// TLSCertFile and TLSKeyFile serve the health server over HTTPS
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TLSClientCAFile, if set, requires clients of the health server to
+	// present a certificate signed by this CA
+	TLSClientCAFile string
+
+	// AuthToken, if set, requires "Authorization: Bearer <token>" on the
+	// health server's endpoints
+	AuthToken string
+
+	// BasicAuthUser and BasicAuthPassword, if set, require HTTP basic auth
+	// on the health server's endpoints
+	BasicAuthUser     string
+	BasicAuthPassword string
+
+	// HealthNoAuth leaves /healthz and /ready unauthenticated for probes
+	HealthNoAuth bool
+
+	// WebhookURL, if set, receives a JSON POST whenever the child is
+	// restarted or a config change is applied, for audit and chatops
+	WebhookURL string
+
+	// WebhookSecret, if set, signs webhook payloads with HMAC-SHA256 in the
+	// WebhookSignatureHeader header so receivers can verify them
+	WebhookSecret string
+
+	// AllocatePTY runs the child attached to a pseudo-terminal
+	AllocatePTY bool
+
+	// ResolveCommand re-resolves and re-stats the command binary before
+	// every start so in-place upgrades are picked up and logged
+	ResolveCommand bool
+
+	// SignalGroup sends stop signals to the child's whole process group, so
+	// the processes of a shell command are stopped even if the shell does
+	// not forward signals
+	SignalGroup bool
+
+	// ConfigureCmd, if set, customizes the child's exec.Cmd on every start,
+	// see process.Options.ConfigureCmd for the fields the manager sets
+	ConfigureCmd func(*exec.Cmd)
+
+	// WatchSelf watches the manager's own executable and makes Run return
+	// ErrSelfUpdate, after stopping the child, when it is replaced on disk
+	WatchSelf bool
+
+	// ShutdownTimeout bounds how long the child gets to stop gracefully, on
+	// shutdown as well as on a restart, and how long the manager waits on a
+	// drain sentinel (default 10s)
+	ShutdownTimeout time.Duration
+
+	// OutputWaitDelay bounds how long the child's output is still forwarded
+	// after it exited, in case a process it started holds its stdout or
+	// stderr open, so its exit is always noticed (default 1s)
+	OutputWaitDelay time.Duration
+
+	// IdleTimeout stops the child once no config change occurred for this
+	// long, and starts it again on the next change. 0 disables it
+	IdleTimeout time.Duration
+
+	// IdleExit shuts the manager down on IdleTimeout instead of only
+	// stopping the child
+	IdleExit bool
+
+	// PostExitDelay keeps the manager around for this long after the child
+	// exited normally, e.g. so sidecar shutdown ordering or log shipping can
+	// complete. A signal ends it early
+	PostExitDelay time.Duration
+
+	// HTTPShutdownTimeout bounds how long in-flight health server requests
+	// may take to finish on shutdown (default 5s)
+	HTTPShutdownTimeout time.Duration
+
+	// DrainSentinel is a file that, while present, holds off stopping the
+	// child on shutdown or reload so an external flush can complete
+	DrainSentinel string
+
+	// DrainCallbackAddr, if set, is where the manager serves POST /drained,
+	// host:port or unix:PATH. A child that finished flushing after the stop
+	// signal calls it, so it is stopped right away instead of the manager
+	// waiting for it to exit, up to the stop timeout
+	DrainCallbackAddr string
+
+	// LameDuckPeriod is how long the manager keeps the child running after
+	// reporting not-ready on /ready, before stopping it during shutdown
+	LameDuckPeriod time.Duration
+
+	// ReadinessProbe, if set, must pass after every (re)start before the
+	// child is considered ready
+	ReadinessProbe probe.Probe
+
+	// ReadinessInterval is the time between readiness checks, and the
+	// timeout of each check (default 1s)
+	ReadinessInterval time.Duration
+
+	// ReadinessTimeout bounds how long a (re)started child may take to pass
+	// its readiness probe before the start counts as failed (default 30s)
+	ReadinessTimeout time.Duration
+
+	// ReadinessSuccessThreshold is how many consecutive passes of the
+	// readiness probe count as ready (default 1)
+	ReadinessSuccessThreshold int
+
+	// ReadinessFailureThreshold, if set, keeps running the readiness probe
+	// once the child is ready, and reports not-ready on /ready after this
+	// many consecutive failures, until the probe passes
+	// ReadinessSuccessThreshold times in a row again. The child is not
+	// restarted
+	ReadinessFailureThreshold int
+
+	// RestartPolicy controls retries of failed starts
+	RestartPolicy RestartPolicy
+
+	// StartLimit makes the manager give up if the child is (re)started too
+	// often, counting initial starts, retries and config-triggered restarts
+	StartLimit StartLimit
+
+	// MirrorChildSignal makes Run return a ChildSignaledError when the child
+	// was killed by a signal, so the caller can re-raise it and its parent
+	// sees the same cause of death instead of a normal exit
+	MirrorChildSignal bool
+
+	// RestartOnExitCodes are exit codes after which the child is started
+	// again, e.g. for transient errors. FatalExitCodes shut the manager
+	// down, e.g. for configuration errors. Other exit codes and deaths by
+	// signal use DefaultExitAction; a clean exit shuts down unless 0 is
+	// listed in RestartOnExitCodes
+	RestartOnExitCodes []int
+	FatalExitCodes     []int
+
+	// DefaultExitAction is the reaction to an abnormal exit not covered by
+	// RestartOnExitCodes or FatalExitCodes (default ExitShutdown)
+	DefaultExitAction ExitAction
+
+	// ExitHandler, if set, decides the reaction to every exit of the child
+	// on its own instead of the exit code lists and DefaultExitAction
+	ExitHandler ExitHandler
+
+	// MaxStartupTime bounds the whole startup: init command, child start,
+	// start retries and readiness probe. If the manager is not healthy in
+	// time, Run shuts down and returns ErrStartupTimeout (disabled if 0)
+	MaxStartupTime time.Duration
+
+	// ExitHistorySize is how many past child exits are kept for Status and
+	// the /exits endpoint (default 10)
+	ExitHistorySize int
 }
 
+// ErrSelfUpdate is returned by Run when the manager's own binary was updated
+// and the caller should re-exec it
+var ErrSelfUpdate = errors.New("flush-manager binary updated")
+
+// ChildSignaledError is returned by Run with MirrorChildSignal when the child
+// was killed by a signal, after the manager shut down, so the caller can die
+// by the same signal
+type ChildSignaledError struct {
+	Signal syscall.Signal
+}
+
+func (e *ChildSignaledError) Error() string {
+	return fmt.Sprintf("child killed by signal %d (%v)", int(e.Signal), e.Signal)
+}
+
+// selfUpdateMinUptime is the minimum time the manager runs before acting on a
+// self-update, so a binary that keeps changing cannot cause a re-exec loop
+const selfUpdateMinUptime = 30 * time.Second
+
+// defaultShutdownTimeout is used when Config.ShutdownTimeout is not set
+const defaultShutdownTimeout = 10 * time.Second
+
+// defaultHTTPShutdownTimeout is used when Config.HTTPShutdownTimeout is not set
+const defaultHTTPShutdownTimeout = 5 * time.Second
+
+// drainPollInterval is how often the drain sentinel is checked
+const drainPollInterval = 100 * time.Millisecond
+
+// Readiness defaults used when the corresponding Config fields are not set
+const (
+	defaultReadinessInterval = time.Second
+	defaultReadinessTimeout  = 30 * time.Second
+)
+
+// defaultReopenSignal reopens child output files when Config.ReopenSignal is not set
+const defaultReopenSignal = syscall.SIGUSR2
+
 // Manager is the main manager that coordinates process and file watching
 type Manager struct {
 	config         Config
 	processManager process.Manager
 	fileWatcher    watcher.FileWatcher
+	watcherMu      sync.Mutex
+	watching       bool
+	stopWatcher    context.CancelFunc
+	watcherSwapped chan bool
+	pathWatches    []*pathWatch
+	pathChanges    chan *pathWatch
+	sourceChanges  chan watcher.ChangeEvent
+	forwarders     sync.WaitGroup // goroutines feeding pathChanges and sourceChanges
+	selfWatcher    watcher.FileWatcher
+	healthServer   *health.Server
+	drainCallback  *drainCallback
+	prober         *probe.Prober
+	startLimiter   *startLimiter
+	exitHistory    *exitHistory
+	outputs        []*process.Output
+	logs           *process.RingBuffer // recent child output, with TeeOutput
+	listenSockets  []*listenSocket
+	exitChan       chan exitResult
+	started        chan struct{}
+	sigChan        chan os.Signal
+	ready          atomic.Bool
+	idle           atomic.Bool
+	idleTimer      *time.Timer
+	childStopped   atomic.Bool // stopped by StopChild until StartChild
+	spawnedAt      time.Time   // when the current child was spawned
+	dirty          bool        // the config changed after spawnedAt, during a restart
+	childControl   chan childRequest
+	paused         atomic.Bool
+	resumed        chan struct{}
+	startedAt      time.Time
+	minSelfUptime  time.Duration
+	skipDrain      bool
+	pendingReloads atomic.Int32
+	droppedReloads atomic.Uint64
+	lastExit       atomic.Pointer[process.ExitStatus]
+	shutdownReason atomic.Pointer[ShutdownReason]
+	exitBackoffs   int // exits in a row restarted with backoff
+	generation     atomic.Uint64
+	fingerprint    atomic.Pointer[string]
 	ctx            context.Context
 	cancel         context.CancelFunc
+	watchCtx       context.Context    // bounds watchers and change sources
+	stopWatching   context.CancelFunc // ends watchCtx, first thing on shutdown
+	startupCtx     context.Context    // bounds the startup by MaxStartupTime
+	endStartupCtx  context.CancelFunc
+
+	// Changes seen while watching was paused; only touched by the run loop
+	pausedConfigChange bool
+	pausedWatches      map[*pathWatch]bool
+
+	// A restart deferred until RestartWindow opens; only touched by the run loop
+	restartDeferred    bool
+	restartWindowTimer *time.Timer
+
+	// The config of the last healthy child, with RollbackOnFailedReload;
+	// only touched by the run loop
+	knownGood *knownGoodConfig
+
+	// Failed reloads in a row and the open reload circuit; only touched by
+	// the run loop, except circuitOpenUntil for Status
+	reloadFailures   int
+	circuitPending   bool // a config change was ignored while open
+	circuitTimer     *time.Timer
+	circuitOpenUntil atomic.Pointer[time.Time]
+
+	// The last reported lifecycle state; only touched by the run loop
+	state TransitionState
 }
 
 // New creates a new Manager instance
 func New(config Config) (*Manager, error) {
+	return NewWithContext(context.Background(), config)
+}
+
+// NewWithContext creates a new Manager whose lifetime is bound to ctx:
+// cancelling ctx shuts the manager down, skipping any lame-duck period
+func NewWithContext(parent context.Context, config Config) (*Manager, error) {
 	logger.Info("Initializing manager with command: %s", config.Command)
 
-	if config.Command == "" {
-		return nil, fmt.Errorf("command cannot be empty")
+	if err := config.Validate(); err != nil {
+		logger.Error("Invalid configuration: %v", err)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if config.HTTPShutdownTimeout <= 0 {
+		config.HTTPShutdownTimeout = defaultHTTPShutdownTimeout
+	}
+	if config.ReadinessInterval <= 0 {
+		config.ReadinessInterval = defaultReadinessInterval
+	}
+	if config.ReadinessTimeout <= 0 {
+		config.ReadinessTimeout = defaultReadinessTimeout
+	}
+	if config.ReopenSignal == 0 {
+		config.ReopenSignal = defaultReopenSignal
+	}
+	if config.ForceKillSignal == 0 {
+		config.ForceKillSignal = syscall.SIGKILL
+	}
+	if config.OnChange == ChangeAuto {
+		config.OnChange = ChangeRestart
+		if len(config.OnChangeCommand) > 0 {
+			config.OnChange = ChangeCommand
+		}
+	}
+	if config.InitTimeout <= 0 {
+		config.InitTimeout = defaultInitTimeout
+	}
+	if config.FlushTimeout <= 0 {
+		config.FlushTimeout = defaultFlushTimeout
+	}
+	if config.ReloadCircuitBreaker.enabled() && config.ReloadCircuitBreaker.Cooldown <= 0 {
+		config.ReloadCircuitBreaker.Cooldown = defaultCircuitCooldown
+	}
+	if config.OnChangeTimeout <= 0 {
+		config.OnChangeTimeout = defaultOnChangeTimeout
+	}
+	if config.MemoryCheckInterval <= 0 {
+		config.MemoryCheckInterval = defaultMemoryCheckInterval
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = defaultHeartbeatInterval
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+
+	args, err := childArgs(config)
+	if err != nil {
+		cancel()
+		logger.Error("Failed to read args file: %v", err)
+		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	var env []string
+	if config.EnvFile != "" {
+		if env, err = readEnvFile(config.EnvFile); err != nil {
+			cancel()
+			logger.Error("Failed to read env file: %v", err)
+			return nil, err
+		}
+	}
 
-	pm := process.NewManager(config.Command, config.Args)
+	stdout, stderr, err := childOutputs(config)
+	if err != nil {
+		cancel()
+		logger.Error("Failed to open child output: %v", err)
+		return nil, err
+	}
+
+	sockets, err := listenSockets(config.ListenSockets)
+	if err != nil {
+		cancel()
+		stdout.Close()
+		stderr.Close()
+		logger.Error("Failed to bind listen sockets: %v", err)
+		return nil, err
+	}
+
+	// Assigned below; the child's stdin follows SetConfigPath
+	var m *Manager
+	processOpts := process.Options{
+		AllocatePTY:     config.AllocatePTY,
+		ResolveCommand:  config.ResolveCommand,
+		SignalGroup:     config.SignalGroup,
+		Stdout:          stdout,
+		Stderr:          stderr,
+		ForceKillSignal: config.ForceKillSignal,
+		ListenFiles:     listenFiles(sockets),
+		ConfigureCmd:    config.ConfigureCmd,
+		WaitDelay:       config.OutputWaitDelay,
+		StopTimeout:     config.ShutdownTimeout,
+		BeforeStart:     func() { m.resetProbe() },
+		OpenStdin:       childStdin(config.ChildStdin, func() string { return m.configPath() }),
+	}
+	var logs *process.RingBuffer
+	if config.TeeOutput {
+		logs = process.NewRingBuffer(logBufferSize)
+		processOpts.Capture = logs
+	}
+	pm := process.NewManagerWithOptions(config.Command, args, processOpts)
+	pm.SetEnv(env)
 
 	// Create file watcher if config file is specified
-	fw, err := watcher.NewFileWatcher(config.ConfigFilePath)
+	fw, err := newConfigWatcher(config.ConfigFilePath, config)
 	if err != nil {
 		cancel()
+		stdout.Close()
+		stderr.Close()
+		closeListenSockets(sockets)
 		logger.Error("Failed to create file watcher: %v", err)
 		return nil, fmt.Errorf("failed to create file watcher: %w", err)
 	}
 
-	m := &Manager{
+	watches, err := newPathWatches(config)
+	if err != nil {
+		cancel()
+		fw.Close()
+		stdout.Close()
+		stderr.Close()
+		closeListenSockets(sockets)
+		logger.Error("Failed to create file watcher: %v", err)
+		return nil, err
+	}
+
+	// Watch our own executable if requested
+	sw, err := newSelfWatcher(config.WatchSelf)
+	if err != nil {
+		cancel()
+		fw.Close()
+		closePathWatches(watches)
+		stdout.Close()
+		stderr.Close()
+		closeListenSockets(sockets)
+		logger.Error("Failed to create self watcher: %v", err)
+		return nil, fmt.Errorf("failed to create self watcher: %w", err)
+	}
+
+	m = &Manager{
 		config:         config,
 		processManager: pm,
 		fileWatcher:    fw,
+		watcherSwapped: make(chan bool, 1),
+		pathWatches:    watches,
+		pathChanges:    make(chan *pathWatch),
+		sourceChanges:  make(chan watcher.ChangeEvent, 1),
+		resumed:        make(chan struct{}, 1),
+		pausedWatches:  make(map[*pathWatch]bool),
+		selfWatcher:    sw,
+		outputs:        []*process.Output{stdout, stderr},
+		logs:           logs,
+		listenSockets:  sockets,
+		minSelfUptime:  selfUpdateMinUptime,
+		startLimiter:   newStartLimiter(config.StartLimit),
+		exitHistory:    newExitHistory(config.ExitHistorySize),
+		exitChan:       make(chan exitResult, 1),
+		childControl:   make(chan childRequest),
+		started:        make(chan struct{}),
+		sigChan:        make(chan os.Signal, 1),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
+	m.watchCtx, m.stopWatching = context.WithCancel(ctx)
+
+	if config.ReadinessProbe != nil {
+		m.prober = probe.NewProber(config.ReadinessProbe, config.ReadinessInterval,
+			config.ReadinessSuccessThreshold, config.ReadinessFailureThreshold)
+	}
+
+	if config.HealthAddr != "" {
+		m.healthServer = health.NewServer(config.HealthAddr, m.isReady)
+		m.healthServer.SetDetail(m.readyDetail)
+		m.healthServer.Handle("/metrics", metrics.Default.Handler())
+		m.healthServer.Handle("/exits", http.HandlerFunc(m.handleExits))
+		m.healthServer.Handle("/debug/watches", http.HandlerFunc(m.handleWatches))
+		if logs != nil {
+			m.healthServer.Handle("/logs", http.HandlerFunc(m.handleLogs))
+		}
+		m.healthServer.Handle("POST /watching/pause", http.HandlerFunc(m.handlePause))
+		m.healthServer.Handle("POST /watching/resume", http.HandlerFunc(m.handleResume))
+		m.healthServer.SetAuth(health.Auth{
+			BearerToken:   config.AuthToken,
+			BasicUser:     config.BasicAuthUser,
+			BasicPassword: config.BasicAuthPassword,
+			ExemptProbes:  config.HealthNoAuth,
+		})
+
+		if config.TLSCertFile != "" || config.TLSKeyFile != "" || config.TLSClientCAFile != "" {
+			if err := m.healthServer.EnableTLS(config.TLSCertFile, config.TLSKeyFile, config.TLSClientCAFile); err != nil {
+				cancel()
+				fw.Close()
+				closePathWatches(watches)
+				sw.Close()
+				closeListenSockets(sockets)
+				logger.Error("Failed to configure TLS: %v", err)
+				return nil, fmt.Errorf("failed to configure TLS: %w", err)
+			}
+		}
+	}
 
 	logger.Info("Manager initialized successfully")
 	return m, nil
 }
 
-// Run starts the manager and blocks until it should exit
-func (m *Manager) Run() error {
+// Run starts the manager and blocks until it should exit. Errors are
+// returned as an *ExitError; ShutdownReason tells why Run returned either way
+func (m *Manager) Run() (err error) {
 	logger.Info("Starting manager run loop...")
+	m.startedAt = time.Now()
+	defer func() {
+		err = m.reportShutdown(err)
+	}()
 
 	// Setup signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	logger.Debug("Signal handlers registered for SIGINT and SIGTERM")
+	reloadSignals, stopSignals := m.notifySignals()
+	defer stopSignals()
+
+	// Reopen child output files on the reopen signal, for logrotate
+	reopenChan := make(chan os.Signal, 1)
+	signal.Notify(reopenChan, m.config.ReopenSignal)
+	defer signal.Stop(reopenChan)
+	go m.reopenOutputs(reopenChan)
+
+	m.beginStartup()
+	defer m.endStartup()
+
+	// Start health server
+	if m.healthServer != nil {
+		if err := m.healthServer.Start(); err != nil {
+			logger.Error("Failed to start health server: %v", err)
+			return m.abortStartup(fmt.Errorf("failed to start health server: %w", err))
+		}
+	}
+
+	if m.config.DrainCallbackAddr != "" {
+		callback, err := startDrainCallback(m.config.DrainCallbackAddr, m.childDrained)
+		if err != nil {
+			logger.Error("Failed to start drain callback: %v", err)
+			return m.abortStartup(fmt.Errorf("failed to start drain callback: %w", err))
+		}
+		m.drainCallback = callback
+	}
 
-	// Start the child process
-	if err := m.processManager.Start(m.ctx); err != nil {
+	// A signal may already have arrived; don't start a child we'd immediately stop
+	if m.signalledDuringStartup() {
+		return m.shutdown(false)
+	}
+
+	// Start the child process and wait for it to become ready
+	if err := m.startChild(false); err != nil {
+		if timeoutErr := m.startupTimeout(); timeoutErr != nil {
+			return m.abortStartup(timeoutErr)
+		}
+		if errors.Is(err, errInterrupted) {
+			return m.shutdown(false)
+		}
 		logger.Error("Failed to start child process: %v", err)
-		return fmt.Errorf("failed to start child process: %w", err)
+		return m.abortStartup(err)
 	}
 
 	logger.Info("Manager started, child process: %s", m.config.Command)
 
+	if m.signalledDuringStartup() {
+		return m.shutdown(false)
+	}
+
 	// Start file watcher
-	if err := m.fileWatcher.Start(m.ctx); err != nil {
+	if err := m.startConfigWatcher(); err != nil {
+		logger.Error("Failed to start file watcher: %v", err)
+		return m.abortStartup(fmt.Errorf("failed to start file watcher: %w", err))
+	}
+
+	if err := m.startPathWatches(); err != nil {
 		logger.Error("Failed to start file watcher: %v", err)
-		return fmt.Errorf("failed to start file watcher: %w", err)
+		return m.abortStartup(err)
 	}
-	if m.config.ConfigFilePath != "" {
-		logger.Info("Watching config file: %s", m.config.ConfigFilePath)
+
+	if err := m.startChangeSources(); err != nil {
+		logger.Error("Failed to start change source: %v", err)
+		return m.abortStartup(err)
 	}
 
-	// Monitor process exit in background
-	type exitResult struct {
-		reason process.ExitReason
-		err    error
+	if err := m.selfWatcher.Start(m.watchCtx); err != nil {
+		logger.Error("Failed to start self watcher: %v", err)
+		return m.abortStartup(fmt.Errorf("failed to start self watcher: %w", err))
+	}
+
+	if m.signalledDuringStartup() {
+		return m.shutdown(false)
+	}
+
+	m.endStartup()
+	m.ready.Store(true)
+	close(m.started)
+	m.monitorReadiness()
+
+	idleTimeout := m.startIdleTimer()
+	memoryCheck, stopMemoryCheck := m.startMemoryTicker()
+	defer stopMemoryCheck()
+	heartbeat, stopHeartbeat := m.startHeartbeat()
+	defer stopHeartbeat()
+
+	// Catch up on a config change that happened while the manager was down
+	if m.configChangedSinceLastRun() && m.configChanged() {
+		if done, err := m.onConfigChange(); done {
+			return err
+		}
+	} else {
+		m.persistFingerprint()
 	}
-	exitChan := make(chan exitResult, 1)
-	go func() {
-		reason, err := m.processManager.Wait()
-		exitChan <- exitResult{reason: reason, err: err}
-	}()
+
+	// Fires once a deferred self-update may proceed
+	var selfUpdateTimer <-chan time.Time
 
 	logger.Info("Entering main event loop")
 
 	// Main event loop
 	for {
 		select {
-		case sig := <-sigChan:
+		case sig := <-m.sigChan:
 			logger.Info("Received signal: %v, shutting down gracefully...", sig)
-			return m.shutdown()
+			m.shutdownSignal(sig)
+			return m.shutdown(true)
+
+		case event := <-m.configWatcher().Changes():
+			if event.Reason == watcher.ReasonMetadata {
+				logger.Info("Config file permissions or ownership changed, content unchanged")
+			}
+			if !m.configChanged() || m.deferWhilePaused(nil) {
+				continue
+			}
+			if done, err := m.onConfigChange(); done {
+				return err
+			}
+
+		case appeared := <-m.watcherSwapped:
+			// Pick up the new watcher's channel; restart if the config appeared
+			if !appeared {
+				continue
+			}
+			logger.Info("Config file appeared at new path")
+			if done, err := m.onConfigChange(); done {
+				return err
+			}
+
+		case event := <-m.sourceChanges:
+			logger.Info("Change reported by %s", describeEvent(event))
+			if !m.configChanged() || m.deferWhilePaused(nil) {
+				continue
+			}
+			if done, err := m.onConfigChange(); done {
+				return err
+			}
+
+		case sig := <-reloadSignals:
+			if done, err := m.onReloadSignal(sig); done {
+				return err
+			}
+
+		case w := <-m.pathChanges:
+			if m.deferWhilePaused(w) {
+				continue
+			}
+			if done, err := m.onPathChange(w); done {
+				return err
+			}
+
+		case <-m.resumed:
+			if done, err := m.applyPausedChanges(); done {
+				return err
+			}
+
+		case <-m.restartWindowOpened():
+			if done, err := m.applyDeferredRestart(); done {
+				return err
+			}
 
-		case <-m.fileWatcher.Changes():
-			logger.Info("Config file change detected, restarting child process...")
-			if err := m.processManager.Restart(m.ctx); err != nil {
-				logger.Error("Failed to restart process: %v", err)
+		case <-m.circuitCooledDown():
+			if done, err := m.halfOpenCircuit(); done {
 				return err
 			}
-			logger.Info("Child process restarted successfully after config change")
 
-			// Restart the exit monitor goroutine
-			go func() {
-				reason, err := m.processManager.Wait()
-				exitChan <- exitResult{reason: reason, err: err}
-			}()
+		case req := <-m.childControl:
+			if done, err := m.onChildControl(req); done {
+				return err
+			}
+
+		case <-m.selfWatcher.Changes():
+			if uptime := time.Since(m.startedAt); uptime < m.minSelfUptime {
+				if selfUpdateTimer == nil {
+					logger.Warn("flush-manager binary updated after only %v, deferring self-update to avoid a re-exec loop", uptime)
+					selfUpdateTimer = time.After(m.minSelfUptime - uptime)
+				}
+				continue
+			}
+			logger.Info("flush-manager binary updated, stopping child for self-update...")
+			return m.selfUpdate()
+
+		case <-idleTimeout:
+			if done, err := m.idleStop(); done {
+				return err
+			}
+
+		case <-memoryCheck:
+			if done, err := m.checkMemory(); done {
+				return err
+			}
 
-		case result := <-exitChan:
+		case <-heartbeat:
+			m.heartbeat()
+
+		case <-selfUpdateTimer:
+			logger.Info("Proceeding with deferred self-update, stopping child...")
+			return m.selfUpdate()
+
+		case result := <-m.exitChan:
 			// If process was restarted by us, continue
 			if result.reason == process.ExitReasonRestart {
 				logger.Debug("Process exit was due to restart, continuing...")
 				continue
 			}
 
-			// If process exited abnormally, manager should exit too
+			// Unless the exit policy restarts it, the manager exits with the child
+			status := result.status
+			m.transition(TransitionExited, status.String())
+			m.lastExit.Store(&status)
+			m.checkOOM(status)
+			if action := m.exitAction(result, status); action == ExitRestart || action == ExitRestartWithBackoff {
+				if done, err := m.restartAfterExit(result, status, action == ExitRestartWithBackoff); done {
+					return err
+				}
+				continue
+			}
+			m.setShutdownReason(ShutdownReason{Cause: ShutdownChildExited, Exit: &status})
 			if result.err != nil {
-				logger.Error("Child process exited with error: %v", result.err)
+				logger.Error("Child process exited with error: %v (%v)", result.err, status)
 			} else {
 				logger.Info("Child process exited normally")
+				m.postExitDelay()
+			}
+			if err := m.shutdown(false); err != nil {
+				return err
+			}
+			if m.config.MirrorChildSignal && status.Kind == process.Signaled {
+				return &ChildSignaledError{Signal: status.Signal}
 			}
-			return m.shutdown()
+			return nil
 
 		case <-m.ctx.Done():
 			logger.Debug("Context cancelled, shutting down...")
-			return m.shutdown()
+			m.setShutdownReason(ShutdownReason{Cause: ShutdownContextCancelled})
+			return m.shutdown(false)
 		}
 	}
 }
 
-// shutdown performs graceful shutdown
-func (m *Manager) shutdown() error {
+// shutdown performs graceful shutdown. lameDuck observes the lame-duck period
+// first, which only applies when a shutdown signal was received
+func (m *Manager) shutdown(lameDuck bool) error {
 	logger.Info("Shutting down manager...")
 
-	// Cancel context to stop watchers
-	m.cancel()
-	logger.Debug("Context cancelled")
+	// Report not-ready and keep the child serving while load balancers deregister
+	wasReady := m.ready.Swap(false)
+	if lameDuck && wasReady && m.config.LameDuckPeriod > 0 && m.ctx.Err() == nil {
+		m.lameDuck()
+	}
 
-	// Close file watcher
-	if err := m.fileWatcher.Close(); err != nil {
+	// Hold off stopping the child while an external flush is in progress
+	m.waitForDrain()
+	m.dropPendingReloads("manager shutting down")
+
+	// Stop the watchers; from here on changes are not acted on
+	m.stopWatching()
+	logger.Debug("Watch context cancelled")
+
+	// Close file watchers
+	if err := m.configWatcher().Close(); err != nil {
 		logger.Error("Error closing file watcher: %v", err)
 	} else {
 		logger.Debug("File watcher closed")
 	}
+	closePathWatches(m.pathWatches)
+	closeChangeSources(m.config.ChangeSources)
+	if err := m.selfWatcher.Close(); err != nil {
+		logger.Error("Error closing self watcher: %v", err)
+	}
+	m.discardChanges()
 
-	// Stop child process gracefully
-	if err := m.processManager.Stop(10 * time.Second); err != nil {
-		logger.Error("Error stopping child process: %v", err)
-		return err
+	// Stop child process gracefully. A failure is reported once the rest of
+	// the teardown ran, so nothing is left behind
+	stopErr := m.stopChild("manager shutting down")
+	if stopErr != nil {
+		logger.Error("Error stopping child process: %v", stopErr)
+	}
+	m.cancel()
+	logger.Debug("Context cancelled")
+
+	if m.drainCallback != nil {
+		m.drainCallback.Close()
+	}
+
+	for _, output := range m.outputs {
+		if err := output.Close(); err != nil {
+			logger.Error("Error closing child %s output: %v", output, err)
+		}
 	}
+	closeListenSockets(m.listenSockets)
 
+	// Stop the health server, letting in-flight requests finish, so the port
+	// is free by the time shutdown completes
+	if m.healthServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), m.config.HTTPShutdownTimeout)
+		if err := m.healthServer.Shutdown(ctx); err != nil {
+			logger.Error("Error shutting down health server: %v", err)
+		}
+		cancel()
+	}
+
+	if stopErr != nil {
+		return stopErr
+	}
 	logger.Info("Manager shutdown complete")
 	return nil
 }
+
+// selfUpdate shuts down and reports ErrSelfUpdate so the caller can re-exec
+func (m *Manager) selfUpdate() error {
+	if err := m.shutdown(false); err != nil {
+		return err
+	}
+	return ErrSelfUpdate
+}
+
+// newSelfWatcher creates a watcher on the manager's own executable, or a no-op
+// watcher if self-watching is disabled
+func newSelfWatcher(enabled bool) (watcher.FileWatcher, error) {
+	if !enabled {
+		return watcher.NewFileWatcher("")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate own executable: %w", err)
+	}
+	logger.Info("Watching own executable for updates: %s", exe)
+	return watcher.NewFileWatcher(exe)
+}
+
+// childOutputs opens the configured destinations of the child's output streams
+func childOutputs(config Config) (*process.Output, *process.Output, error) {
+	stdout, err := process.NewOutput(config.ChildStdout, "stdout")
+	if err != nil {
+		return nil, nil, err
+	}
+	stderr, err := process.NewOutput(config.ChildStderr, "stderr")
+	if err != nil {
+		stdout.Close()
+		return nil, nil, err
+	}
+	stdout.SetRateLimit(config.ChildStdoutRateLimit)
+	stderr.SetRateLimit(config.ChildStderrRateLimit)
+	return stdout, stderr, nil
+}
+
+// Child stdin sources besides a file path, see Config.ChildStdin
+const (
+	StdinInherit = "inherit"
+	StdinConfig  = "config"
+)
+
+// childStdin returns how to open the configured stdin of the child, or nil
+// for /dev/null. configPath returns the current config file path
+func childStdin(source string, configPath func() string) func() (*os.File, error) {
+	switch source {
+	case "":
+		return nil
+	case StdinInherit:
+		return func() (*os.File, error) { return os.Stdin, nil }
+	case StdinConfig:
+		return func() (*os.File, error) { return os.Open(configPath()) }
+	default:
+		return func() (*os.File, error) { return os.Open(source) }
+	}
+}
+
+// reopenOutputs reopens child output files on every reopen signal until the
+// manager shuts down
+func (m *Manager) reopenOutputs(reopenChan <-chan os.Signal) {
+	for {
+		select {
+		case sig := <-reopenChan:
+			logger.Info("Received signal: %v, reopening child output files", sig)
+			for _, output := range m.outputs {
+				if err := output.Reopen(); err != nil {
+					logger.Error("Failed to reopen child output: %v", err)
+				}
+			}
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// childArgs combines the args file, if any, with the configured args
+func childArgs(config Config) ([]string, error) {
+	if config.ArgsFile == "" {
+		return config.Args, nil
+	}
+
+	fileArgs, err := readArgsFile(config.ArgsFile)
+	if err != nil {
+		return nil, err
+	}
+	return append(fileArgs, config.Args...), nil
+}
+
+// reloadArgs re-reads the args and env files before a restart, keeping the
+// previous values if they can't be read
+func (m *Manager) reloadArgs() {
+	if m.config.EnvFile != "" {
+		if env, err := readEnvFile(m.config.EnvFile); err != nil {
+			logger.Error("Failed to re-read env file, keeping previous environment: %v", err)
+		} else {
+			logger.Info("Reloaded child environment from %s (%d variables)", m.config.EnvFile, len(env))
+			m.processManager.SetEnv(env)
+		}
+	}
+
+	if m.config.ArgsFile == "" {
+		return
+	}
+
+	args, err := childArgs(m.config)
+	if err != nil {
+		logger.Error("Failed to re-read args file, keeping previous args: %v", err)
+		return
+	}
+	logger.Info("Reloaded child args from %s: %v", m.config.ArgsFile, args)
+	m.processManager.SetArgs(args)
+}
+
+// monitorReadiness keeps running the readiness probe while the child is
+// ready, if ReadinessFailureThreshold is set
+func (m *Manager) monitorReadiness() {
+	if m.prober == nil || m.config.ReadinessFailureThreshold <= 0 {
+		return
+	}
+	go m.prober.Monitor(m.ctx, m.ready.Load)
+}
+
+// isReady reports whether /ready returns 200: the child is started and the
+// readiness probe, if monitored, is not failing
+func (m *Manager) isReady() bool {
+	if !m.ready.Load() {
+		return false
+	}
+	return m.prober == nil || m.config.ReadinessFailureThreshold <= 0 || !m.prober.Failing()
+}
+
+// readyDetail describes the last readiness probe result for the /ready body
+func (m *Manager) readyDetail() string {
+	if m.idle.Load() {
+		return "child stopped after idle timeout"
+	}
+	if m.childStopped.Load() {
+		return "child stopped by request"
+	}
+	if m.prober == nil {
+		return ""
+	}
+	if result := m.prober.LastResult(); result != nil {
+		return fmt.Sprintf("probe %s: %s", m.prober, result)
+	}
+	return fmt.Sprintf("probe %s: not run yet", m.prober)
+}
+
+// signalledDuringStartup reports whether a shutdown signal arrived while the
+// manager was still starting up, consuming it
+func (m *Manager) signalledDuringStartup() bool {
+	select {
+	case sig := <-m.sigChan:
+		logger.Info("Received signal: %v during startup, shutting down...", sig)
+		m.shutdownSignal(sig)
+		return true
+	default:
+		return false
+	}
+}
+
+// postExitDelay waits PostExitDelay after a normal child exit, reporting
+// not-ready meanwhile. A signal or context cancellation ends the wait early
+func (m *Manager) postExitDelay() {
+	if m.config.PostExitDelay <= 0 {
+		return
+	}
+
+	m.ready.Store(false)
+	logger.Info("Waiting %v before exiting", m.config.PostExitDelay)
+
+	timer := time.NewTimer(m.config.PostExitDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case sig := <-m.sigChan:
+		logger.Info("Received signal: %v, ending post-exit delay", sig)
+	case <-m.ctx.Done():
+	}
+}
+
+// abortStartup tears down whatever was started before a failure and returns
+// the original error, so a partially initialized manager never leaks a child,
+// watcher or listener
+func (m *Manager) abortStartup(err error) error {
+	logger.Info("Aborting: %v", err)
+	if shutdownErr := m.shutdown(false); shutdownErr != nil {
+		logger.Error("Error during cleanup after failure: %v", shutdownErr)
+	}
+	return err
+}
+
+// lameDuck waits for the lame-duck period, returning early on a second signal
+func (m *Manager) lameDuck() {
+	logger.Info("Entering lame-duck period of %v, child keeps serving", m.config.LameDuckPeriod)
+
+	timer := time.NewTimer(m.config.LameDuckPeriod)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		logger.Info("Lame-duck period elapsed")
+	case sig := <-m.sigChan:
+		logger.Info("Received signal: %v during lame-duck period, stopping immediately", sig)
+	}
+}
+
+// waitForDrain blocks while the drain sentinel exists, up to the shutdown
+// timeout. It returns true if a signal interrupted the wait
+func (m *Manager) waitForDrain() bool {
+	if m.config.DrainSentinel == "" || m.skipDrain {
+		return false
+	}
+
+	if _, err := os.Stat(m.config.DrainSentinel); err != nil {
+		return false
+	}
+
+	logger.Info("Drain sentinel %s present, deferring stop for up to %v until it is removed",
+		m.config.DrainSentinel, m.config.ShutdownTimeout)
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(m.config.ShutdownTimeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := os.Stat(m.config.DrainSentinel); os.IsNotExist(err) {
+				logger.Info("Drain sentinel removed, proceeding")
+				return false
+			}
+		case <-deadline.C:
+			logger.Warn("Timed out after %v waiting for drain sentinel %s, proceeding",
+				m.config.ShutdownTimeout, m.config.DrainSentinel)
+			return false
+		case <-m.configWatcher().Changes():
+			if m.configChanged() {
+				m.enqueueReload()
+			}
+		case <-m.sourceChanges:
+			if m.configChanged() {
+				m.enqueueReload()
+			}
+		case sig := <-m.sigChan:
+			logger.Info("Received signal: %v while waiting for drain, proceeding immediately", sig)
+			m.shutdownSignal(sig)
+			m.skipDrain = true
+			return true
+		}
+	}
+}
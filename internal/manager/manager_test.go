@@ -1,13 +1,32 @@
 package manager
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/metrics"
+	"github.com/zlrrr/flush-manager/internal/probe"
+	"github.com/zlrrr/flush-manager/internal/process"
+	"github.com/zlrrr/flush-manager/internal/watcher"
 )
 
 func TestNew(t *testing.T) {
@@ -69,6 +88,19 @@ func TestNew(t *testing.T) {
 		}
 	})
 
+	t.Run("error on unreadable TLS certificate", func(t *testing.T) {
+		config := Config{
+			Command:     "echo",
+			HealthAddr:  "127.0.0.1:0",
+			TLSCertFile: "/nonexistent/cert.pem",
+			TLSKeyFile:  "/nonexistent/key.pem",
+		}
+
+		m, err := New(config)
+		assert.ErrorContains(t, err, "TLS")
+		assert.Nil(t, m)
+	})
+
 	t.Run("error on empty command", func(t *testing.T) {
 		config := Config{
 			Command: "",
@@ -78,6 +110,11 @@ func TestNew(t *testing.T) {
 		assert.Error(t, err)
 		assert.Nil(t, m)
 	})
+
+	t.Run("reject non-terminating force kill signal", func(t *testing.T) {
+		_, err := New(Config{Command: "true", ForceKillSignal: syscall.SIGCONT})
+		assert.Error(t, err)
+	})
 }
 
 func TestManager_ProcessExitsNormally(t *testing.T) {
@@ -106,6 +143,54 @@ func TestManager_ProcessExitsNormally(t *testing.T) {
 	}
 }
 
+func TestManager_PostExitDelay(t *testing.T) {
+	run := func(t *testing.T, delay time.Duration) (*Manager, chan error) {
+		t.Helper()
+		m, err := New(Config{
+			Command:       "sh",
+			Args:          []string{"-c", "exit 0"},
+			PostExitDelay: delay,
+		})
+		require.NoError(t, err)
+		t.Cleanup(m.cancel)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		return m, done
+	}
+
+	t.Run("waits after a normal exit", func(t *testing.T) {
+		start := time.Now()
+		_, done := run(t, 500*time.Millisecond)
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	})
+
+	t.Run("signal ends the delay", func(t *testing.T) {
+		m, done := run(t, time.Minute)
+		// Not ready while waiting
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return status.LastExit != nil && !status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+
+		m.sigChan <- syscall.SIGTERM
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("signal did not end the post-exit delay")
+		}
+	})
+}
+
 func TestManager_ProcessExitsWithError(t *testing.T) {
 	config := Config{
 		Command: "sh",
@@ -130,6 +215,48 @@ func TestManager_ProcessExitsWithError(t *testing.T) {
 	case <-time.After(5 * time.Second):
 		t.Fatal("timeout waiting for manager to exit")
 	}
+
+	lastExit := m.Status().LastExit
+	require.NotNil(t, lastExit)
+	assert.Equal(t, process.ExitedWithCode, lastExit.Kind)
+	assert.Equal(t, 1, lastExit.Code)
+}
+
+func TestManager_OOMKill(t *testing.T) {
+	kmsg := filepath.Join(t.TempDir(), "kmsg")
+	require.NoError(t, os.WriteFile(kmsg, []byte(
+		"6,100,1000,-;eth0: link up\n"+
+			"3,101,2000,-;Out of memory: Killed process 4242 (sh) total-vm:1000kB\n"), 0644))
+	saved := kmsgPath
+	kmsgPath = kmsg
+	defer func() { kmsgPath = saved }()
+
+	before := oomKillsTotal.Value()
+
+	m, err := New(Config{
+		Command:   "sh",
+		Args:      []string{"-c", "kill -9 $$"},
+		DetectOOM: true,
+	})
+	require.NoError(t, err)
+	_ = m.Run()
+
+	assert.Equal(t, before+1, oomKillsTotal.Value())
+	assert.Equal(t, syscall.SIGKILL, m.Status().LastExit.Signal)
+
+	found, err := kernelReportsOOM("sh")
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = kernelReportsOOM("redis-exporter")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestIsOOMRecord(t *testing.T) {
+	line := "Out of memory: Killed process 1234 (redis-exporter-) total-vm:1000kB"
+	assert.True(t, isOOMRecord(line, "redis-exporter-v2"), "command names are truncated to 15 chars")
+	assert.False(t, isOOMRecord(line, "redis"))
+	assert.False(t, isOOMRecord("Started process 1234 (redis)", "redis"))
 }
 
 func TestManager_ConfigFileChange(t *testing.T) {
@@ -156,7 +283,7 @@ func TestManager_ConfigFileChange(t *testing.T) {
 	}()
 
 	// Wait for manager to start
-	time.Sleep(500 * time.Millisecond)
+	waitReady(t, m)
 
 	// Modify config file
 	err = os.WriteFile(configFile, []byte("modified"), 0644)
@@ -176,6 +303,271 @@ func TestManager_ConfigFileChange(t *testing.T) {
 	}
 }
 
+func TestManager_NoRestartOnConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.conf")
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	require.NoError(t, os.WriteFile(configFile, []byte("initial"), 0644))
+
+	m, err := New(Config{
+		Command:           "sh",
+		Args:              []string{"-c", "echo started >> " + outputFile + "; exec sleep 30"},
+		ConfigFilePath:    configFile,
+		NoRestartOnConfig: true,
+	})
+	require.NoError(t, err)
+
+	changes := configChangesTotal.Value()
+	suppressed := suppressedRestartsTotal.Value()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	require.NoError(t, os.WriteFile(configFile, []byte("modified"), 0644))
+	time.Sleep(1500 * time.Millisecond)
+
+	data, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "started\n", string(data), "child must not be restarted")
+	assert.Equal(t, changes+1, configChangesTotal.Value())
+	assert.Equal(t, suppressed+1, suppressedRestartsTotal.Value())
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+}
+
+func TestManager_ChildOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	stdoutFile := filepath.Join(tmpDir, "stdout.log")
+
+	m, err := New(Config{
+		Command:     "sh",
+		Args:        []string{"-c", "echo out; echo err >&2"},
+		ChildStdout: stdoutFile,
+		ChildStderr: "discard",
+	})
+	require.NoError(t, err)
+	assert.NoError(t, m.Run())
+
+	data, err := os.ReadFile(stdoutFile)
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", string(data))
+
+	t.Run("invalid output path", func(t *testing.T) {
+		_, err := New(Config{Command: "true", ChildStderr: "/nonexistent/dir/err.log"})
+		assert.Error(t, err)
+	})
+
+	t.Run("reopen signal must not be a shutdown signal", func(t *testing.T) {
+		_, err := New(Config{Command: "true", ReopenSignal: syscall.SIGTERM})
+		assert.Error(t, err)
+	})
+
+	t.Run("reopen on signal after rotation", func(t *testing.T) {
+		logFile := filepath.Join(tmpDir, "child.log")
+		rotated := logFile + ".1"
+
+		m, err := New(Config{
+			Command:     "sh",
+			Args:        []string{"-c", "while :; do echo tick; sleep 0.05; done"},
+			ChildStdout: logFile,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		time.Sleep(300 * time.Millisecond)
+
+		require.NoError(t, os.Rename(logFile, rotated))
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+		time.Sleep(300 * time.Millisecond)
+
+		m.cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+
+		old, err := os.ReadFile(rotated)
+		require.NoError(t, err)
+		assert.Contains(t, string(old), "tick")
+		fresh, err := os.ReadFile(logFile)
+		require.NoError(t, err, "output must land in a new file after the signal")
+		assert.Contains(t, string(fresh), "tick")
+	})
+}
+
+// unstartableCommand returns an executable file that exec refuses to start,
+// so the child fails at runtime rather than in validation
+func unstartableCommand(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "unstartable")
+	require.NoError(t, os.WriteFile(path, []byte("not a program\n"), 0755))
+	return path
+}
+
+// waitReady blocks until m finished starting
+func waitReady(t *testing.T, m *Manager) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, m.WaitReady(ctx))
+}
+
+func TestManager_WaitReady(t *testing.T) {
+	t.Run("returns once started", func(t *testing.T) {
+		m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+
+		waitReady(t, m)
+		assert.True(t, m.Status().Ready)
+
+		m.cancel()
+		<-done
+	})
+
+	t.Run("returns error if startup fails", func(t *testing.T) {
+		m, err := New(Config{Command: unstartableCommand(t)})
+		require.NoError(t, err)
+
+		go m.Run()
+		err = m.WaitReady(context.Background())
+		assert.ErrorIs(t, err, errStoppedBeforeReady)
+	})
+
+	t.Run("honors context", func(t *testing.T) {
+		m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+		require.NoError(t, err)
+		defer m.cancel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, m.WaitReady(ctx), context.DeadlineExceeded)
+	})
+}
+
+func TestManager_RequireConfigFile(t *testing.T) {
+	t.Run("missing file fails New", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "typo.conf")
+		_, err := New(Config{Command: "sleep", ConfigFilePath: missing, RequireConfigFile: true})
+		assert.ErrorContains(t, err, "required config file is missing")
+
+		// A no-op watcher by default
+		_, err = New(Config{Command: "sleep", ConfigFilePath: missing})
+		assert.NoError(t, err)
+	})
+
+	t.Run("path must be set", func(t *testing.T) {
+		_, err := New(Config{Command: "sleep", RequireConfigFile: true})
+		assert.ErrorContains(t, err, "no path is set")
+	})
+
+	t.Run("existing file accepted", func(t *testing.T) {
+		configFile := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(configFile, []byte("v1"), 0644))
+		m, err := New(Config{Command: "sleep", ConfigFilePath: configFile, RequireConfigFile: true})
+		require.NoError(t, err)
+		defer m.configWatcher().Close()
+
+		err = m.SetConfigPath(filepath.Join(t.TempDir(), "typo.conf"))
+		assert.ErrorContains(t, err, "required config file is missing")
+		assert.Equal(t, configFile, m.config.ConfigFilePath)
+	})
+}
+
+func TestManager_SetConfigPath(t *testing.T) {
+	start := func(t *testing.T, configFile string) (*Manager, func() string, chan error) {
+		t.Helper()
+		outputFile := filepath.Join(t.TempDir(), "output.txt")
+		m, err := New(Config{
+			Command:        "sh",
+			Args:           []string{"-c", "echo started >> " + outputFile + "; exec sleep 30"},
+			ConfigFilePath: configFile,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+
+		readOutput := func() string {
+			data, _ := os.ReadFile(outputFile)
+			return string(data)
+		}
+		require.Eventually(t, func() bool { return readOutput() == "started\n" }, 5*time.Second, 50*time.Millisecond)
+		return m, readOutput, done
+	}
+	stop := func(t *testing.T, m *Manager, done chan error) {
+		t.Helper()
+		m.cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	}
+
+	t.Run("switches watched file without restarting", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		oldFile := filepath.Join(tmpDir, "old.conf")
+		newFile := filepath.Join(tmpDir, "new.conf")
+		require.NoError(t, os.WriteFile(oldFile, []byte("old"), 0644))
+		require.NoError(t, os.WriteFile(newFile, []byte("new"), 0644))
+
+		m, readOutput, done := start(t, oldFile)
+		defer stop(t, m, done)
+
+		require.NoError(t, m.SetConfigPath(newFile))
+
+		// The old file is no longer watched
+		require.NoError(t, os.WriteFile(oldFile, []byte("old modified"), 0644))
+		time.Sleep(time.Second)
+		assert.Equal(t, "started\n", readOutput())
+
+		// The new one is
+		require.NoError(t, os.WriteFile(newFile, []byte("new modified"), 0644))
+		assert.Eventually(t, func() bool { return readOutput() == "started\nstarted\n" }, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("restarts when config appears", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		newFile := filepath.Join(tmpDir, "new.conf")
+		require.NoError(t, os.WriteFile(newFile, []byte("new"), 0644))
+
+		m, readOutput, done := start(t, filepath.Join(tmpDir, "missing.conf"))
+		defer stop(t, m, done)
+
+		require.NoError(t, m.SetConfigPath(newFile))
+		assert.Eventually(t, func() bool { return readOutput() == "started\nstarted\n" }, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("fails after shutdown", func(t *testing.T) {
+		m, err := New(Config{Command: "true"})
+		require.NoError(t, err)
+		m.cancel()
+		assert.Error(t, m.SetConfigPath("/tmp/some.conf"))
+	})
+}
+
 func TestManager_Shutdown(t *testing.T) {
 	t.Run("graceful shutdown", func(t *testing.T) {
 		config := Config{
@@ -187,7 +579,7 @@ func TestManager_Shutdown(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, m)
 
-		err = m.shutdown()
+		err = m.shutdown(false)
 		assert.NoError(t, err)
 	})
 
@@ -218,9 +610,104 @@ func TestManager_Shutdown(t *testing.T) {
 		// Wait a bit
 		time.Sleep(100 * time.Millisecond)
 
-		err = m.shutdown()
+		err = m.shutdown(false)
 		assert.NoError(t, err)
 	})
+
+	// runTrapping runs the manager with a child script that touches trapped
+	// once its signal traps are installed, and waits for that
+	runTrapping := func(t *testing.T, config Config, script, trapped string) (*Manager, chan error) {
+		t.Helper()
+		config.Command = "sh"
+		config.Args = []string{"-c", script + "; touch " + trapped + "; while :; do sleep 0.05; done"}
+		m, err := New(config)
+		require.NoError(t, err)
+		m.fileWatcher = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(trapped)
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+		return m, done
+	}
+
+	waitShutdown := func(t *testing.T, done chan error) {
+		t.Helper()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	}
+
+	t.Run("child handles SIGTERM on shutdown signal", func(t *testing.T) {
+		dir := t.TempDir()
+		marker := filepath.Join(dir, "stopped")
+		m, done := runTrapping(t, Config{}, "trap 'echo stopped > "+marker+"; exit 0' TERM", filepath.Join(dir, "trapped"))
+
+		m.sigChan <- syscall.SIGTERM
+		waitShutdown(t, done)
+		assert.FileExists(t, marker)
+	})
+
+	t.Run("child handles SIGTERM on context cancellation", func(t *testing.T) {
+		dir := t.TempDir()
+		marker := filepath.Join(dir, "stopped")
+		m, done := runTrapping(t, Config{}, "trap 'echo stopped > "+marker+"; exit 0' TERM", filepath.Join(dir, "trapped"))
+
+		m.cancel()
+		waitShutdown(t, done)
+		assert.FileExists(t, marker)
+	})
+
+	t.Run("force kill signal on shutdown", func(t *testing.T) {
+		dir := t.TempDir()
+		marker := filepath.Join(dir, "quit")
+		m, done := runTrapping(t, Config{
+			ShutdownTimeout: 200 * time.Millisecond,
+			ForceKillSignal: syscall.SIGQUIT,
+		}, "trap '' TERM; trap 'echo quit > "+marker+"; exit 3' QUIT", filepath.Join(dir, "trapped"))
+
+		m.sigChan <- syscall.SIGTERM
+		waitShutdown(t, done)
+		// Stop does not wait for the child to act on the force kill signal
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(marker)
+			return err == nil
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("forced kill is counted on shutdown", func(t *testing.T) {
+		dir := t.TempDir()
+		m, done := runTrapping(t, Config{ShutdownTimeout: 200 * time.Millisecond}, "trap '' TERM", filepath.Join(dir, "trapped"))
+
+		before := counterValue(t, "flushmanager_forced_kills_total")
+		m.sigChan <- syscall.SIGTERM
+		waitShutdown(t, done)
+		assert.Equal(t, before+1, counterValue(t, "flushmanager_forced_kills_total"))
+	})
+}
+
+// counterValue reads the counter name from the default metrics registry
+func counterValue(t *testing.T, name string) uint64 {
+	t.Helper()
+	var out strings.Builder
+	metrics.Default.Write(&out)
+	for _, line := range strings.Split(out.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, name+" "); ok {
+			n, err := strconv.ParseUint(value, 10, 64)
+			require.NoError(t, err)
+			return n
+		}
+	}
+	t.Fatalf("counter %s not registered", name)
+	return 0
 }
 
 func TestManager_Integration(t *testing.T) {
@@ -296,51 +783,3163 @@ sleep 30
 	})
 }
 
-// Test that manager properly handles context cancellation
-func TestManager_ContextCancellation(t *testing.T) {
-	config := Config{
-		Command: "sleep",
-		Args:    []string{"30"},
-	}
+func TestManager_LameDuck(t *testing.T) {
+	t.Run("reports not ready during lame-duck period", func(t *testing.T) {
+		config := Config{
+			Command:        "sleep",
+			Args:           []string{"30"},
+			HealthAddr:     "127.0.0.1:0",
+			LameDuckPeriod: 300 * time.Millisecond,
+		}
 
-	m, err := New(config)
-	require.NoError(t, err)
-	require.NotNil(t, m)
+		m, err := New(config)
+		require.NoError(t, err)
+		require.NotNil(t, m)
 
-	// Run in background
-	done := make(chan error, 1)
-	go func() {
-		done <- m.Run()
-	}()
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
 
-	// Wait for startup
-	time.Sleep(500 * time.Millisecond)
+		// Wait for startup
+		waitReady(t, m)
+		assert.True(t, m.ready.Load())
 
-	// Cancel context
-	m.cancel()
+		resp, err := http.Get("http://" + m.healthServer.Addr() + "/ready")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-	// Should exit quickly
-	select {
-	case <-done:
-		// Success
-	case <-time.After(5 * time.Second):
-		t.Fatal("timeout waiting for manager to exit after context cancellation")
-	}
-}
+		start := time.Now()
+		m.sigChan <- syscall.SIGTERM
 
-// Benchmark manager creation
-func BenchmarkNew(b *testing.B) {
-	config := Config{
-		Command: "echo",
-		Args:    []string{"hello"},
+		// Readiness flips immediately while the child keeps running
+		time.Sleep(100 * time.Millisecond)
+		resp, err = http.Get("http://" + m.healthServer.Addr() + "/ready")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, time.Since(start), config.LameDuckPeriod)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	})
+
+	t.Run("second signal interrupts lame-duck period", func(t *testing.T) {
+		config := Config{
+			Command:        "sleep",
+			Args:           []string{"30"},
+			LameDuckPeriod: 10 * time.Second,
+		}
+
+		m, err := New(config)
+		require.NoError(t, err)
+		require.NotNil(t, m)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+
+		time.Sleep(500 * time.Millisecond)
+		m.sigChan <- syscall.SIGTERM
+		time.Sleep(100 * time.Millisecond)
+		m.sigChan <- syscall.SIGTERM
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("second signal did not interrupt lame-duck period")
+		}
+	})
+
+	t.Run("child exiting on its own skips lame-duck period", func(t *testing.T) {
+		config := Config{
+			Command:        "sh",
+			Args:           []string{"-c", "sleep 0.5"},
+			LameDuckPeriod: 10 * time.Second,
+		}
+
+		m, err := New(config)
+		require.NoError(t, err)
+		require.NotNil(t, m)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("shutdown waited for the lame-duck period after the child exited")
+		}
+	})
+}
+
+// fakeWatcher is a FileWatcher that records its lifecycle calls
+type fakeWatcher struct {
+	startErr error
+	changes  chan watcher.ChangeEvent
+	started  atomic.Bool
+	closed   atomic.Bool
+}
+
+func (fw *fakeWatcher) Start(ctx context.Context) error {
+	fw.started.Store(true)
+	return fw.startErr
+}
+
+func (fw *fakeWatcher) Changes() <-chan watcher.ChangeEvent {
+	return fw.changes
+}
+
+func (fw *fakeWatcher) Close() error {
+	fw.closed.Store(true)
+	return nil
+}
+
+func (fw *fakeWatcher) ActiveWatches() []watcher.WatchInfo {
+	return nil
+}
+
+// failingStop is a process.Manager whose Stop stops the child but reports err
+type failingStop struct {
+	process.Manager
+	err error
+}
+
+func (f *failingStop) Stop(timeout time.Duration) error {
+	f.Manager.Stop(timeout)
+	return f.err
+}
+
+func TestManager_StopFailure(t *testing.T) {
+	t.Run("health server is shut down", func(t *testing.T) {
+		m, err := New(Config{
+			Command:    "sleep",
+			Args:       []string{"30"},
+			HealthAddr: "127.0.0.1:0",
+		})
+		require.NoError(t, err)
+		stopErr := errors.New("stop failed")
+		m.processManager = &failingStop{Manager: m.processManager, err: stopErr}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+
+		m.sigChan <- syscall.SIGTERM
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, stopErr)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+
+		_, err = http.Get("http://" + m.healthServer.Addr() + "/healthz")
+		assert.Error(t, err)
+	})
+
+	t.Run("listen sockets are closed", func(t *testing.T) {
+		socketPath := filepath.Join(t.TempDir(), "child.sock")
+		m, err := New(Config{
+			Command:       "sleep",
+			Args:          []string{"30"},
+			ListenSockets: []string{"127.0.0.1:0", "unix:" + socketPath},
+		})
+		require.NoError(t, err)
+		stopErr := errors.New("stop failed")
+		m.processManager = &failingStop{Manager: m.processManager, err: stopErr}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+		addr := m.listenSockets[0].listener.Addr().String()
+		require.FileExists(t, socketPath)
+
+		m.sigChan <- syscall.SIGTERM
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, stopErr)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+
+		_, err = net.DialTimeout("tcp", addr, time.Second)
+		assert.Error(t, err)
+		assert.NoFileExists(t, socketPath)
+	})
+}
+
+func TestManager_PartialStartup(t *testing.T) {
+	t.Run("process fails to start", func(t *testing.T) {
+		config := Config{
+			Command:    unstartableCommand(t),
+			HealthAddr: "127.0.0.1:0",
+		}
+
+		m, err := New(config)
+		require.NoError(t, err)
+		fw := &fakeWatcher{}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+
+		select {
+		case err := <-done:
+			assert.Error(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("manager hung after child failed to start")
+		}
+
+		assert.True(t, fw.closed.Load())
+		assert.Error(t, m.ctx.Err())
+		assert.False(t, m.ready.Load())
+
+		_, err = http.Get("http://" + m.healthServer.Addr() + "/healthz")
+		assert.Error(t, err)
+	})
+
+	t.Run("watcher fails to start after process started", func(t *testing.T) {
+		config := Config{
+			Command: "sleep",
+			Args:    []string{"30"},
+		}
+
+		m, err := New(config)
+		require.NoError(t, err)
+		fw := &fakeWatcher{startErr: errors.New("watch failed")}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+
+		select {
+		case err := <-done:
+			assert.ErrorContains(t, err, "watch failed")
+		case <-time.After(15 * time.Second):
+			t.Fatal("manager hung after watcher failed to start")
+		}
+
+		assert.True(t, fw.started.Load())
+		assert.True(t, fw.closed.Load())
+
+		// The child must not outlive the manager
+		select {
+		case <-m.exitChan:
+		case <-time.After(5 * time.Second):
+			t.Fatal("child process still running after failed startup")
+		}
+	})
+}
+
+func TestManager_SelfUpdate(t *testing.T) {
+	run := func(t *testing.T, minUptime time.Duration) (*Manager, *fakeWatcher, chan error) {
+		m, err := New(Config{
+			Command: "sleep",
+			Args:    []string{"30"},
+		})
+		require.NoError(t, err)
+
+		sw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.selfWatcher = sw
+		m.minSelfUptime = minUptime
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		time.Sleep(200 * time.Millisecond)
+
+		return m, sw, done
+	}
+
+	t.Run("returns ErrSelfUpdate after stopping child", func(t *testing.T) {
+		m, sw, done := run(t, 0)
+
+		sw.changes <- watcher.ChangeEvent{}
+
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, ErrSelfUpdate)
+			assert.True(t, sw.closed.Load())
+			assert.Error(t, m.ctx.Err())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for self-update")
+		}
+	})
+
+	t.Run("defers self-update until minimum uptime", func(t *testing.T) {
+		_, sw, done := run(t, time.Second)
+
+		sw.changes <- watcher.ChangeEvent{}
+		start := time.Now()
+
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, ErrSelfUpdate)
+			assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for deferred self-update")
+		}
+	})
+}
+
+func TestManager_DrainSentinel(t *testing.T) {
+	run := func(t *testing.T, config Config) (*Manager, chan error) {
+		m, err := New(config)
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		time.Sleep(300 * time.Millisecond)
+
+		return m, done
+	}
+
+	t.Run("shutdown waits for sentinel removal", func(t *testing.T) {
+		sentinel := filepath.Join(t.TempDir(), "draining")
+		require.NoError(t, os.WriteFile(sentinel, nil, 0644))
+
+		m, done := run(t, Config{
+			Command:       "sleep",
+			Args:          []string{"30"},
+			DrainSentinel: sentinel,
+		})
+
+		start := time.Now()
+		m.sigChan <- syscall.SIGTERM
+
+		time.Sleep(400 * time.Millisecond)
+		select {
+		case <-done:
+			t.Fatal("manager stopped while drain sentinel was present")
+		default:
+		}
+		require.NoError(t, os.Remove(sentinel))
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	})
+
+	t.Run("wait is bounded by shutdown timeout", func(t *testing.T) {
+		sentinel := filepath.Join(t.TempDir(), "draining")
+		require.NoError(t, os.WriteFile(sentinel, nil, 0644))
+
+		m, done := run(t, Config{
+			Command:         "sleep",
+			Args:            []string{"30"},
+			DrainSentinel:   sentinel,
+			ShutdownTimeout: 300 * time.Millisecond,
+		})
+
+		m.sigChan <- syscall.SIGTERM
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("drain wait was not bounded by shutdown timeout")
+		}
+	})
+
+	t.Run("second signal interrupts wait", func(t *testing.T) {
+		sentinel := filepath.Join(t.TempDir(), "draining")
+		require.NoError(t, os.WriteFile(sentinel, nil, 0644))
+
+		m, done := run(t, Config{
+			Command:         "sleep",
+			Args:            []string{"30"},
+			DrainSentinel:   sentinel,
+			ShutdownTimeout: time.Minute,
+		})
+
+		m.sigChan <- syscall.SIGTERM
+		time.Sleep(200 * time.Millisecond)
+		m.sigChan <- syscall.SIGTERM
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("second signal did not interrupt drain wait")
+		}
+	})
+}
+
+func TestManager_PendingReloads(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.conf")
+	sentinel := filepath.Join(tmpDir, "draining")
+	require.NoError(t, os.WriteFile(configFile, []byte("initial"), 0644))
+	require.NoError(t, os.WriteFile(sentinel, nil, 0644))
+
+	m, err := New(Config{
+		Command:         "sleep",
+		Args:            []string{"30"},
+		ConfigFilePath:  configFile,
+		DrainSentinel:   sentinel,
+		ShutdownTimeout: time.Minute,
+	})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	time.Sleep(300 * time.Millisecond)
+
+	// First change is enqueued and deferred by the drain sentinel
+	require.NoError(t, os.WriteFile(configFile, []byte("first"), 0644))
+	time.Sleep(time.Second)
+	assert.Equal(t, 1, m.Status().PendingReloads)
+
+	// Second change is coalesced into the pending reload
+	require.NoError(t, os.WriteFile(configFile, []byte("second"), 0644))
+	time.Sleep(time.Second)
+	assert.Equal(t, 1, m.Status().PendingReloads)
+	assert.Equal(t, uint64(1), m.Status().DroppedReloads)
+
+	// Removing the sentinel lets the reload execute
+	require.NoError(t, os.Remove(sentinel))
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 0, m.Status().PendingReloads)
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+}
+
+func TestManager_OverlappingChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	readOutput := func() string {
+		data, _ := os.ReadFile(outputFile)
+		return string(data)
+	}
+
+	m, err := New(Config{
+		Command: "sh",
+		Args:    []string{"-c", "echo started >> " + outputFile + "; exec sleep 30"},
+		// Don't process changes before the first child has written its line
+		ReadinessProbe: probeFunc(func(ctx context.Context) error {
+			if readOutput() == "" {
+				return errors.New("no output yet")
+			}
+			return nil
+		}),
+		ReadinessInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	// Both changes are queued before the run loop sees the first one
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 2)}
+	fw.changes <- watcher.ChangeEvent{}
+	fw.changes <- watcher.ChangeEvent{}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	assert.Eventually(t, func() bool { return readOutput() == "started\nstarted\n" },
+		5*time.Second, 50*time.Millisecond)
+
+	// No second restart follows
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, "started\nstarted\n", readOutput(), "overlapping changes must cause a single restart")
+	assert.Equal(t, uint64(1), m.Status().DroppedReloads)
+	assert.Equal(t, 0, m.Status().PendingReloads)
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+}
+
+func TestManager_ChangeDuringRestart(t *testing.T) {
+	m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.cancel()
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+	dropped := m.Status().DroppedReloads
+
+	// The second write lands while the first restart is still in its
+	// stop-sleep-start sequence
+	fw.changes <- watcher.ChangeEvent{}
+	require.Eventually(t, func() bool { return !m.Status().Ready }, 5*time.Second, time.Millisecond)
+	fw.changes <- watcher.ChangeEvent{}
+
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status.Exits) == 1 && status.Ready
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// No second restart follows
+	time.Sleep(500 * time.Millisecond)
+	assert.Len(t, m.Status().Exits, 1, "one burst of writes must cause a single restart")
+	assert.Equal(t, dropped+1, m.Status().DroppedReloads)
+}
+
+func TestManager_ChangeAfterSpawn(t *testing.T) {
+	m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.cancel()
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+
+	// A change detected after the new child was spawned, which it may have
+	// missed: the restart is followed by another one
+	fw.changes <- watcher.ChangeEvent{}
+	require.Eventually(t, func() bool { return !m.Status().Ready }, 5*time.Second, time.Millisecond)
+	fw.changes <- watcher.ChangeEvent{Time: time.Now().Add(time.Hour)}
+
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status.Exits) == 2 && status.Ready
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// And then it converged
+	time.Sleep(500 * time.Millisecond)
+	assert.Len(t, m.Status().Exits, 2)
+	assert.Equal(t, uint64(3), m.Status().Generation)
+}
+
+func TestManager_SignalDuringStartup(t *testing.T) {
+	t.Run("signal before child start is not lost", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "started")
+
+		m, err := New(Config{
+			Command: "sh",
+			Args:    []string{"-c", "touch " + marker + "; sleep 30"},
+		})
+		require.NoError(t, err)
+
+		// Deliver SIGTERM before Run has a chance to enter its event loop
+		m.sigChan <- syscall.SIGTERM
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("signal during startup was lost")
+		}
+
+		_, err = os.Stat(marker)
+		assert.True(t, os.IsNotExist(err), "child should not have been started")
+	})
+
+	t.Run("signal right after Run begins", func(t *testing.T) {
+		m, err := New(Config{
+			Command: "sleep",
+			Args:    []string{"30"},
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		m.sigChan <- syscall.SIGTERM
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(15 * time.Second):
+			t.Fatal("signal right after Run began was lost")
+		}
+	})
+}
+
+func TestParseCommandLine(t *testing.T) {
+	valid := []struct {
+		name    string
+		input   string
+		command string
+		args    []string
+	}{
+		{"plain words", "redis-exporter --web.listen-address=:9121", "redis-exporter", []string{"--web.listen-address=:9121"}},
+		{"collapse whitespace", " \tapp\n  -v \t", "app", []string{"-v"}},
+		{"single quotes are literal", `app '--name=a b' 'c\d' '$HOME'`, "app", []string{"--name=a b", `c\d`, "$HOME"}},
+		{"double quote escapes", `app "say \"hi\"" "back\\slash" "\$HOME" "keep\n"`, "app", []string{`say "hi"`, `back\slash`, "$HOME", `keep\n`}},
+		{"unquoted escapes", `app a\ b \'x\' \\`, "app", []string{"a b", "'x'", `\`}},
+		{"adjacent parts join", `app a"b c"d'e f'`, "app", []string{"ab cde f"}},
+		{"empty arguments", `app "" '' x`, "app", []string{"", "", "x"}},
+		{"quotes inside quotes", `app "it's" 'say "hi"'`, "app", []string{"it's", `say "hi"`}},
+		{"line continuation", "app \\\n  -v \"a\\\nb\"", "app", []string{"-v", "ab"}},
+		{"no expansion", "app $HOME *.yml a|b ;", "app", []string{"$HOME", "*.yml", "a|b", ";"}},
+		{"command only", "'/opt/my app/bin'", "/opt/my app/bin", []string{}},
+	}
+	for _, tt := range valid {
+		t.Run(tt.name, func(t *testing.T) {
+			command, args, err := ParseCommandLine(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.command, command)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+
+	invalid := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"empty", "  ", "empty"},
+		{"unterminated single quote", "app 'abc", "unterminated single quote"},
+		{"unterminated double quote", `app "abc\"`, "unterminated double quote"},
+		{"trailing backslash", `app \`, "backslash"},
+		{"empty command", `"" -v`, "command must not be empty"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseCommandLine(tt.input)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestReadArgsFile(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "args")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("one argument per line with comments", func(t *testing.T) {
+		path := write(t, `# exporter flags
+--collector.cpu
+
+  --web.listen-address=:9121
+"  padded  "
+"tab\there"
+'# not a comment'
+`)
+		args, err := readArgsFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"--collector.cpu",
+			"--web.listen-address=:9121",
+			"  padded  ",
+			"tab\there",
+			"# not a comment",
+		}, args)
+	})
+
+	t.Run("invalid quoting names the line", func(t *testing.T) {
+		path := write(t, "--ok\n'unterminated\n")
+		_, err := readArgsFile(path)
+		assert.ErrorContains(t, err, ":2:")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readArgsFile("/nonexistent/args")
+		assert.Error(t, err)
+	})
+}
+
+func TestReadEnvFile(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), ".env")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("key value lines with comments and quoting", func(t *testing.T) {
+		path := write(t, `# exporter settings
+REDIS_ADDR=redis:6379
+
+export REDIS_USER = admin
+REDIS_PASSWORD="p@ss\"word"
+GREETING='hello # world'
+EMPTY=
+`)
+		env, err := readEnvFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"REDIS_ADDR=redis:6379",
+			"REDIS_USER=admin",
+			`REDIS_PASSWORD=p@ss"word`,
+			"GREETING=hello # world",
+			"EMPTY=",
+		}, env)
+	})
+
+	t.Run("malformed lines name the line", func(t *testing.T) {
+		for _, content := range []string{
+			"A=1\nno equals sign\n",
+			"A=1\n1BAD=x\n",
+			"A=1\nB='unterminated\n",
+		} {
+			_, err := readEnvFile(write(t, content))
+			assert.ErrorContains(t, err, ":2:")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readEnvFile("/nonexistent/.env")
+		assert.Error(t, err)
+	})
+}
+
+func TestManager_EnvFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	envFile := filepath.Join(tmpDir, ".env")
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	require.NoError(t, os.WriteFile(envFile, []byte("WORD=first\n"), 0644))
+
+	m, err := New(Config{
+		Command:        "sh",
+		Args:           []string{"-c", "echo $WORD >> " + outputFile + "; exec sleep 30"},
+		EnvFile:        envFile,
+		ConfigFilePath: envFile,
+	})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	readOutput := func() string {
+		data, _ := os.ReadFile(outputFile)
+		return string(data)
+	}
+	require.Eventually(t, func() bool { return readOutput() == "first\n" }, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(envFile, []byte("WORD=second\n"), 0644))
+	assert.Eventually(t, func() bool { return readOutput() == "first\nsecond\n" }, 5*time.Second, 50*time.Millisecond)
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+}
+
+func TestManager_ArgsFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	argsFile := filepath.Join(tmpDir, "args")
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	writeArgs := func(word string) {
+		content := "-c\n" + "echo " + word + " >> " + outputFile + "; exec sleep 30\n"
+		require.NoError(t, os.WriteFile(argsFile, []byte(content), 0644))
+	}
+	writeArgs("first")
+
+	m, err := New(Config{
+		Command:        "sh",
+		ArgsFile:       argsFile,
+		ConfigFilePath: argsFile,
+	})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	writeArgs("second")
+	time.Sleep(1500 * time.Millisecond)
+
+	data, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+}
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	policy := RestartPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 800*time.Millisecond, policy.backoff(4))
+	assert.Equal(t, time.Second, policy.backoff(10))
+
+	assert.Equal(t, defaultInitialBackoff, RestartPolicy{}.backoff(1))
+
+	t.Run("full jitter stays within the exponential cap", func(t *testing.T) {
+		policy.Strategy = BackoffFullJitter
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			delay := policy.backoff(3)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, 400*time.Millisecond)
+			seen[delay] = true
+		}
+		assert.Greater(t, len(seen), 1, "jittered delays should vary")
+	})
+
+	t.Run("parse strategy", func(t *testing.T) {
+		for _, s := range []BackoffStrategy{BackoffExponential, BackoffFullJitter} {
+			parsed, err := ParseBackoffStrategy(s.String())
+			require.NoError(t, err)
+			assert.Equal(t, s, parsed)
+		}
+		_, err := ParseBackoffStrategy("linear")
+		assert.Error(t, err)
+	})
+}
+
+func TestStartLimiter(t *testing.T) {
+	now := time.Now()
+
+	t.Run("disabled", func(t *testing.T) {
+		l := newStartLimiter(StartLimit{})
+		for i := 0; i < 10; i++ {
+			assert.True(t, l.allow(now))
+		}
+		assert.Equal(t, 0, l.count(now))
+	})
+
+	t.Run("sliding window", func(t *testing.T) {
+		l := newStartLimiter(StartLimit{Interval: time.Minute, Burst: 3})
+		assert.True(t, l.allow(now))
+		assert.True(t, l.allow(now.Add(10*time.Second)))
+		assert.True(t, l.allow(now.Add(20*time.Second)))
+		assert.Equal(t, 3, l.count(now.Add(30*time.Second)))
+		assert.False(t, l.allow(now.Add(30*time.Second)))
+
+		// The first start leaves the window
+		assert.Equal(t, 2, l.count(now.Add(61*time.Second)))
+		assert.True(t, l.allow(now.Add(61*time.Second)))
+		assert.False(t, l.allow(now.Add(62*time.Second)))
+	})
+}
+
+func TestManager_StartLimit(t *testing.T) {
+	m, err := New(Config{
+		Command:    "sleep",
+		Args:       []string{"30"},
+		StartLimit: StartLimit{Interval: time.Minute, Burst: 2},
+	})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+	assert.Equal(t, 1, m.Status().RecentStarts)
+
+	fw.changes <- watcher.ChangeEvent{}
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return status.RecentStarts == 2 && status.Ready
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// A third start within the interval exceeds the burst. The change is sent
+	// once the restart finished, since changes during it are coalesced
+	fw.changes <- watcher.ChangeEvent{}
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, errStartLimit)
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, ShutdownStartLimit, exitErr.Cause)
+	case <-time.After(15 * time.Second):
+		t.Fatal("manager did not give up after hitting the start limit")
+	}
+}
+
+func TestManager_ShutdownReason(t *testing.T) {
+	run := func(t *testing.T, config Config) (*Manager, chan error) {
+		m, err := New(config)
+		require.NoError(t, err)
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+		return m, done
+	}
+	wait := func(t *testing.T, done chan error) error {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+			return nil
+		}
+	}
+
+	t.Run("signal", func(t *testing.T) {
+		m, done := run(t, Config{Command: "sleep", Args: []string{"30"}})
+		m.sigChan <- syscall.SIGTERM
+		assert.NoError(t, wait(t, done))
+		assert.Equal(t, ShutdownReason{Cause: ShutdownSignal, Signal: syscall.SIGTERM}, m.ShutdownReason())
+	})
+
+	t.Run("child exited", func(t *testing.T) {
+		m, done := run(t, Config{Command: "sh", Args: []string{"-c", "sleep 0.2; exit 3"}})
+		assert.NoError(t, wait(t, done))
+		reason := m.ShutdownReason()
+		assert.Equal(t, ShutdownChildExited, reason.Cause)
+		require.NotNil(t, reason.Exit)
+		assert.Equal(t, 3, reason.Exit.Code)
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		m, done := run(t, Config{Command: "sleep", Args: []string{"30"}})
+		m.cancel()
+		assert.NoError(t, wait(t, done))
+		assert.Equal(t, ShutdownContextCancelled, m.ShutdownReason().Cause)
+	})
+}
+
+func TestExitHistory(t *testing.T) {
+	now := time.Now()
+	exit := func(i int) process.Exit {
+		return process.Exit{
+			Reason:    process.ExitReasonRestart,
+			Status:    process.ExitStatus{Kind: process.ExitedWithCode},
+			StartedAt: now,
+			ExitedAt:  now.Add(time.Duration(i) * time.Second),
+		}
+	}
+
+	h := newExitHistory(3)
+	assert.Empty(t, h.list())
+
+	h.add(process.Exit{Reason: process.ExitReasonUnknown})
+	assert.Empty(t, h.list(), "exits of a child that never started are ignored")
+
+	for i := 1; i <= 4; i++ {
+		h.add(exit(i))
+	}
+	records := h.list()
+	require.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, time.Duration(i+2)*time.Second, record.Uptime)
+	}
+	assert.Contains(t, records[0].String(), "restart: exited with code 0 after 2s")
+}
+
+func TestManager_ExitHistory(t *testing.T) {
+	m, err := New(Config{
+		Command:    "sleep",
+		Args:       []string{"30"},
+		HealthAddr: "127.0.0.1:0",
+	})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+	assert.Empty(t, m.Status().Exits)
+
+	fw.changes <- watcher.ChangeEvent{}
+	// Ready again once the replacement child started
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status.Exits) == 1 && status.Ready
+	}, 5*time.Second, 50*time.Millisecond)
+
+	record := m.Status().Exits[0]
+	assert.Equal(t, process.ExitReasonRestart, record.Reason)
+	assert.Equal(t, process.ExitStatus{Kind: process.Stopped, Signal: syscall.SIGTERM}, record.Status)
+	assert.Greater(t, record.Uptime, time.Duration(0))
+
+	resp, err := http.Get("http://" + m.healthServer.Addr() + "/exits")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "restart: stopped, killed by signal 15")
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+}
+
+func TestManager_ActiveWatches(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("v1"), 0644))
+	m, err := New(Config{
+		Command:        "sleep",
+		Args:           []string{"30"},
+		ConfigFilePath: configFile,
+		HealthAddr:     "127.0.0.1:0",
+	})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	assert.Contains(t, m.Status().Watches,
+		watcher.WatchInfo{Path: filepath.Dir(configFile), Dir: true, Source: "fsnotify"})
+
+	resp, err := http.Get("http://" + m.healthServer.Addr() + "/debug/watches")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "fsnotify dir "+filepath.Dir(configFile)+"\n")
+	assert.Contains(t, string(body), "poll-stat file "+configFile+" (mtime=")
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+}
+
+func TestManager_TeeOutput(t *testing.T) {
+	stdoutFile := filepath.Join(t.TempDir(), "stdout.log")
+	m, err := New(Config{
+		Command:     "sh",
+		Args:        []string{"-c", "echo hello; sleep 30"},
+		ChildStdout: stdoutFile,
+		TeeOutput:   true,
+		HealthAddr:  "127.0.0.1:0",
+	})
+	require.NoError(t, err)
+	m.fileWatcher = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + m.healthServer.Addr() + "/logs")
+		if err != nil {
+			return false
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return err == nil && string(body) == "hello\n"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	data, err := os.ReadFile(stdoutFile)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+
+	t.Run("not with a pty", func(t *testing.T) {
+		err := Config{Command: "sleep", TeeOutput: true, AllocatePTY: true}.Validate()
+		assert.ErrorContains(t, err, "tee output cannot be combined with a PTY")
+	})
+}
+
+func TestManager_StopChild(t *testing.T) {
+	m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+	pid := m.processManager.PID()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, m.StopChild(ctx))
+	status := m.Status()
+	assert.True(t, status.ChildStopped)
+	assert.False(t, status.Ready)
+	assert.Error(t, syscall.Kill(pid, 0), "child should be gone")
+
+	// Stopping again is a no-op, and changes leave the child down
+	require.NoError(t, m.StopChild(ctx))
+	fw.changes <- watcher.ChangeEvent{}
+	assert.Never(t, func() bool {
+		return m.Status().Ready
+	}, 500*time.Millisecond, 50*time.Millisecond)
+	assert.True(t, m.Status().ChildStopped)
+
+	require.NoError(t, m.StartChild(ctx))
+	status = m.Status()
+	assert.False(t, status.ChildStopped)
+	assert.True(t, status.Ready)
+	assert.NotEqual(t, pid, m.processManager.PID())
+	require.NoError(t, m.StartChild(ctx))
+
+	m.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+	assert.Error(t, m.StopChild(ctx))
+
+	t.Run("shutdown while stopped", func(t *testing.T) {
+		m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+		require.NoError(t, err)
+		m.fileWatcher = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+		require.NoError(t, m.StopChild(ctx))
+
+		m.cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	})
+}
+
+func TestManager_ReadinessTimeout(t *testing.T) {
+	t.Run("start fails when probe never passes", func(t *testing.T) {
+		m, err := New(Config{
+			Command:           "sleep",
+			Args:              []string{"30"},
+			HealthAddr:        "127.0.0.1:0",
+			ReadinessProbe:    probe.TCP{Address: "127.0.0.1:1"},
+			ReadinessInterval: 50 * time.Millisecond,
+			ReadinessTimeout:  300 * time.Millisecond,
+			RestartPolicy:     RestartPolicy{MaxRetries: 1, InitialBackoff: 50 * time.Millisecond},
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		err = m.Run()
+		assert.ErrorContains(t, err, "not ready within")
+		// Two attempts plus one backoff
+		assert.GreaterOrEqual(t, time.Since(start), 650*time.Millisecond)
+
+		last := m.Status().LastProbe
+		require.NotNil(t, last)
+		assert.False(t, last.Success)
+		assert.NotEmpty(t, last.Error)
+	})
+
+	t.Run("ready once probe passes", func(t *testing.T) {
+		var healthy atomic.Bool
+		m, err := New(Config{
+			Command:    "sleep",
+			Args:       []string{"30"},
+			HealthAddr: "127.0.0.1:0",
+			ReadinessProbe: probeFunc(func(ctx context.Context) error {
+				if !healthy.Load() {
+					return errors.New("warming up")
+				}
+				return nil
+			}),
+			ReadinessInterval: 50 * time.Millisecond,
+			ReadinessTimeout:  5 * time.Second,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+
+		time.Sleep(300 * time.Millisecond)
+		assert.False(t, m.Status().Ready)
+		body := readyBody(t, m)
+		assert.Contains(t, body, "not ready")
+		assert.Contains(t, body, "warming up")
+
+		healthy.Store(true)
+		time.Sleep(300 * time.Millisecond)
+		status := m.Status()
+		assert.True(t, status.Ready)
+		require.NotNil(t, status.LastProbe)
+		assert.True(t, status.LastProbe.Success)
+		assert.Contains(t, readyBody(t, m), "pass at")
+
+		m.cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(15 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	})
+
+	t.Run("not ready after consecutive failures once ready", func(t *testing.T) {
+		var healthy atomic.Bool
+		healthy.Store(true)
+		m, err := New(Config{
+			Command:    "sleep",
+			Args:       []string{"30"},
+			HealthAddr: "127.0.0.1:0",
+			ReadinessProbe: probeFunc(func(ctx context.Context) error {
+				if !healthy.Load() {
+					return errors.New("overloaded")
+				}
+				return nil
+			}),
+			ReadinessInterval:         50 * time.Millisecond,
+			ReadinessTimeout:          5 * time.Second,
+			ReadinessSuccessThreshold: 2,
+			ReadinessFailureThreshold: 3,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+
+		healthy.Store(false)
+		assert.Eventually(t, func() bool { return !m.Status().Ready }, 2*time.Second, 20*time.Millisecond)
+		assert.Contains(t, readyBody(t, m), "overloaded")
+
+		healthy.Store(true)
+		assert.Eventually(t, func() bool { return m.Status().Ready }, 2*time.Second, 20*time.Millisecond)
+
+		m.cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(15 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	})
+
+	t.Run("child exiting before ready fails the start", func(t *testing.T) {
+		m, err := New(Config{
+			Command:           "sh",
+			Args:              []string{"-c", "exit 3"},
+			ReadinessProbe:    probe.TCP{Address: "127.0.0.1:1"},
+			ReadinessInterval: 50 * time.Millisecond,
+			ReadinessTimeout:  5 * time.Second,
+		})
+		require.NoError(t, err)
+
+		err = m.Run()
+		assert.ErrorContains(t, err, "exited before becoming ready")
+	})
+}
+
+// probeFunc adapts a function to the probe.Probe interface
+type probeFunc func(ctx context.Context) error
+
+func (f probeFunc) Check(ctx context.Context) error { return f(ctx) }
+func (f probeFunc) String() string                  { return "func" }
+
+func readyBody(t *testing.T, m *Manager) string {
+	t.Helper()
+	resp, err := http.Get("http://" + m.healthServer.Addr() + "/ready")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestManager_MaxStartupTime(t *testing.T) {
+	t.Run("exit when not healthy in time", func(t *testing.T) {
+		m, err := New(Config{
+			Command:           "sleep",
+			Args:              []string{"30"},
+			ReadinessProbe:    probe.TCP{Address: "127.0.0.1:1"},
+			ReadinessInterval: 50 * time.Millisecond,
+			ReadinessTimeout:  10 * time.Second,
+			RestartPolicy:     RestartPolicy{MaxRetries: 5, InitialBackoff: time.Second},
+			MaxStartupTime:    300 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		err = m.Run()
+		assert.ErrorIs(t, err, ErrStartupTimeout)
+		assert.Less(t, time.Since(start), 3*time.Second)
+		assert.Equal(t, 0, m.processManager.PID())
+	})
+
+	t.Run("deadline lifted once healthy", func(t *testing.T) {
+		m, err := New(Config{
+			Command:        "sleep",
+			Args:           []string{"30"},
+			MaxStartupTime: 300 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+
+		time.Sleep(500 * time.Millisecond)
+		assert.True(t, m.Status().Ready)
+		assert.NotZero(t, m.processManager.PID())
+
+		m.cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for manager to exit")
+		}
+	})
+
+	t.Run("reject negative max startup time", func(t *testing.T) {
+		_, err := New(Config{Command: "sleep", MaxStartupTime: -time.Second})
+		assert.Error(t, err)
+	})
+}
+
+// Test that manager properly handles context cancellation
+func TestManager_ContextCancellation(t *testing.T) {
+	config := Config{
+		Command: "sleep",
+		Args:    []string{"30"},
+	}
+
+	m, err := New(config)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	// Run in background
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+
+	// Wait for startup
+	waitReady(t, m)
+
+	// Cancel context
+	m.cancel()
+
+	// Should exit quickly
+	select {
+	case <-done:
+		// Success
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit after context cancellation")
+	}
+}
+
+// Benchmark manager creation
+func BenchmarkNew(b *testing.B) {
+	config := Config{
+		Command: "echo",
+		Args:    []string{"hello"},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m, err := New(config)
+		if err != nil {
+			b.Fatal(err)
+		}
+		m.cancel()
+	}
+}
+
+func TestNewWithContext(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, err := NewWithContext(parent, Config{
+		Command: "sleep",
+		Args:    []string{"30"},
+	})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	// Cancelling the caller's context shuts the manager down
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit after parent context cancellation")
+	}
+}
+
+func TestManager_ConfigChecksumFile(t *testing.T) {
+	run := func(t *testing.T, configFile, checksumFile string) *Manager {
+		t.Helper()
+		m, err := New(Config{
+			Command:            "sleep",
+			Args:               []string{"30"},
+			ConfigFilePath:     configFile,
+			ConfigChecksumFile: checksumFile,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		t.Cleanup(func() {
+			m.cancel()
+			<-done
+		})
+		waitReady(t, m)
+		return m
+	}
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.conf")
+	checksumFile := filepath.Join(tmpDir, "checksum")
+	require.NoError(t, os.WriteFile(configFile, []byte("v1"), 0644))
+
+	t.Run("first run persists the fingerprint", func(t *testing.T) {
+		m := run(t, configFile, checksumFile)
+		assert.Eventually(t, func() bool {
+			fingerprint, err := loadFingerprint(checksumFile)
+			return err == nil && fingerprint != ""
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.Empty(t, m.Status().Exits)
+	})
+
+	t.Run("unchanged config does not restart", func(t *testing.T) {
+		m := run(t, configFile, checksumFile)
+		time.Sleep(300 * time.Millisecond)
+		assert.Empty(t, m.Status().Exits)
+	})
+
+	t.Run("change while down restarts once", func(t *testing.T) {
+		before, err := loadFingerprint(checksumFile)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(configFile, []byte("v2"), 0644))
+
+		m := run(t, configFile, checksumFile)
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.Equal(t, process.ExitReasonRestart, m.Status().Exits[0].Reason)
+
+		// The new fingerprint is persisted once the restart completed
+		current, err := configFingerprint(configFile)
+		require.NoError(t, err)
+		assert.NotEqual(t, before, current)
+		assert.Eventually(t, func() bool {
+			after, err := loadFingerprint(checksumFile)
+			return err == nil && after == current
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+}
+
+func TestManager_ConfigFingerprint(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "test.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("v1"), 0644))
+
+	m, err := New(Config{
+		Command:        "sleep",
+		Args:           []string{"30"},
+		ConfigFilePath: configFile,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, m.ConfigFingerprint())
+
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.cancel()
+		<-done
+	}()
+	waitReady(t, m)
+
+	v1, err := configFingerprint(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, v1, m.ConfigFingerprint())
+
+	// Pending until the child is restarted with the new config
+	require.NoError(t, os.WriteFile(configFile, []byte("v2"), 0644))
+	v2, err := configFingerprint(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, v1, m.ConfigFingerprint())
+
+	fw.changes <- watcher.ChangeEvent{}
+	assert.Eventually(t, func() bool {
+		return m.ConfigFingerprint() == v2
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestManager_Webhook(t *testing.T) {
+	type delivery struct {
+		payload   webhookPayload
+		signature string
+	}
+	// receiver fails the first failures requests and records the others
+	receiver := func(t *testing.T, failures int32) (string, chan delivery) {
+		deliveries := make(chan delivery, 10)
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			var d delivery
+			assert.NoError(t, json.Unmarshal(body, &d.payload))
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			if sig := r.Header.Get(WebhookSignatureHeader); sig != "" {
+				assert.Equal(t, "sha256="+signWebhook(body, "s3cret"), sig)
+				d.signature = sig
+			}
+			deliveries <- d
+		}))
+		t.Cleanup(server.Close)
+		return server.URL, deliveries
+	}
+
+	run := func(t *testing.T, config Config) (*Manager, *fakeWatcher) {
+		t.Helper()
+		configFile := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(configFile, []byte("v1"), 0644))
+		config.Command = "sleep"
+		config.Args = []string{"30"}
+		config.ConfigFilePath = configFile
+		m, err := New(config)
+		require.NoError(t, err)
+		fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		t.Cleanup(func() {
+			m.cancel()
+			<-done
+		})
+		waitReady(t, m)
+		return m, fw
+	}
+
+	receive := func(t *testing.T, deliveries chan delivery, timeout time.Duration) delivery {
+		t.Helper()
+		select {
+		case d := <-deliveries:
+			return d
+		case <-time.After(timeout):
+			t.Fatal("timeout waiting for webhook")
+			return delivery{}
+		}
+	}
+
+	t.Run("config reload is reported with a signature", func(t *testing.T) {
+		url, deliveries := receiver(t, 0)
+		m, fw := run(t, Config{WebhookURL: url, WebhookSecret: "s3cret"})
+
+		fw.changes <- watcher.ChangeEvent{}
+		d := receive(t, deliveries, 5*time.Second)
+		assert.Equal(t, webhookReload, d.payload.Event)
+		assert.Equal(t, uint64(2), d.payload.Generation)
+		assert.Equal(t, "config file changed", d.payload.Reason)
+		assert.Equal(t, m.processManager.PID(), d.payload.PID)
+		assert.Equal(t, m.ConfigFingerprint(), d.payload.Fingerprint)
+		assert.WithinDuration(t, time.Now(), d.payload.Timestamp, 5*time.Second)
+		assert.NotEmpty(t, d.signature)
+	})
+
+	t.Run("unsigned without a secret", func(t *testing.T) {
+		url, deliveries := receiver(t, 0)
+		_, fw := run(t, Config{WebhookURL: url})
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Empty(t, receive(t, deliveries, 5*time.Second).signature)
+	})
+
+	t.Run("failed delivery is retried", func(t *testing.T) {
+		before := webhooksTotal.With("success").Value()
+		url, deliveries := receiver(t, 1)
+		_, fw := run(t, Config{WebhookURL: url})
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Equal(t, webhookReload, receive(t, deliveries, 5*time.Second).payload.Event)
+		assert.Eventually(t, func() bool {
+			return webhooksTotal.With("success").Value() == before+1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("reject invalid URL", func(t *testing.T) {
+		_, err := New(Config{Command: "sleep", WebhookURL: "ftp://example.com/hook"})
+		assert.ErrorContains(t, err, "webhook URL")
+	})
+}
+
+func TestManager_Generation(t *testing.T) {
+	m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+	assert.Zero(t, m.Status().Generation)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+	assert.Equal(t, uint64(1), m.Status().Generation)
+	assert.Equal(t, float64(1), childGenerationGauge.Value())
+	assert.Equal(t, "1", logger.Field("gen"))
+
+	fw.changes <- watcher.ChangeEvent{}
+	assert.Eventually(t, func() bool {
+		return m.Status().Generation == 2 && len(m.Status().Exits) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, float64(2), childGenerationGauge.Value())
+	assert.Equal(t, "2", logger.Field("gen"))
+
+	// Lines logged once the child is gone are not tagged with its generation
+	m.sigChan <- syscall.SIGTERM
+	require.NoError(t, <-done)
+	assert.Empty(t, logger.Field("gen"))
+}
+
+func TestManager_ShutdownDiscardsChanges(t *testing.T) {
+	// The run loop picks between ready cases at random, so the cancellation
+	// and a change have to be ready together, while it is busy: here with
+	// stopping an idle child that ignores SIGTERM
+	for i := 0; i < 3; i++ {
+		m, err := New(Config{
+			Command:         "sh",
+			Args:            []string{"-c", "trap '' TERM; while :; do sleep 0.05; done"},
+			IdleTimeout:     100 * time.Millisecond,
+			ShutdownTimeout: 500 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+
+		// Queue a change right as shutdown begins
+		require.Eventually(t, func() bool { return !m.Status().Ready }, 2*time.Second, 5*time.Millisecond)
+		m.cancel()
+		fw.changes <- watcher.ChangeEvent{}
+		require.NoError(t, <-done)
+
+		assert.Equal(t, uint64(1), m.Status().Generation, "child started again during shutdown")
+		assert.Empty(t, fw.changes, "pending change not drained")
+	}
+}
+
+func TestManager_Heartbeat(t *testing.T) {
+	modTime := func(t *testing.T, path string) time.Time {
+		t.Helper()
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		return info.ModTime()
+	}
+
+	t.Run("touched while running and not after shutdown", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "heartbeat")
+		m, err := New(Config{
+			Command:           "sleep",
+			Args:              []string{"30"},
+			HeartbeatFile:     path,
+			HeartbeatInterval: 50 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+
+		first := modTime(t, path)
+		assert.Eventually(t, func() bool {
+			return modTime(t, path).After(first)
+		}, 2*time.Second, 20*time.Millisecond)
+
+		m.cancel()
+		require.NoError(t, <-done)
+		last := modTime(t, path)
+		time.Sleep(200 * time.Millisecond)
+		assert.Equal(t, last, modTime(t, path))
+	})
+
+	t.Run("not touched without a running child", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "heartbeat")
+		m, err := New(Config{Command: "sleep", HeartbeatFile: path})
+		require.NoError(t, err)
+
+		m.heartbeat()
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("reject negative interval", func(t *testing.T) {
+		_, err := New(Config{Command: "sleep", HeartbeatInterval: -time.Second})
+		assert.Error(t, err)
+	})
+}
+
+func TestManager_OnChangeCommand(t *testing.T) {
+	run := func(t *testing.T, config Config) (*Manager, *fakeWatcher) {
+		t.Helper()
+		config.Command = "sleep"
+		config.Args = []string{"30"}
+		m, err := New(config)
+		require.NoError(t, err)
+		fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		t.Cleanup(func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		})
+		waitReady(t, m)
+		return m, fw
+	}
+
+	t.Run("requires a command", func(t *testing.T) {
+		_, err := New(Config{Command: "sleep", OnChange: ChangeCommand})
+		assert.ErrorContains(t, err, "requires an on-change command")
+	})
+
+	t.Run("command leaves the child running", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.txt")
+		m, fw := run(t, Config{
+			OnChangeCommand: []string{"sh", "-c", "echo ran >> " + outputFile},
+		})
+		assert.Equal(t, ChangeCommand, m.config.OnChange)
+		successes := onChangeCommandsTotal.With("success").Value()
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			return onChangeCommandsTotal.With("success").Value() == successes+1
+		}, 5*time.Second, 50*time.Millisecond)
+
+		data, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, "ran\n", string(data))
+		assert.Empty(t, m.Status().Exits)
+	})
+
+	t.Run("both runs the command and restarts", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.txt")
+		m, fw := run(t, Config{
+			OnChange:        ChangeCommandAndRestart,
+			OnChangeCommand: []string{"sh", "-c", "echo ran >> " + outputFile},
+		})
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 50*time.Millisecond)
+
+		data, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, "ran\n", string(data))
+	})
+
+	t.Run("failure and timeout are counted", func(t *testing.T) {
+		m, fw := run(t, Config{
+			OnChangeCommand: []string{"sleep", "10"},
+			OnChangeTimeout: 100 * time.Millisecond,
+		})
+		failures := onChangeCommandsTotal.With("failure").Value()
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			return onChangeCommandsTotal.With("failure").Value() == failures+1
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.True(t, m.Status().Ready)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("zero values are valid", func(t *testing.T) {
+		assert.NoError(t, Config{Command: "sleep"}.Validate())
+	})
+
+	t.Run("reports all problems", func(t *testing.T) {
+		err := Config{
+			Command:         "/nonexistent/command",
+			ShutdownTimeout: -time.Second,
+			ForceKillSignal: syscall.SIGCONT,
+			TLSCertFile:     "cert.pem",
+			OnChange:        ChangeCommand,
+		}.Validate()
+		require.Error(t, err)
+
+		for _, problem := range []string{
+			"command /nonexistent/command cannot be resolved",
+			"shutdown timeout must not be negative",
+			"force kill signal",
+			"both TLS certificate and key are required",
+			"requires an on-change command",
+		} {
+			assert.ErrorContains(t, err, problem)
+		}
+		assert.Len(t, strings.Split(err.Error(), "\n"), 5)
+	})
+
+	t.Run("mutually exclusive modes", func(t *testing.T) {
+		err := Config{Command: "sleep", NoRestartOnConfig: true, OnChange: ChangeRestart}.Validate()
+		assert.ErrorContains(t, err, "conflicts with no-restart-on-config")
+
+		err = Config{Command: "sleep", NoRestartOnConfig: true}.Validate()
+		assert.NoError(t, err)
+	})
+
+	t.Run("watch strategies", func(t *testing.T) {
+		strategies := []watcher.Strategy{watcher.StrategyFsnotify, watcher.StrategyPollHash}
+		err := Config{Command: "sleep", WatchMode: watcher.WatchPoll, WatchStrategies: strategies}.Validate()
+		assert.ErrorContains(t, err, "cannot be combined with watch mode poll")
+
+		err = Config{Command: "sleep", WatchStrategies: strategies}.Validate()
+		assert.NoError(t, err)
+	})
+
+	t.Run("exit codes", func(t *testing.T) {
+		err := Config{Command: "sleep", RestartOnExitCodes: []int{75, 300}, FatalExitCodes: []int{75}}.Validate()
+		assert.ErrorContains(t, err, "exit code 300 is out of range")
+		assert.ErrorContains(t, err, "exit code 75 is both a restart and a fatal exit code")
+	})
+
+	t.Run("reload circuit breaker", func(t *testing.T) {
+		err := Config{Command: "sleep", ReloadCircuitBreaker: CircuitBreaker{Failures: 3}}.Validate()
+		assert.ErrorContains(t, err, "requires rollback on failed reload")
+
+		err = Config{Command: "sleep", RollbackOnFailedReload: true, ReloadCircuitBreaker: CircuitBreaker{Failures: 3}}.Validate()
+		assert.NoError(t, err)
+	})
+
+	t.Run("New rejects invalid config", func(t *testing.T) {
+		_, err := New(Config{Command: "sleep", RestartPolicy: RestartPolicy{MaxRetries: -1}})
+		assert.ErrorContains(t, err, "invalid configuration")
+		assert.ErrorContains(t, err, "restart max retries")
+	})
+}
+
+func TestManager_Watches(t *testing.T) {
+	tmpDir := t.TempDir()
+	paths := map[WatchAction]string{}
+	for _, action := range []WatchAction{WatchRestart, WatchSignal, WatchCommand} {
+		paths[action] = filepath.Join(tmpDir, action.String()+".yml")
+		require.NoError(t, os.WriteFile(paths[action], []byte("a: 1\n"), 0644))
+	}
+	hupFile := filepath.Join(tmpDir, "hup")
+	commandFile := filepath.Join(tmpDir, "command")
+
+	m, err := New(Config{
+		Command: "sh",
+		Args:    []string{"-c", "trap 'touch " + hupFile + "' HUP; while true; do sleep 0.05; done"},
+		Watches: []WatchSpec{
+			{Path: paths[WatchRestart], Action: WatchRestart},
+			{Path: paths[WatchSignal], Action: WatchSignal},
+			{Path: paths[WatchCommand], Action: WatchCommand, Command: []string{"touch", commandFile}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, m.pathWatches, 3)
+	assert.Equal(t, syscall.SIGHUP, m.pathWatches[1].spec.Signal)
+
+	fakes := make([]*fakeWatcher, len(m.pathWatches))
+	for i, w := range m.pathWatches {
+		w.fw.Close()
+		fakes[i] = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		w.fw = fakes[i]
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.cancel()
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+	// Give the shell time to install its trap
+	time.Sleep(200 * time.Millisecond)
+
+	exists := func(path string) func() bool {
+		return func() bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}
+	}
+
+	t.Run("signal", func(t *testing.T) {
+		fakes[1].changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, exists(hupFile), 5*time.Second, 50*time.Millisecond)
+		assert.Empty(t, m.Status().Exits)
+	})
+
+	t.Run("command", func(t *testing.T) {
+		fakes[2].changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, exists(commandFile), 5*time.Second, 50*time.Millisecond)
+		assert.Empty(t, m.Status().Exits)
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		fakes[0].changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		err := Config{Command: "sleep", ConfigFilePath: paths[WatchRestart], Watches: []WatchSpec{
+			{Path: paths[WatchRestart]},
+			{Path: paths[WatchCommand], Action: WatchCommand},
+		}}.Validate()
+		assert.ErrorContains(t, err, "is watched more than once")
+		assert.ErrorContains(t, err, "requires a command")
+	})
+}
+
+func TestParseWatchAction(t *testing.T) {
+	for _, action := range []WatchAction{WatchRestart, WatchSignal, WatchCommand} {
+		parsed, err := ParseWatchAction(action.String())
+		require.NoError(t, err)
+		assert.Equal(t, action, parsed)
+	}
+	_, err := ParseWatchAction("reload")
+	assert.Error(t, err)
+}
+
+func TestManager_WatchBinary(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "child")
+	require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\nexec sleep 30\n"), 0755))
+
+	m, err := New(Config{Command: binary, WatchBinary: true})
+	require.NoError(t, err)
+	require.Len(t, m.pathWatches, 1)
+	assert.Equal(t, binary, m.pathWatches[0].spec.Path)
+	assert.True(t, m.pathWatches[0].binary)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.cancel()
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+	generation := m.Status().Generation
+
+	// A new version copied in without the executable bit is not started
+	tmp := binary + ".new"
+	require.NoError(t, os.WriteFile(tmp, []byte("#!/bin/sh\n# v2\nexec sleep 30\n"), 0644))
+	require.NoError(t, os.Rename(tmp, binary))
+	assert.Never(t, func() bool {
+		return m.Status().Generation != generation
+	}, 2*time.Second, 100*time.Millisecond)
+
+	require.NoError(t, os.Chmod(binary, 0755))
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return status.Generation > generation && status.Ready
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestManager_ChangeSources(t *testing.T) {
+	source := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m, err := New(Config{
+		Command:       "sleep",
+		Args:          []string{"30"},
+		ChangeSources: []watcher.ChangeSource{source},
+	})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+	assert.True(t, source.started.Load())
+
+	source.changes <- watcher.ChangeEvent{Source: "http", Time: time.Now()}
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status.Exits) == 1 && status.Ready
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, process.ExitReasonRestart, m.Status().Exits[0].Reason)
+
+	m.cancel()
+	require.NoError(t, <-done)
+	assert.True(t, source.closed.Load())
+}
+
+func TestManager_SigtermAction(t *testing.T) {
+	m, err := New(Config{
+		Command:       "sleep",
+		Args:          []string{"30"},
+		SigtermAction: SignalReload,
+	})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status.Exits) == 1 && status.Ready
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, process.ExitReasonRestart, m.Status().Exits[0].Reason)
+
+	select {
+	case err := <-done:
+		t.Fatalf("manager exited on SIGTERM: %v", err)
+	default:
+	}
+
+	// SIGINT still shuts down
+	m.sigChan <- syscall.SIGINT
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for manager to exit on SIGINT")
+	}
+}
+
+func TestParseSignalAction(t *testing.T) {
+	for _, action := range []SignalAction{SignalShutdown, SignalReload} {
+		parsed, err := ParseSignalAction(action.String())
+		require.NoError(t, err)
+		assert.Equal(t, action, parsed)
+	}
+	_, err := ParseSignalAction("ignore")
+	assert.Error(t, err)
+}
+
+func TestManager_ExitCodes(t *testing.T) {
+	run := func(t *testing.T, script string, config Config) (*Manager, chan error) {
+		t.Helper()
+		config.Command = "sh"
+		config.Args = []string{"-c", script}
+		m, err := New(config)
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		t.Cleanup(m.cancel)
+		return m, done
+	}
+
+	// Exits with code 75 on the first start and keeps running on the next
+	transient := func(t *testing.T) string {
+		marker := filepath.Join(t.TempDir(), "started")
+		return "if [ -e " + marker + " ]; then exec sleep 30; fi; touch " + marker + "; exit 75"
+	}
+
+	t.Run("restart exit code starts the child again", func(t *testing.T) {
+		before := exitRestartsTotal.Value()
+		m, done := run(t, transient(t), Config{RestartOnExitCodes: []int{75}})
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Equal(t, process.ExitStatus{Kind: process.ExitedWithCode, Code: 75}, *m.Status().LastExit)
+		assert.Equal(t, before+1, exitRestartsTotal.Value())
+
+		m.cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("default action restarts", func(t *testing.T) {
+		m, done := run(t, transient(t), Config{DefaultExitAction: ExitRestart})
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+
+		m.cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("fatal exit code shuts down", func(t *testing.T) {
+		m, done := run(t, transient(t), Config{FatalExitCodes: []int{75}, DefaultExitAction: ExitRestart})
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("manager did not shut down on a fatal exit code")
+		}
+		assert.Len(t, m.Status().Exits, 1)
+	})
+
+	t.Run("clean exit shuts down by default", func(t *testing.T) {
+		_, done := run(t, "exit 0", Config{DefaultExitAction: ExitRestart})
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("manager did not shut down on a clean exit")
+		}
+	})
+
+	t.Run("exit handler decides", func(t *testing.T) {
+		codes := make(chan int, 1)
+		handler := func(reason process.ExitReason, code int, err error) ExitAction {
+			codes <- code
+			assert.Equal(t, process.ExitReasonAbnormal, reason)
+			assert.Error(t, err)
+			return ExitRestart
+		}
+		// The fatal code list is overridden by the handler
+		m, done := run(t, transient(t), Config{FatalExitCodes: []int{75}, ExitHandler: handler})
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Equal(t, 75, <-codes)
+
+		m.cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("exit handler reports signals as code -1", func(t *testing.T) {
+		codes := make(chan int, 1)
+		handler := func(reason process.ExitReason, code int, err error) ExitAction {
+			codes <- code
+			return ExitShutdown
+		}
+		_, done := run(t, "kill -KILL $$", Config{ExitHandler: handler})
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("manager did not shut down")
+		}
+		assert.Equal(t, -1, <-codes)
+	})
+
+	t.Run("restart with backoff waits before starting again", func(t *testing.T) {
+		policy := RestartPolicy{InitialBackoff: 400 * time.Millisecond}
+		m, done := run(t, transient(t), Config{DefaultExitAction: ExitRestartWithBackoff, RestartPolicy: policy})
+		require.Eventually(t, func() bool { return len(m.Status().Exits) == 1 }, 5*time.Second, 10*time.Millisecond)
+		exited := time.Now()
+		assert.Eventually(t, func() bool { return m.Status().Ready }, 5*time.Second, 10*time.Millisecond)
+		assert.GreaterOrEqual(t, time.Since(exited), 300*time.Millisecond)
+
+		m.cancel()
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("stop during exit backoff", func(t *testing.T) {
+		policy := RestartPolicy{InitialBackoff: time.Minute}
+		m, done := run(t, "exit 1", Config{DefaultExitAction: ExitRestartWithBackoff, RestartPolicy: policy})
+		require.Eventually(t, func() bool { return len(m.Status().Exits) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		m.cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("manager did not shut down during exit backoff")
+		}
+	})
+}
+
+func TestParseExitAction(t *testing.T) {
+	for _, action := range []ExitAction{ExitShutdown, ExitRestart, ExitRestartWithBackoff} {
+		parsed, err := ParseExitAction(action.String())
+		require.NoError(t, err)
+		assert.Equal(t, action, parsed)
+	}
+	_, err := ParseExitAction("ignore")
+	assert.Error(t, err)
+}
+
+func TestManager_FlushCommand(t *testing.T) {
+	run := func(t *testing.T, config Config) (*Manager, *fakeWatcher) {
+		t.Helper()
+		m, err := New(config)
+		require.NoError(t, err)
+		fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		t.Cleanup(func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		})
+		waitReady(t, m)
+		return m, fw
+	}
+
+	t.Run("runs before the child is stopped for a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		outputFile := filepath.Join(dir, "output.txt")
+		pidFile := filepath.Join(dir, "child.pid")
+		m, fw := run(t, Config{
+			Command: "sh",
+			Args:    []string{"-c", "echo $$ > " + pidFile + "; echo child >> " + outputFile + "; exec sleep 30"},
+			// Only succeeds while the child is still running
+			FlushCommand: []string{"sh", "-c", "kill -0 $(cat " + pidFile + ") && echo flush >> " + outputFile},
+		})
+		assert.Equal(t, defaultFlushTimeout, m.config.FlushTimeout)
+		// The child is ready once spawned, maybe before it wrote its PID
+		require.Eventually(t, func() bool {
+			data, _ := os.ReadFile(outputFile)
+			return string(data) == "child\n"
+		}, 5*time.Second, 20*time.Millisecond)
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			data, _ := os.ReadFile(outputFile)
+			return string(data) == "child\nflush\nchild\n"
+		}, 5*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("required flush failing aborts the restart", func(t *testing.T) {
+		m, fw := run(t, Config{
+			Command:       "sleep",
+			Args:          []string{"30"},
+			FlushCommand:  []string{"sh", "-c", "echo disk full; exit 1"},
+			FlushRetries:  1,
+			FlushRequired: true,
+		})
+		pid := m.processManager.PID()
+		failures := flushFailuresTotal.Value()
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			return flushFailuresTotal.Value() == failures+2 && m.Status().Ready
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Equal(t, pid, m.processManager.PID())
+		assert.Equal(t, uint64(1), m.Status().Generation)
+	})
+
+	t.Run("restarts anyway unless required", func(t *testing.T) {
+		m, fw := run(t, Config{
+			Command:      "sleep",
+			Args:         []string{"30"},
+			FlushCommand: []string{"false"},
+		})
+		failures := flushFailuresTotal.Value()
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			return m.Status().Generation == 2 && m.Status().Ready
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Equal(t, failures+1, flushFailuresTotal.Value())
+	})
+}
+
+func TestManager_DrainCallback(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "drain.sock")
+	m, err := New(Config{
+		Command:           "sh",
+		Args:              []string{"-c", "trap '' TERM; while :; do sleep 0.05; done"},
+		ShutdownTimeout:   10 * time.Second,
+		DrainCallbackAddr: "unix:" + socket,
+	})
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	drained := func() int {
+		resp, err := client.Post("http://manager/drained", "", nil)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+	// Give the shell time to install its trap
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, http.StatusConflict, drained())
+
+	// The child ignores SIGTERM; its callback ends the stop early
+	start := time.Now()
+	m.sigChan <- syscall.SIGTERM
+	assert.Eventually(t, func() bool {
+		return drained() == http.StatusAccepted
+	}, 2*time.Second, 50*time.Millisecond)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
+	}
+	assert.NoFileExists(t, socket)
+}
+
+func TestManager_DrainCallbackFromChild(t *testing.T) {
+	if socket := os.Getenv("FLUSH_MANAGER_DRAIN_SOCKET"); socket != "" {
+		// The child: on SIGTERM, report drained and wait to be killed
+		terms := make(chan os.Signal, 1)
+		signal.Notify(terms, syscall.SIGTERM)
+		dir := filepath.Dir(socket)
+		os.WriteFile(filepath.Join(dir, "trapped"), nil, 0644)
+		<-terms
+		os.WriteFile(filepath.Join(dir, "terminated"), nil, 0644)
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}}
+		if resp, err := client.Post("http://manager/drained", "", nil); err == nil {
+			resp.Body.Close()
+		}
+		select {}
+	}
+
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "drain.sock")
+	t.Setenv("FLUSH_MANAGER_DRAIN_SOCKET", socket)
+	m, err := New(Config{
+		Command:           os.Args[0],
+		Args:              []string{"-test.run=^TestManager_DrainCallbackFromChild$"},
+		ShutdownTimeout:   10 * time.Second,
+		DrainCallbackAddr: "unix:" + socket,
+	})
+	require.NoError(t, err)
+	m.fileWatcher = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	pm := &drainRecorder{Manager: m.processManager}
+	m.processManager = pm
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "trapped"))
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// The child ignores SIGTERM; its own callback ends the stop early
+	start := time.Now()
+	m.sigChan <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	case <-time.After(15 * time.Second):
+		t.Fatal("timeout waiting for manager to exit")
 	}
+	assert.FileExists(t, filepath.Join(dir, "terminated"))
+	assert.True(t, pm.drained.Load(), "the child's callback was not accepted")
+}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
+// drainRecorder is a process.Manager that records an accepted drain callback
+type drainRecorder struct {
+	process.Manager
+	drained atomic.Bool
+}
+
+func (r *drainRecorder) Drained() bool {
+	if !r.Manager.Drained() {
+		return false
+	}
+	r.drained.Store(true)
+	return true
+}
+
+func TestExec(t *testing.T) {
+	if envFile := os.Getenv("FLUSH_MANAGER_EXEC_ENV_FILE"); envFile != "" {
+		err := Exec(Config{Command: "sh", Args: []string{"-c", `echo "$GREETING $$"`}, EnvFile: envFile})
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	t.Run("replaces the process", func(t *testing.T) {
+		envFile := filepath.Join(t.TempDir(), "env")
+		require.NoError(t, os.WriteFile(envFile, []byte("GREETING=hello\n"), 0644))
+
+		var stdout strings.Builder
+		cmd := exec.Command(os.Args[0], "-test.run=^TestExec$")
+		cmd.Env = append(os.Environ(), "FLUSH_MANAGER_EXEC_ENV_FILE="+envFile)
+		cmd.Stdout = &stdout
+		require.NoError(t, cmd.Start())
+		require.NoError(t, cmd.Wait())
+
+		// The child kept the PID of the process it replaced
+		assert.True(t, strings.HasSuffix(stdout.String(), fmt.Sprintf("\nhello %d\n", cmd.Process.Pid)), stdout.String())
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		assert.Error(t, Exec(Config{}))
+	})
+}
+
+func TestManager_InitCommand(t *testing.T) {
+	t.Run("runs before every start", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.txt")
+		m, err := New(Config{
+			Command:     "sh",
+			Args:        []string{"-c", "echo child >> " + outputFile + "; exec sleep 30"},
+			InitCommand: []string{"sh", "-c", "echo init >> " + outputFile},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, defaultInitTimeout, m.config.InitTimeout)
+		fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		defer func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		}()
+		output := func(want string) func() bool {
+			return func() bool {
+				data, _ := os.ReadFile(outputFile)
+				return string(data) == want
+			}
+		}
+		require.Eventually(t, output("init\nchild\n"), 5*time.Second, 20*time.Millisecond)
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, output("init\nchild\ninit\nchild\n"), 5*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("failure is retried and keeps the child from starting", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.txt")
+		m, err := New(Config{
+			Command:       "sh",
+			Args:          []string{"-c", "echo child >> " + outputFile + "; exec sleep 30"},
+			InitCommand:   []string{"sh", "-c", "echo not yet; exit 1"},
+			RestartPolicy: RestartPolicy{MaxRetries: 2, InitialBackoff: 10 * time.Millisecond},
+		})
+		require.NoError(t, err)
+		failures := initCommandsTotal.With("failure").Value()
+
+		err = m.Run()
+		assert.ErrorContains(t, err, "init command failed")
+		assert.Equal(t, failures+3, initCommandsTotal.With("failure").Value())
+		assert.NoFileExists(t, outputFile)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		m, err := New(Config{
+			Command:     "sleep",
+			Args:        []string{"30"},
+			InitCommand: []string{"sleep", "10"},
+			InitTimeout: 100 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		err = m.Run()
+		assert.ErrorContains(t, err, "timed out after 100ms")
+	})
+}
+
+func TestManager_MemoryRestartThreshold(t *testing.T) {
+	if !process.RSSSupported {
+		t.Skip("reading the child's memory is not supported on this platform")
+	}
+
+	run := func(t *testing.T, threshold int64) *Manager {
+		t.Helper()
+		m, err := New(Config{
+			Command:                "sleep",
+			Args:                   []string{"30"},
+			MemoryRestartThreshold: threshold,
+			MemoryCheckInterval:    100 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		t.Cleanup(func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		})
+		waitReady(t, m)
+		return m
+	}
+
+	t.Run("restarts the child above the threshold", func(t *testing.T) {
+		m := run(t, 1)
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) >= 1 && status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Equal(t, process.ExitReasonRestart, m.Status().Exits[0].Reason)
+	})
+
+	t.Run("leaves the child alone below the threshold", func(t *testing.T) {
+		m := run(t, 1<<40)
+		time.Sleep(500 * time.Millisecond)
+		assert.Empty(t, m.Status().Exits)
+	})
+}
+
+func TestManager_ListenSockets(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "child.sock")
+	m, err := New(Config{
+		Command:       "sleep",
+		Args:          []string{"30"},
+		ListenSockets: []string{"127.0.0.1:0", "unix:" + socketPath},
+	})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	waitReady(t, m)
+
+	addr := m.listenSockets[0].listener.Addr().String()
+	dial := func() error {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	assert.NoError(t, dial())
+
+	// Connections are still accepted into the backlog across a restart
+	fw.changes <- watcher.ChangeEvent{}
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status.Exits) == 1 && status.Ready
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.NoError(t, dial())
+
+	m.cancel()
+	require.NoError(t, <-done)
+	assert.Error(t, dial())
+	assert.NoFileExists(t, socketPath)
+}
+
+func TestManager_IdleTimeout(t *testing.T) {
+	run := func(t *testing.T, config Config) (*Manager, *fakeWatcher, chan error) {
+		t.Helper()
+		config.Command = "sleep"
+		config.Args = []string{"30"}
 		m, err := New(config)
-		if err != nil {
-			b.Fatal(err)
+		require.NoError(t, err)
+		fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+		return m, fw, done
+	}
+
+	t.Run("stops the child and starts it on the next change", func(t *testing.T) {
+		m, fw, done := run(t, Config{IdleTimeout: 300 * time.Millisecond})
+		defer func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		}()
+
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return status.Idle && !status.Ready && len(status.Exits) == 1
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.Equal(t, "child stopped after idle timeout", m.readyDetail())
+
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return !status.Idle && status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+
+		// The idle window starts over
+		assert.Eventually(t, func() bool { return m.Status().Idle }, 5*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("changes keep the child running", func(t *testing.T) {
+		m, fw, done := run(t, Config{IdleTimeout: time.Second, OnChange: ChangeCommand, OnChangeCommand: []string{"true"}})
+		defer func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		}()
+
+		for i := 0; i < 4; i++ {
+			time.Sleep(500 * time.Millisecond)
+			fw.changes <- watcher.ChangeEvent{}
+		}
+		assert.False(t, m.Status().Idle)
+		assert.Empty(t, m.Status().Exits)
+	})
+
+	t.Run("idle exit shuts the manager down", func(t *testing.T) {
+		_, _, done := run(t, Config{IdleTimeout: 200 * time.Millisecond, IdleExit: true})
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("manager did not exit after the idle timeout")
+		}
+	})
+}
+
+func TestManager_MirrorChildSignal(t *testing.T) {
+	run := func(t *testing.T, mirror bool, script string) error {
+		t.Helper()
+		m, err := New(Config{Command: "sh", Args: []string{"-c", script}, MirrorChildSignal: mirror})
+		require.NoError(t, err)
+		return m.Run()
+	}
+
+	t.Run("reports the signal", func(t *testing.T) {
+		err := run(t, true, "kill -SEGV $$")
+		var signaled *ChildSignaledError
+		require.ErrorAs(t, err, &signaled)
+		assert.Equal(t, syscall.SIGSEGV, signaled.Signal)
+	})
+
+	t.Run("exit codes are unaffected", func(t *testing.T) {
+		assert.NoError(t, run(t, true, "exit 3"))
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		assert.NoError(t, run(t, false, "kill -SEGV $$"))
+	})
+}
+
+func TestManager_PauseWatching(t *testing.T) {
+	m, err := New(Config{Command: "sleep", Args: []string{"30"}, HealthAddr: "127.0.0.1:0"})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.cancel()
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+
+	t.Run("changes are deferred to a single reload on resume", func(t *testing.T) {
+		m.PauseWatching()
+		assert.True(t, m.Status().Paused)
+
+		for i := 0; i < 3; i++ {
+			fw.changes <- watcher.ChangeEvent{}
+		}
+		time.Sleep(300 * time.Millisecond)
+		assert.Empty(t, m.Status().Exits)
+
+		m.ResumeWatching()
+		assert.False(t, m.Status().Paused)
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+
+		time.Sleep(300 * time.Millisecond)
+		assert.Len(t, m.Status().Exits, 1)
+	})
+
+	t.Run("resume without changes does not reload", func(t *testing.T) {
+		m.PauseWatching()
+		m.ResumeWatching()
+		time.Sleep(300 * time.Millisecond)
+		assert.Len(t, m.Status().Exits, 1)
+	})
+
+	t.Run("control endpoints", func(t *testing.T) {
+		base := "http://" + m.healthServer.Addr() + "/watching/"
+
+		resp, err := http.Post(base+"pause", "", nil)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "paused: true\n", string(body))
+		assert.True(t, m.Status().Paused)
+
+		resp, err = http.Get(base + "resume")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+		resp, err = http.Post(base+"resume", "", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.False(t, m.Status().Paused)
+	})
+}
+
+func TestRestartWindow(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 3, 1, hour, min, 0, 0, time.Local)
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		w, err := ParseRestartWindow("02:00-04:30")
+		require.NoError(t, err)
+		assert.Equal(t, RestartWindow{Start: 2 * time.Hour, End: 4*time.Hour + 30*time.Minute}, w)
+		assert.Equal(t, "02:00-04:30", w.String())
+
+		for _, invalid := range []string{"02:00", "2am-4am", "25:00-04:00", "03:00-03:00"} {
+			_, err := ParseRestartWindow(invalid)
+			assert.Error(t, err, invalid)
 		}
+	})
+
+	t.Run("same day", func(t *testing.T) {
+		w := RestartWindow{Start: 2 * time.Hour, End: 4 * time.Hour}
+		assert.False(t, w.contains(at(1, 59)))
+		assert.True(t, w.contains(at(2, 0)))
+		assert.False(t, w.contains(at(4, 0)))
+
+		assert.Equal(t, at(3, 0), w.next(at(3, 0)))
+		assert.Equal(t, at(2, 0), w.next(at(1, 0)))
+		assert.Equal(t, at(2, 0).AddDate(0, 0, 1), w.next(at(5, 0)))
+	})
+
+	t.Run("across midnight", func(t *testing.T) {
+		w := RestartWindow{Start: 22 * time.Hour, End: 2 * time.Hour}
+		assert.True(t, w.contains(at(23, 0)))
+		assert.True(t, w.contains(at(1, 0)))
+		assert.False(t, w.contains(at(12, 0)))
+		assert.Equal(t, at(22, 0), w.next(at(12, 0)))
+	})
+
+	t.Run("zero value allows any time", func(t *testing.T) {
+		assert.False(t, RestartWindow{}.enabled())
+		m, err := New(Config{Command: "sleep", Args: []string{"30"}})
+		require.NoError(t, err)
+		assert.Nil(t, m.Status().NextAllowedRestart)
+		assert.False(t, m.deferToRestartWindow())
+	})
+}
+
+func TestManager_RestartWindow(t *testing.T) {
+	// A window that opens shortly
+	now := time.Now()
+	start := (now.Sub(midnight(now, 0)) + 2*time.Second) % (24 * time.Hour)
+	m, err := New(Config{
+		Command:       "sleep",
+		Args:          []string{"30"},
+		RestartWindow: RestartWindow{Start: start, End: (start + time.Hour) % (24 * time.Hour)},
+	})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
 		m.cancel()
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+
+	next := m.Status().NextAllowedRestart
+	require.NotNil(t, next)
+	assert.True(t, next.After(time.Now()))
+
+	// Changes outside the window are applied together once it opens
+	for i := 0; i < 3; i++ {
+		fw.changes <- watcher.ChangeEvent{}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Empty(t, m.Status().Exits)
+
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status.Exits) == 1 && status.Ready
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.False(t, time.Now().Before(*next))
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Len(t, m.Status().Exits, 1)
+	assert.WithinDuration(t, time.Now(), *m.Status().NextAllowedRestart, time.Second)
+}
+
+func TestManager_RollbackOnFailedReload(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("good"), 0640))
+
+	m, err := New(Config{
+		Command:                "sleep",
+		Args:                   []string{"30"},
+		ConfigFilePath:         configFile,
+		ReadinessProbe:         probe.Exec{Command: []string{"sh", "-c", "! grep -q bad " + configFile}},
+		ReadinessInterval:      50 * time.Millisecond,
+		ReadinessTimeout:       500 * time.Millisecond,
+		RollbackOnFailedReload: true,
+	})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.sigChan <- syscall.SIGTERM
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+	generation := m.Status().Generation
+	rollbacks := rollbacksTotal.Value()
+
+	// A config the child can't start healthy with is rolled back
+	require.NoError(t, os.WriteFile(configFile, []byte("bad"), 0640))
+	fw.changes <- watcher.ChangeEvent{}
+
+	assert.Eventually(t, func() bool {
+		return rollbacksTotal.Value() == rollbacks+1 && m.Status().Ready
+	}, 5*time.Second, 20*time.Millisecond)
+	content, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "good", string(content))
+	info, err := os.Stat(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	assert.Equal(t, generation+2, m.Status().Generation)
+
+	// The restored file is not a change of its own
+	fw.changes <- watcher.ChangeEvent{}
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, generation+2, m.Status().Generation)
+	assert.True(t, m.Status().Ready)
+}
+
+func TestManager_ReloadCircuitBreaker(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "app.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("good"), 0640))
+
+	m, err := New(Config{
+		Command:                "sleep",
+		Args:                   []string{"30"},
+		ConfigFilePath:         configFile,
+		ReadinessProbe:         probe.Exec{Command: []string{"sh", "-c", "! grep -q bad " + configFile}},
+		ReadinessInterval:      50 * time.Millisecond,
+		ReadinessTimeout:       300 * time.Millisecond,
+		RollbackOnFailedReload: true,
+		ReloadCircuitBreaker:   CircuitBreaker{Failures: 2, Cooldown: time.Second},
+	})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.sigChan <- syscall.SIGTERM
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+
+	pushConfig := func(content string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0640))
+		fw.changes <- watcher.ChangeEvent{}
 	}
+	rollbacks := rollbacksTotal.Value()
+	for i := 1; i <= 2; i++ {
+		pushConfig("bad")
+		require.Eventually(t, func() bool {
+			return rollbacksTotal.Value() == rollbacks+uint64(i) && m.Status().Ready
+		}, 5*time.Second, 20*time.Millisecond)
+	}
+
+	// Open after two failed reloads in a row: changes are ignored
+	openUntil := m.Status().ReloadCircuitOpenUntil
+	require.NotNil(t, openUntil)
+	assert.Equal(t, float64(1), reloadCircuitOpenGauge.Value())
+	generation := m.Status().Generation
+	pushConfig("good again")
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, generation, m.Status().Generation)
+
+	// After the cooldown, the change made meanwhile is applied and closes it
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return status.Generation == generation+1 && status.Ready && status.ReloadCircuitOpenUntil == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.False(t, time.Now().Before(*openUntil))
+	assert.Equal(t, float64(0), reloadCircuitOpenGauge.Value())
+}
+
+func TestManager_ChildStdin(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "app.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("v1\n"), 0644))
+	outputFile := filepath.Join(dir, "output.txt")
+
+	m, err := New(Config{
+		Command:        "sh",
+		Args:           []string{"-c", "cat >> " + outputFile + "; exec sleep 30"},
+		ConfigFilePath: configFile,
+		ChildStdin:     StdinConfig,
+	})
+	require.NoError(t, err)
+	fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+	m.fileWatcher = fw
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+	defer func() {
+		m.cancel()
+		assert.NoError(t, <-done)
+	}()
+	waitReady(t, m)
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(outputFile)
+		return string(data) == "v1\n"
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// The restarted child reads the changed config
+	require.NoError(t, os.WriteFile(configFile, []byte("v2\n"), 0644))
+	fw.changes <- watcher.ChangeEvent{}
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(outputFile)
+		return string(data) == "v1\nv2\n"
+	}, 5*time.Second, 20*time.Millisecond)
+
+	t.Run("config source requires a config file", func(t *testing.T) {
+		err := Config{Command: "sleep", ChildStdin: StdinConfig}.Validate()
+		assert.ErrorContains(t, err, "requires a config file path")
+	})
+}
+
+func TestManager_ReadinessFile(t *testing.T) {
+	t.Run("marker left behind is removed before the start", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "ready")
+		// Left behind by a previous child
+		require.NoError(t, os.WriteFile(marker, []byte("ok\n"), 0644))
+
+		m, err := New(Config{
+			Command:           "sh",
+			Args:              []string{"-c", "sleep 0.3; echo ok > " + marker + "; exec sleep 30"},
+			ReadinessProbe:    probe.File{Path: marker, Content: "ok"},
+			ReadinessInterval: 50 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		m.fileWatcher = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+
+		start := time.Now()
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		defer func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		}()
+		waitReady(t, m)
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+		assert.FileExists(t, marker)
+	})
+
+	t.Run("marker written while stopping is removed before the restart", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "ready")
+		m, err := New(Config{
+			Command: "sh",
+			Args: []string{"-c", "trap 'echo ok > " + marker + "; exit 0' TERM; " +
+				"sleep 0.3; echo ok > " + marker + "; while :; do sleep 0.05; done"},
+			ReadinessProbe:    probe.File{Path: marker, Content: "ok"},
+			ReadinessInterval: 50 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		fw := &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+		m.fileWatcher = fw
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		defer func() {
+			m.cancel()
+			assert.NoError(t, <-done)
+		}()
+		waitReady(t, m)
+
+		start := time.Now()
+		fw.changes <- watcher.ChangeEvent{}
+		assert.Eventually(t, func() bool {
+			status := m.Status()
+			return len(status.Exits) == 1 && status.Ready
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+}
+
+func TestManager_OnTransition(t *testing.T) {
+	t.Run("stopped at shutdown", func(t *testing.T) {
+		var states []TransitionState
+		m, err := New(Config{
+			Command:      "sleep",
+			Args:         []string{"30"},
+			OnTransition: func(t Transition) { states = append(states, t.State) },
+		})
+		require.NoError(t, err)
+		m.fileWatcher = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- m.Run()
+		}()
+		waitReady(t, m)
+		m.sigChan <- syscall.SIGTERM
+		require.NoError(t, <-done)
+
+		assert.Equal(t, []TransitionState{
+			TransitionStarting, TransitionStarted, TransitionReady, TransitionStopping, TransitionStopped,
+		}, states)
+	})
+
+	t.Run("exited on its own", func(t *testing.T) {
+		var transitions []Transition
+		m, err := New(Config{
+			Command:      "sh",
+			Args:         []string{"-c", "sleep 0.2; exit 3"},
+			OnTransition: func(t Transition) { transitions = append(transitions, t) },
+		})
+		require.NoError(t, err)
+		m.fileWatcher = &fakeWatcher{changes: make(chan watcher.ChangeEvent, 1)}
+
+		assert.NoError(t, m.Run())
+		require.Len(t, transitions, 4)
+		exit := transitions[3]
+		assert.Equal(t, TransitionExited, exit.State)
+		assert.Equal(t, uint64(1), exit.Generation)
+		assert.Contains(t, exit.Detail, "3")
+		assert.False(t, exit.Time.Before(transitions[2].Time))
+	})
 }
@@ -0,0 +1,145 @@
+// Package managertest provides helpers for testing code that embeds the
+// manager, replacing sleep-based lifecycle scaffolding with bounded waits
+package managertest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/manager"
+	"github.com/zlrrr/flush-manager/internal/process"
+)
+
+// Timeout bounds every wait of the helpers
+var Timeout = 10 * time.Second
+
+// pollInterval is how often the helpers check the manager's status
+const pollInterval = 20 * time.Millisecond
+
+// StartAndWaitReady creates a manager from config, runs it and waits until it
+// is ready. The manager is shut down when the test ends, failing the test if
+// Run returns an error
+func StartAndWaitReady(t testing.TB, config manager.Config) *manager.Manager {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m, err := manager.NewWithContext(ctx, config)
+	if err != nil {
+		cancel()
+		t.Fatalf("failed to create manager: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run()
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("manager exited with error: %v", err)
+			}
+		case <-time.After(Timeout):
+			t.Errorf("manager did not shut down within %v", Timeout)
+		}
+	})
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, Timeout)
+	defer waitCancel()
+	if err := m.WaitReady(waitCtx); err != nil {
+		// The cleanup reports Run's error, if any
+		t.Fatalf("manager did not become ready: %v", err)
+	}
+	return m
+}
+
+// AssertRestartedOnChange appends a line to configPath and asserts that the
+// manager restarts the child and becomes ready again
+func AssertRestartedOnChange(t testing.TB, m *manager.Manager, configPath string) bool {
+	t.Helper()
+
+	before := lastRestart(m.Status())
+
+	f, err := os.OpenFile(configPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Errorf("failed to open config file: %v", err)
+		return false
+	}
+	_, err = fmt.Fprintf(f, "# changed at %s\n", time.Now().Format(time.RFC3339Nano))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Errorf("failed to modify config file: %v", err)
+		return false
+	}
+
+	return WaitFor(t, m, "child restarted and ready", func(status manager.Status) bool {
+		return status.Ready && lastRestart(status).After(before)
+	})
+}
+
+// WaitFor polls m's status until cond holds, failing the test with what if it
+// does not within Timeout
+func WaitFor(t testing.TB, m *manager.Manager, what string, cond func(manager.Status) bool) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(Timeout)
+	for {
+		if cond(m.Status()) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("timed out after %v waiting for: %s", Timeout, what)
+			return false
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Recorder collects the lifecycle transitions of a manager, for asserting on
+// their sequence. Pass its Record method as Config.OnTransition
+type Recorder struct {
+	mu          sync.Mutex
+	transitions []manager.Transition
+}
+
+// Record appends t to the recorded transitions
+func (r *Recorder) Record(t manager.Transition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transitions = append(r.transitions, t)
+}
+
+// Transitions returns a copy of the transitions recorded so far
+func (r *Recorder) Transitions() []manager.Transition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]manager.Transition(nil), r.transitions...)
+}
+
+// States returns the states of the transitions recorded so far
+func (r *Recorder) States() []manager.TransitionState {
+	transitions := r.Transitions()
+	states := make([]manager.TransitionState, len(transitions))
+	for i, t := range transitions {
+		states[i] = t.State
+	}
+	return states
+}
+
+// lastRestart returns when the child last exited because of a restart
+func lastRestart(status manager.Status) time.Time {
+	for i := len(status.Exits) - 1; i >= 0; i-- {
+		if status.Exits[i].Reason == process.ExitReasonRestart {
+			return status.Exits[i].Time
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,64 @@
+package managertest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zlrrr/flush-manager/internal/manager"
+)
+
+func TestStartAndWaitReady(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("initial\n"), 0644))
+
+	m := StartAndWaitReady(t, manager.Config{
+		Command:        "sleep",
+		Args:           []string{"30"},
+		ConfigFilePath: configFile,
+	})
+	assert.True(t, m.Status().Ready)
+
+	// Each change restarts the child again
+	assert.True(t, AssertRestartedOnChange(t, m, configFile))
+	assert.True(t, AssertRestartedOnChange(t, m, configFile))
+	assert.Len(t, m.Status().Exits, 2)
+}
+
+func TestRecorder(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "test.conf")
+	require.NoError(t, os.WriteFile(configFile, []byte("initial\n"), 0644))
+
+	var recorder Recorder
+	m := StartAndWaitReady(t, manager.Config{
+		Command:        "sleep",
+		Args:           []string{"30"},
+		ConfigFilePath: configFile,
+		OnTransition:   recorder.Record,
+	})
+	assert.Equal(t, []manager.TransitionState{
+		manager.TransitionStarting,
+		manager.TransitionStarted,
+		manager.TransitionReady,
+	}, recorder.States())
+
+	require.True(t, AssertRestartedOnChange(t, m, configFile))
+	assert.Equal(t, []manager.TransitionState{
+		manager.TransitionStarting,
+		manager.TransitionStarted,
+		manager.TransitionReady,
+		manager.TransitionReloading,
+		manager.TransitionStarting,
+		manager.TransitionStopping,
+		manager.TransitionStopped,
+		manager.TransitionStarted,
+		manager.TransitionReady,
+	}, recorder.States())
+
+	transitions := recorder.Transitions()
+	assert.Equal(t, uint64(1), transitions[0].Generation)
+	assert.Equal(t, uint64(2), transitions[len(transitions)-1].Generation)
+	assert.NotEqual(t, transitions[1].PID, transitions[len(transitions)-2].PID)
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/process"
+)
+
+// defaultMemoryCheckInterval is how often the child's memory is checked by
+// default
+const defaultMemoryCheckInterval = 10 * time.Second
+
+// startMemoryTicker starts checking the child's memory if
+// MemoryRestartThreshold is set. It returns the channel the run loop waits
+// on, nil if the check is disabled or unsupported
+func (m *Manager) startMemoryTicker() (<-chan time.Time, func()) {
+	if m.config.MemoryRestartThreshold <= 0 {
+		return nil, func() {}
+	}
+	if !process.RSSSupported {
+		logger.Warn("Memory restart threshold is only supported on linux, ignoring it")
+		return nil, func() {}
+	}
+
+	logger.Info("Restarting the child when its RSS exceeds %d bytes (checked every %v)",
+		m.config.MemoryRestartThreshold, m.config.MemoryCheckInterval)
+	ticker := time.NewTicker(m.config.MemoryCheckInterval)
+	return ticker.C, ticker.Stop
+}
+
+// checkMemory restarts the child gracefully if its resident set size exceeds
+// MemoryRestartThreshold. It reports whether the run loop has to return, and
+// with which error
+func (m *Manager) checkMemory() (bool, error) {
+	if m.idle.Load() || m.childStopped.Load() {
+		return false, nil
+	}
+	pid := m.processManager.PID()
+	if pid == 0 {
+		return false, nil
+	}
+
+	rss, err := process.ReadRSS(pid)
+	if err != nil {
+		// The child may have exited since PID returned
+		logger.Debug("Cannot read child memory usage: %v", err)
+		return false, nil
+	}
+	if rss <= m.config.MemoryRestartThreshold {
+		return false, nil
+	}
+
+	memoryRestartsTotal.Inc()
+	logger.Warn("Child RSS of %d bytes exceeds the threshold of %d bytes, restarting child process...",
+		rss, m.config.MemoryRestartThreshold)
+	if m.waitForDrain() {
+		logger.Info("Restart interrupted by signal, shutting down...")
+		return true, m.shutdown(false)
+	}
+
+	m.reloadArgs()
+	m.ready.Store(false)
+	if err := m.startChild(true); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return true, m.shutdown(false)
+		}
+		if errors.Is(err, errFlushFailed) {
+			m.ready.Store(true)
+			logger.Error("Restart aborted, the child keeps running: %v", err)
+			return false, nil
+		}
+		logger.Error("Failed to restart process: %v", err)
+		return true, m.abortStartup(err)
+	}
+	m.ready.Store(true)
+	m.persistFingerprint()
+	logger.Info("Child process restarted successfully after exceeding the memory threshold")
+	m.notifyWebhook(webhookRestart, fmt.Sprintf("memory usage of %d bytes exceeded the threshold", rss))
+	return false, nil
+}
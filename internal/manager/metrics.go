@@ -0,0 +1,37 @@
+package manager
+
+import "github.com/zlrrr/flush-manager/internal/metrics"
+
+// Metrics exported by the manager on /metrics
+var (
+	pendingReloadsGauge = metrics.NewGauge("flushmanager_pending_reloads",
+		"Number of config reloads waiting to be executed")
+	childGenerationGauge = metrics.NewGauge("flushmanager_child_generation",
+		"Generation of the current child, counting every start attempt")
+	droppedReloadsTotal = metrics.NewCounter("flushmanager_dropped_reloads_total",
+		"Number of config changes dropped because a reload was already pending or the manager shut down")
+	configChangesTotal = metrics.NewCounter("flushmanager_config_changes_total",
+		"Number of config file changes detected")
+	suppressedRestartsTotal = metrics.NewCounter("flushmanager_suppressed_restarts_total",
+		"Number of config changes that did not restart the child because restarts on config change are disabled")
+	onChangeCommandsTotal = metrics.NewCounterVec("flushmanager_on_change_commands_total",
+		"Number of on-change command runs, by result", "result")
+	initCommandsTotal = metrics.NewCounterVec("flushmanager_init_commands_total",
+		"Number of init command runs, by result", "result")
+	flushFailuresTotal = metrics.NewCounter("flushmanager_flush_failures_total",
+		"Number of failed flush command attempts before a restart")
+	watchedChangesTotal = metrics.NewCounterVec("flushmanager_watched_path_changes_total",
+		"Number of changes to additional watched paths, by action", "action")
+	oomKillsTotal = metrics.NewCounter("flushmanager_oom_kills_total",
+		"Number of child exits by SIGKILL, the likely sign of an OOM kill")
+	exitRestartsTotal = metrics.NewCounter("flushmanager_exit_restarts_total",
+		"Number of times the child was started again after exiting on its own")
+	rollbacksTotal = metrics.NewCounter("flushmanager_config_rollbacks_total",
+		"Number of times a failed reload was rolled back to the last known-good config")
+	reloadCircuitOpenGauge = metrics.NewGauge("flushmanager_reload_circuit_open",
+		"1 while config changes are ignored after repeated failed reloads, 0 otherwise")
+	memoryRestartsTotal = metrics.NewCounter("flushmanager_memory_restarts_total",
+		"Number of child restarts because its memory exceeded the restart threshold")
+	webhooksTotal = metrics.NewCounterVec("flushmanager_webhooks_total",
+		"Number of webhook deliveries, by result", "result")
+)
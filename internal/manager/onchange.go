@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// ChangeAction selects how the manager reacts to a config change
+type ChangeAction int
+
+const (
+	// ChangeAuto restarts the child, or runs OnChangeCommand if one is set
+	ChangeAuto ChangeAction = iota
+	// ChangeRestart restarts the child
+	ChangeRestart
+	// ChangeCommand runs OnChangeCommand and leaves the child running
+	ChangeCommand
+	// ChangeCommandAndRestart runs OnChangeCommand, then restarts the child
+	ChangeCommandAndRestart
+)
+
+func (a ChangeAction) String() string {
+	switch a {
+	case ChangeAuto:
+		return "auto"
+	case ChangeRestart:
+		return "restart"
+	case ChangeCommand:
+		return "command"
+	case ChangeCommandAndRestart:
+		return "both"
+	default:
+		return fmt.Sprintf("ChangeAction(%d)", int(a))
+	}
+}
+
+// ParseChangeAction parses an action name as returned by String
+func ParseChangeAction(name string) (ChangeAction, error) {
+	for _, a := range []ChangeAction{ChangeAuto, ChangeRestart, ChangeCommand, ChangeCommandAndRestart} {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown change action %q (expected auto, restart, command or both)", name)
+}
+
+// runsCommand reports whether the action runs the on-change command
+func (a ChangeAction) runsCommand() bool {
+	return a == ChangeCommand || a == ChangeCommandAndRestart
+}
+
+// restarts reports whether the action restarts the child
+func (a ChangeAction) restarts() bool {
+	return a == ChangeRestart || a == ChangeCommandAndRestart
+}
+
+const (
+	// defaultOnChangeTimeout bounds the on-change command by default
+	defaultOnChangeTimeout = 30 * time.Second
+
+	// commandWaitDelay bounds how long output is collected after the
+	// command exited, in case a background process keeps it open
+	commandWaitDelay = time.Second
+)
+
+// onConfigChange reacts to a config change according to the configured
+// action. It reports whether the run loop has to return, and with which error
+func (m *Manager) onConfigChange() (bool, error) {
+	if m.ctx.Err() != nil {
+		// Picked over the cancellation; shutting down takes precedence
+		return true, m.shutdown(false)
+	}
+	if m.unchangedConfig() || m.circuitOpen() {
+		return false, nil
+	}
+	if m.keptStopped() {
+		return false, nil
+	}
+	if m.idle.Load() {
+		return m.wake()
+	}
+	m.resetIdleTimer()
+
+	if m.config.OnChange.runsCommand() {
+		m.runOnChangeCommand()
+	}
+	if !m.config.OnChange.restarts() {
+		// The child picks up the change without a restart
+		fingerprint := m.currentFingerprint()
+		m.fingerprint.Store(&fingerprint)
+		m.persistFingerprint()
+		m.notifyWebhook(webhookReload, "config file changed, on-change command run")
+		return false, nil
+	}
+	if m.deferToRestartWindow() {
+		return false, nil
+	}
+	logger.Info("Config file change detected, restarting child process...")
+	return m.reload(webhookReload, "config file changed")
+}
+
+// runOnChangeCommand runs OnChangeCommand for a config file change
+func (m *Manager) runOnChangeCommand() {
+	logger.Info("Config file change detected, running on-change command: %v", m.config.OnChangeCommand)
+	m.runChangeCommand(m.config.OnChangeCommand)
+}
+
+// runLogged runs command to completion or until timeout, logging each line
+// of its output tagged with tag. It returns how long the command ran
+func (m *Manager) runLogged(command []string, timeout time.Duration, tag string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(m.opCtx(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.WaitDelay = commandWaitDelay
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		logger.Info("[%s] %s", tag, scanner.Text())
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", timeout)
+	}
+	return elapsed, err
+}
+
+// runChangeCommand runs command to completion or until OnChangeTimeout,
+// logging its output. Failures are logged and counted, the child is not
+// affected
+func (m *Manager) runChangeCommand(command []string) {
+	elapsed, err := m.runLogged(command, m.config.OnChangeTimeout, "on-change")
+	if err != nil {
+		onChangeCommandsTotal.With("failure").Inc()
+		logger.Error("On-change command failed after %v: %v", elapsed, err)
+		return
+	}
+	onChangeCommandsTotal.With("success").Inc()
+	logger.Info("On-change command succeeded in %v", elapsed)
+}
@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/process"
+)
+
+// kmsgPath is the kernel log device consulted by -detect-oom
+var kmsgPath = "/dev/kmsg"
+
+// commLen is the kernel's limit on a task's command name (TASK_COMM_LEN - 1)
+const commLen = 15
+
+// checkOOM logs a hint and counts the exit if the child was SIGKILLed, which
+// is what the kernel OOM killer does. The signal alone can't prove it was the
+// OOM killer, so with DetectOOM the kernel log is checked as well
+func (m *Manager) checkOOM(status process.ExitStatus) {
+	if status.Kind != process.Signaled || status.Signal != syscall.SIGKILL {
+		return
+	}
+
+	oomKillsTotal.Inc()
+	logger.Warn("Child appears to have been OOM-killed (SIGKILL); check its memory limit")
+
+	if !m.config.DetectOOM {
+		return
+	}
+
+	comm := filepath.Base(m.config.Command)
+	found, err := kernelReportsOOM(comm)
+	switch {
+	case err != nil:
+		logger.Warn("Could not read kernel log to confirm OOM kill: %v", err)
+	case found:
+		logger.Warn("Kernel log confirms the OOM killer killed %s", comm)
+	default:
+		logger.Info("No OOM kill of %s found in kernel log; it was SIGKILLed by something else", comm)
+	}
+}
+
+// kernelReportsOOM scans the kernel log for an OOM kill of a process named comm
+func kernelReportsOOM(comm string) (bool, error) {
+	f, err := os.OpenFile(kmsgPath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// /dev/kmsg returns one record per read and EAGAIN once drained;
+	// a regular file (as in tests) just ends with EOF
+	found := false
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if isOOMRecord(line, comm) {
+			found = true
+		}
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, io.EOF) {
+				return found, nil
+			}
+			if errors.Is(err, syscall.EPIPE) {
+				// Records were overwritten while reading; keep going
+				continue
+			}
+			return found, fmt.Errorf("failed to read %s: %w", kmsgPath, err)
+		}
+	}
+}
+
+// isOOMRecord reports whether a kernel log line records an OOM kill of comm,
+// e.g. "Out of memory: Killed process 1234 (redis-exporter) total-vm:..."
+func isOOMRecord(line, comm string) bool {
+	if len(comm) > commLen {
+		comm = comm[:commLen]
+	}
+	return strings.Contains(line, "Killed process") && strings.Contains(line, "("+comm+")")
+}
@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// PauseWatching makes the manager ignore config changes, e.g. during a batch
+// of planned edits. Changes are still detected and remembered, so
+// ResumeWatching can apply them with a single reload
+func (m *Manager) PauseWatching() {
+	if m.paused.Swap(true) {
+		return
+	}
+	logger.Info("Watching paused, config changes will be applied on resume")
+}
+
+// ResumeWatching reacts to config changes again. If any occurred while
+// paused, each affected path's action runs once
+func (m *Manager) ResumeWatching() {
+	if !m.paused.Swap(false) {
+		return
+	}
+	logger.Info("Watching resumed")
+	select {
+	case m.resumed <- struct{}{}:
+	default:
+	}
+}
+
+// deferWhilePaused records a change of w (nil for the config file) if
+// watching is paused, reporting whether it has to be ignored for now
+func (m *Manager) deferWhilePaused(w *pathWatch) bool {
+	if !m.paused.Load() {
+		return false
+	}
+	if w == nil {
+		m.pausedConfigChange = true
+	} else {
+		m.pausedWatches[w] = true
+	}
+	logger.Info("Change ignored while watching is paused")
+	return true
+}
+
+// applyPausedChanges runs the actions of the changes that occurred while
+// watching was paused. It reports whether the run loop has to return, and
+// with which error
+func (m *Manager) applyPausedChanges() (bool, error) {
+	if m.paused.Load() {
+		// Paused again before the run loop got to it
+		return false, nil
+	}
+
+	for _, w := range m.pathWatches {
+		if !m.pausedWatches[w] {
+			continue
+		}
+		delete(m.pausedWatches, w)
+		if done, err := m.onPathChange(w); done {
+			return true, err
+		}
+	}
+
+	if !m.pausedConfigChange {
+		return false, nil
+	}
+	m.pausedConfigChange = false
+	logger.Info("Applying config change made while watching was paused")
+	return m.onConfigChange()
+}
+
+// handlePause pauses watching, for POST /watching/pause
+func (m *Manager) handlePause(w http.ResponseWriter, r *http.Request) {
+	m.PauseWatching()
+	m.writeWatching(w)
+}
+
+// handleResume resumes watching, for POST /watching/resume
+func (m *Manager) handleResume(w http.ResponseWriter, r *http.Request) {
+	m.ResumeWatching()
+	m.writeWatching(w)
+}
+
+// writeWatching reports the paused state in a control endpoint response
+func (m *Manager) writeWatching(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "paused: %v\n", m.paused.Load())
+}
@@ -0,0 +1,200 @@
+package manager
+
+import (
+	"errors"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/watcher"
+)
+
+// maxPendingReloads bounds the reload queue. A restart always picks up the
+// latest config, so changes beyond the bound are coalesced and dropped
+const maxPendingReloads = 1
+
+// reload restarts the child to pick up a config change, and again as long as
+// the config changed after the new child was started. It reports whether the
+// run loop has to return, and with which error
+func (m *Manager) reload(event, reason string) (bool, error) {
+	for {
+		if done, err := m.restartForChange(event, reason); done || !m.dirty {
+			return done, err
+		}
+		m.dirty = false
+		if m.ctx.Err() != nil {
+			return true, m.shutdown(false)
+		}
+		logger.Info("Config changed after the child was restarted, restarting again to apply it")
+	}
+}
+
+// restartForChange makes a single restart for reload
+func (m *Manager) restartForChange(event, reason string) (bool, error) {
+	m.enqueueReload()
+	if m.waitForDrain() {
+		logger.Info("Restart interrupted by signal, shutting down...")
+		return true, m.shutdown(false)
+	}
+	// Changes that arrived while draining are covered by this restart,
+	// which re-reads the config right before stopping the child
+	m.collapseChanges()
+	m.dequeueReload()
+	m.reloadArgs()
+	m.ready.Store(false)
+	m.transition(TransitionReloading, reason)
+	if err := m.startChild(true); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return true, m.shutdown(false)
+		}
+		if errors.Is(err, errFlushFailed) {
+			m.ready.Store(true)
+			logger.Error("Restart aborted, the child keeps running with the previous config: %v", err)
+			return false, nil
+		}
+		logger.Error("Failed to restart process: %v", err)
+		if m.config.RollbackOnFailedReload {
+			rollbackErr := m.rollBack()
+			if rollbackErr == nil {
+				m.ready.Store(true)
+				m.reloadFailed()
+				m.notifyWebhook(webhookRestart, "config reload failed, rolled back to the last known-good config")
+				return false, nil
+			}
+			if errors.Is(rollbackErr, errInterrupted) {
+				return true, m.shutdown(false)
+			}
+			logger.Error("Cannot roll back: %v", rollbackErr)
+		}
+		return true, m.abortStartup(err)
+	}
+	// One burst of writes yields one restart: the new child already read
+	// what changed before it was spawned
+	m.coalesceChanges()
+	m.ready.Store(true)
+	m.reloadSucceeded()
+	m.persistFingerprint()
+	logger.Info("Child process restarted successfully after config change")
+	m.notifyWebhook(event, reason)
+	return false, nil
+}
+
+// configChanged counts a detected config change and reports whether it should
+// trigger a restart
+func (m *Manager) configChanged() bool {
+	configChangesTotal.Inc()
+	if !m.config.NoRestartOnConfig {
+		return true
+	}
+
+	suppressedRestartsTotal.Inc()
+	logger.Warn("Config changed, restart suppressed")
+	return false
+}
+
+// enqueueReload records a config change, returning false if it was coalesced
+// into a reload that is already pending
+func (m *Manager) enqueueReload() bool {
+	if int(m.pendingReloads.Load()) >= maxPendingReloads {
+		m.droppedReloads.Add(1)
+		droppedReloadsTotal.Inc()
+		logger.Info("Config change coalesced into already pending reload")
+		return false
+	}
+
+	pending := m.pendingReloads.Add(1)
+	pendingReloadsGauge.Set(float64(pending))
+	logger.Info("Reload enqueued (pending: %d)", pending)
+	return true
+}
+
+// collapseChanges folds config changes that are already waiting into the
+// pending reload, so a restart that is about to run reflects them instead of
+// being followed by a second restart
+func (m *Manager) collapseChanges() {
+	for {
+		select {
+		case <-m.configWatcher().Changes():
+		case <-m.sourceChanges:
+		default:
+			return
+		}
+		if m.configChanged() {
+			m.enqueueReload()
+		}
+	}
+}
+
+// coalesceChanges drops config changes that arrived during a restart instead
+// of letting them trigger a second one. A change detected after the new
+// child was spawned may have been missed by it, so it marks the config dirty
+// instead, and reload restarts once more. Events without a Time are assumed
+// to be covered by the restart
+func (m *Manager) coalesceChanges() {
+	for {
+		var event watcher.ChangeEvent
+		select {
+		case event = <-m.configWatcher().Changes():
+		case event = <-m.sourceChanges:
+		default:
+			return
+		}
+		if !m.configChanged() {
+			continue
+		}
+		if event.Time.After(m.spawnedAt) {
+			m.dirty = true
+			logger.Info("Config change after the child was spawned, another restart follows")
+			continue
+		}
+		m.droppedReloads.Add(1)
+		droppedReloadsTotal.Inc()
+		logger.Info("Config change during restart coalesced into it")
+	}
+}
+
+// dequeueReload marks the pending reload as being executed
+func (m *Manager) dequeueReload() {
+	if m.pendingReloads.Load() == 0 {
+		return
+	}
+
+	pending := m.pendingReloads.Add(-1)
+	pendingReloadsGauge.Set(float64(pending))
+	logger.Info("Executing reload (pending: %d)", pending)
+}
+
+// dropPendingReloads discards any pending reload
+func (m *Manager) dropPendingReloads(reason string) {
+	dropped := m.pendingReloads.Swap(0)
+	if dropped == 0 {
+		return
+	}
+
+	m.droppedReloads.Add(uint64(dropped))
+	droppedReloadsTotal.Add(uint64(dropped))
+	pendingReloadsGauge.Set(0)
+	logger.Info("Dropped %d pending reload(s): %s", dropped, reason)
+}
+
+// discardChanges drops the change notifications still queued once the
+// watchers are stopped, so none of them is acted on during shutdown
+func (m *Manager) discardChanges() {
+	// Forwarders exit once watching stops; wait so none delivers after the drain
+	m.forwarders.Wait()
+
+	discarded := 0
+	for {
+		select {
+		case <-m.configWatcher().Changes():
+		case <-m.sourceChanges:
+		case <-m.pathChanges:
+		default:
+			if discarded > 0 {
+				m.droppedReloads.Add(uint64(discarded))
+				droppedReloadsTotal.Add(uint64(discarded))
+				logger.Info("Discarded %d change notification(s) pending at shutdown", discarded)
+			}
+			return
+		}
+		discarded++
+	}
+}
@@ -0,0 +1,266 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/probe"
+	"github.com/zlrrr/flush-manager/internal/process"
+)
+
+// RestartPolicy controls how a failed start is retried. A start fails when
+// the child cannot be started or does not pass its readiness probe in time
+type RestartPolicy struct {
+	// MaxRetries is how many times a failed start is retried before the
+	// manager gives up; 0 disables retries
+	MaxRetries int
+
+	// InitialBackoff is the delay before the first retry (default 1s)
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the exponentially growing delay (default 30s)
+	MaxBackoff time.Duration
+
+	// Strategy selects how the delay is derived from the exponential cap
+	Strategy BackoffStrategy
+}
+
+// BackoffStrategy determines the delay between start retries
+type BackoffStrategy int
+
+const (
+	// BackoffExponential waits the exponential delay itself
+	BackoffExponential BackoffStrategy = iota
+	// BackoffFullJitter waits a random delay between 0 and the exponential
+	// delay, so many instances retrying at once spread out instead of
+	// hitting a shared dependency in lockstep
+	BackoffFullJitter
+)
+
+func (s BackoffStrategy) String() string {
+	switch s {
+	case BackoffExponential:
+		return "exponential"
+	case BackoffFullJitter:
+		return "full-jitter"
+	default:
+		return fmt.Sprintf("BackoffStrategy(%d)", int(s))
+	}
+}
+
+// ParseBackoffStrategy parses a strategy name as returned by String
+func ParseBackoffStrategy(name string) (BackoffStrategy, error) {
+	for _, s := range []BackoffStrategy{BackoffExponential, BackoffFullJitter} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown backoff strategy %q (expected exponential or full-jitter)", name)
+}
+
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// backoff returns the delay before the given retry, starting at 1
+func (p RestartPolicy) backoff(retry int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+
+	delay := initial
+	for i := 1; i < retry && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	if p.Strategy == BackoffFullJitter {
+		return rand.N(delay + 1)
+	}
+	return delay
+}
+
+// errInterrupted is returned when a signal or cancellation interrupts a start
+var errInterrupted = errors.New("start interrupted")
+
+// exitResult is a child exit reported by the exit monitor
+type exitResult struct {
+	reason process.ExitReason
+	err    error
+	status process.ExitStatus
+	uptime time.Duration
+}
+
+// watchExit reports the exit of the current child generation on exitChan
+func (m *Manager) watchExit() {
+	go func() {
+		exit := m.processManager.WaitExit()
+		m.exitHistory.add(exit)
+		m.exitChan <- exitResult{reason: exit.Reason, err: exit.Err, status: exit.Status, uptime: exit.Uptime()}
+	}()
+}
+
+// startChild starts (or restarts) the child and waits for it to become ready,
+// retrying with backoff according to the restart policy
+func (m *Manager) startChild(restart bool) error {
+	policy := m.config.RestartPolicy
+
+	for retry := 0; ; retry++ {
+		running, err := m.tryStart(restart)
+		if err == nil {
+			m.cacheKnownGoodConfig()
+			return nil
+		}
+		if retry >= policy.MaxRetries || errors.Is(err, errStartLimit) || errors.Is(err, errFlushFailed) {
+			return err
+		}
+
+		delay := policy.backoff(retry + 1)
+		logger.Warn("Start attempt %d failed: %v, retrying in %v", retry+1, err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case sig := <-m.sigChan:
+			timer.Stop()
+			logger.Info("Received signal: %v during restart backoff", sig)
+			m.shutdownSignal(sig)
+			return errInterrupted
+		case <-m.opCtx().Done():
+			timer.Stop()
+			return errInterrupted
+		}
+
+		// A child that is still running has to be replaced, not started again
+		restart = running
+	}
+}
+
+// tryStart makes a single start attempt, reporting whether a child is left
+// running when it fails
+func (m *Manager) tryStart(restart bool) (bool, error) {
+	if !m.startLimiter.allow(time.Now()) {
+		limit := m.config.StartLimit
+		logger.Error("Child started %d times within %v, giving up", limit.Burst, limit.Interval)
+		return restart, fmt.Errorf("%w: %d starts within %v", errStartLimit, limit.Burst, limit.Interval)
+	}
+
+	// The previous child keeps running until the init command succeeded
+	if err := m.runInitCommand(); err != nil {
+		return restart, err
+	}
+	if restart {
+		if err := m.runFlushCommand(); err != nil {
+			return true, err
+		}
+	}
+
+	// Taken before the child reads the config, so a change racing with the
+	// start makes the fingerprint stale rather than wrongly current
+	fingerprint := m.currentFingerprint()
+
+	m.nextGeneration()
+	m.transition(TransitionStarting, "")
+	m.spawnedAt = time.Now()
+	// Cancelling the manager's context must not kill the child: shutdown
+	// stops it gracefully, with the stop timeout and force-kill signal
+	childCtx := context.WithoutCancel(m.ctx)
+	var err error
+	if restart {
+		replacing := m.childRunning()
+		if replacing {
+			m.transition(TransitionStopping, "restart")
+		}
+		err = m.processManager.Restart(childCtx)
+		if err == nil && replacing {
+			m.transition(TransitionStopped, "restart")
+		}
+	} else {
+		err = m.processManager.Start(childCtx)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to start child process: %w", err)
+	}
+	m.transition(TransitionStarted, "")
+	m.fingerprint.Store(&fingerprint)
+	m.watchExit()
+	// The new child picks up any change still waiting for the restart window
+	m.clearDeferredRestart()
+
+	running, err := m.awaitReadiness()
+	if err == nil {
+		m.transition(TransitionReady, "")
+	}
+	return running, err
+}
+
+// awaitReadiness waits for the readiness probe to pass, bounded by the
+// readiness timeout. It reports whether the child is still running on failure
+func (m *Manager) awaitReadiness() (bool, error) {
+	if m.prober == nil {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(m.opCtx(), m.config.ReadinessTimeout)
+	defer cancel()
+
+	ready := make(chan error, 1)
+	go func() {
+		ready <- m.prober.WaitReady(ctx)
+	}()
+
+	for {
+		select {
+		case err := <-ready:
+			if err != nil {
+				return true, fmt.Errorf("child not ready within %v: %w", m.config.ReadinessTimeout, err)
+			}
+			return true, nil
+
+		case result := <-m.exitChan:
+			// The previous generation exiting because we replaced it is expected
+			if result.reason == process.ExitReasonRestart {
+				continue
+			}
+			cancel()
+			<-ready
+			status := result.status
+			m.transition(TransitionExited, status.String())
+			return false, fmt.Errorf("child exited before becoming ready: %v", status)
+		}
+	}
+}
+
+// resetProbe resets a readiness probe whose state outlives the child, such
+// as a marker file, so it only passes once the new child is ready. It runs
+// right before each child is spawned, after the previous one was stopped
+func (m *Manager) resetProbe() {
+	resetter, ok := m.config.ReadinessProbe.(probe.Resetter)
+	if !ok {
+		return
+	}
+	if err := resetter.Reset(); err != nil {
+		logger.Warn("Failed to reset readiness probe %v: %v", m.config.ReadinessProbe, err)
+	}
+}
+
+// nextGeneration counts a new child generation and tags all following log
+// lines with it, so the activity around one (re)start can be filtered for
+func (m *Manager) nextGeneration() {
+	gen := m.generation.Add(1)
+	logger.SetField("gen", strconv.FormatUint(gen, 10))
+	childGenerationGauge.Set(float64(gen))
+}
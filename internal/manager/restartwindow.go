@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// RestartWindow is a daily time-of-day range, in local time, during which
+// config-driven restarts may happen. A window whose end is before its start
+// spans midnight. The zero value allows restarts at any time
+type RestartWindow struct {
+	Start time.Duration // since midnight
+	End   time.Duration // since midnight
+}
+
+// ParseRestartWindow parses a window written as HH:MM-HH:MM
+func ParseRestartWindow(s string) (RestartWindow, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return RestartWindow{}, fmt.Errorf("invalid restart window %q (expected HH:MM-HH:MM)", s)
+	}
+	var w RestartWindow
+	var err error
+	if w.Start, err = parseTimeOfDay(start); err != nil {
+		return RestartWindow{}, fmt.Errorf("invalid restart window %q: %w", s, err)
+	}
+	if w.End, err = parseTimeOfDay(end); err != nil {
+		return RestartWindow{}, fmt.Errorf("invalid restart window %q: %w", s, err)
+	}
+	if w.Start == w.End {
+		return RestartWindow{}, fmt.Errorf("invalid restart window %q: start and end are equal", s)
+	}
+	return w, nil
+}
+
+// parseTimeOfDay parses HH:MM as the time since midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (expected HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w RestartWindow) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return format(w.Start) + "-" + format(w.End)
+}
+
+// enabled reports whether the window restricts restarts at all
+func (w RestartWindow) enabled() bool {
+	return w.Start != w.End
+}
+
+// contains reports whether t falls within the window
+func (w RestartWindow) contains(t time.Time) bool {
+	offset := t.Sub(midnight(t, 0))
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// next returns when restarts are next allowed: t itself within the window,
+// otherwise the window's next start
+func (w RestartWindow) next(t time.Time) time.Time {
+	if w.contains(t) {
+		return t
+	}
+	start := midnight(t, 0).Add(w.Start)
+	if !start.After(t) {
+		start = midnight(t, 1).Add(w.Start)
+	}
+	return start
+}
+
+// midnight returns the start of the day days after t's
+func midnight(t time.Time, days int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, t.Location())
+}
+
+// nextAllowedRestart returns when a config-driven restart is next allowed,
+// or nil without a restart window
+func (m *Manager) nextAllowedRestart() *time.Time {
+	if !m.config.RestartWindow.enabled() {
+		return nil
+	}
+	next := m.config.RestartWindow.next(time.Now())
+	return &next
+}
+
+// deferToRestartWindow records a config-driven restart outside the restart
+// window, reporting whether it has to wait. Restarts deferred until the
+// window opens are applied as one
+func (m *Manager) deferToRestartWindow() bool {
+	window := m.config.RestartWindow
+	now := time.Now()
+	if !window.enabled() || window.contains(now) {
+		return false
+	}
+
+	next := window.next(now)
+	if !m.restartDeferred {
+		m.restartDeferred = true
+		m.restartWindowTimer = time.NewTimer(next.Sub(now))
+	}
+	logger.Info("Restart deferred until the restart window %v opens at %s", window, next.Format(time.DateTime))
+	return true
+}
+
+// restartWindowOpened fires once a deferred restart may proceed
+func (m *Manager) restartWindowOpened() <-chan time.Time {
+	if m.restartWindowTimer == nil {
+		return nil
+	}
+	return m.restartWindowTimer.C
+}
+
+// clearDeferredRestart forgets a deferred restart, once a newly started
+// child picked up the change anyway
+func (m *Manager) clearDeferredRestart() {
+	m.restartDeferred = false
+	if m.restartWindowTimer != nil {
+		m.restartWindowTimer.Stop()
+		m.restartWindowTimer = nil
+	}
+}
+
+// applyDeferredRestart restarts the child for the changes deferred until
+// the restart window. It reports whether the run loop has to return, and
+// with which error
+func (m *Manager) applyDeferredRestart() (bool, error) {
+	deferred := m.restartDeferred
+	m.clearDeferredRestart()
+	if !deferred || m.idle.Load() || m.keptStopped() {
+		return false, nil
+	}
+	if m.paused.Load() {
+		// Applied on resume instead
+		m.pausedConfigChange = true
+		return false, nil
+	}
+	if m.ctx.Err() != nil {
+		return true, m.shutdown(false)
+	}
+
+	logger.Info("Restart window %v open, restarting child process for deferred changes...", m.config.RestartWindow)
+	return m.reload(webhookReload, "config changed outside the restart window")
+}
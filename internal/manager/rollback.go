@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// errNoKnownGoodConfig is returned by rollBack when no healthy child was
+// started since the manager started, so there is nothing to roll back to
+var errNoKnownGoodConfig = errors.New("no known-good config to roll back to")
+
+// knownGoodConfig is the config file content the last healthy child was
+// started with, for RollbackOnFailedReload
+type knownGoodConfig struct {
+	content     []byte
+	mode        os.FileMode
+	fingerprint string
+	generation  uint64
+}
+
+// cacheKnownGoodConfig remembers the config file after a child started and
+// passed its readiness probe. The content is only cached if it is still
+// what the child was started with
+func (m *Manager) cacheKnownGoodConfig() {
+	path := m.configPath()
+	if !m.config.RollbackOnFailedReload || path == "" {
+		return
+	}
+
+	fingerprint := m.ConfigFingerprint()
+	if m.knownGood != nil && m.knownGood.fingerprint == fingerprint {
+		m.knownGood.generation = m.generation.Load()
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.Warn("Cannot cache known-good config %s: %v", path, err)
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		logger.Warn("Cannot cache known-good config %s: %v", path, err)
+		return
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != fingerprint {
+		logger.Debug("Config file %s changed since the child started, not caching it as known-good", path)
+		return
+	}
+
+	m.knownGood = &knownGoodConfig{
+		content:     content,
+		mode:        info.Mode().Perm(),
+		fingerprint: fingerprint,
+		generation:  m.generation.Load(),
+	}
+	logger.Debug("Cached config of generation %d as known-good (sha256 %s)", m.knownGood.generation, fingerprint)
+}
+
+// rollBack restores the known-good config after a reload failed and starts
+// the child with it again
+func (m *Manager) rollBack() error {
+	good := m.knownGood
+	if good == nil {
+		return errNoKnownGoodConfig
+	}
+
+	path := m.configPath()
+	logger.Warn("Rolling back %s to the config of generation %d (sha256 %s)", path, good.generation, good.fingerprint)
+	if err := writeFileAtomic(path, good.content, good.mode); err != nil {
+		return fmt.Errorf("failed to restore known-good config: %w", err)
+	}
+	rollbacksTotal.Inc()
+
+	if err := m.startChild(true); err != nil {
+		return fmt.Errorf("failed to start child with the known-good config: %w", err)
+	}
+	logger.Info("Rolled back to the config of generation %d, child process running", good.generation)
+	return nil
+}
+
+// writeFileAtomic replaces the file path resolves to with content, through a
+// temporary file renamed over it, so readers never see a partial file and a
+// symlinked path stays a symlink
+func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".flush-manager-rollback-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// unchangedConfig reports whether a config change can be ignored with
+// RollbackOnFailedReload because the file is as the running child read it,
+// such as after a rollback wrote it back
+func (m *Manager) unchangedConfig() bool {
+	if !m.config.RollbackOnFailedReload {
+		return false
+	}
+	current := m.currentFingerprint()
+	if current == "" || current != m.ConfigFingerprint() {
+		return false
+	}
+	logger.Info("Config file is unchanged from what the child was started with, not restarting")
+	return true
+}
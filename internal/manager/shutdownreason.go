@@ -0,0 +1,108 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/process"
+)
+
+// ShutdownCause says what made Run return
+type ShutdownCause string
+
+const (
+	// ShutdownSignal means SIGINT or SIGTERM was received
+	ShutdownSignal ShutdownCause = "signal"
+	// ShutdownChildExited means the child exited and was not started again
+	ShutdownChildExited ShutdownCause = "child exited"
+	// ShutdownContextCancelled means the manager's context was cancelled
+	ShutdownContextCancelled ShutdownCause = "context cancelled"
+	// ShutdownStartLimit means the child was started too often, see StartLimit
+	ShutdownStartLimit ShutdownCause = "start limit hit"
+	// ShutdownSelfUpdate means the manager's own binary was updated
+	ShutdownSelfUpdate ShutdownCause = "self-update"
+	// ShutdownIdle means no config change was seen for IdleTimeout, with IdleExit
+	ShutdownIdle ShutdownCause = "idle"
+	// ShutdownError means the manager gave up after an error
+	ShutdownError ShutdownCause = "error"
+)
+
+// ShutdownReason describes why the manager shut down
+type ShutdownReason struct {
+	Cause ShutdownCause
+	// Signal is the received signal, with ShutdownSignal
+	Signal os.Signal
+	// Exit is how the child exited, with ShutdownChildExited
+	Exit *process.ExitStatus
+}
+
+func (r ShutdownReason) String() string {
+	switch {
+	case r.Signal != nil:
+		return fmt.Sprintf("%s (%v)", r.Cause, r.Signal)
+	case r.Exit != nil:
+		return fmt.Sprintf("%s (%v)", r.Cause, r.Exit)
+	default:
+		return string(r.Cause)
+	}
+}
+
+// ExitError is returned by Run when it fails, wrapping the error with the
+// reason the manager shut down
+type ExitError struct {
+	ShutdownReason
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownReason returns why Run returned, or a zero ShutdownReason while it
+// is still running
+func (m *Manager) ShutdownReason() ShutdownReason {
+	if reason := m.shutdownReason.Load(); reason != nil {
+		return *reason
+	}
+	return ShutdownReason{}
+}
+
+// setShutdownReason records why the manager is shutting down. Only the first
+// reason is kept, so a second signal during shutdown does not mask the cause
+func (m *Manager) setShutdownReason(reason ShutdownReason) {
+	m.shutdownReason.CompareAndSwap(nil, &reason)
+}
+
+// shutdownSignal records sig as the reason the manager is shutting down
+func (m *Manager) shutdownSignal(sig os.Signal) {
+	m.setShutdownReason(ShutdownReason{Cause: ShutdownSignal, Signal: sig})
+}
+
+// reportShutdown logs why Run returns err, and wraps a non-nil err in an
+// ExitError. Exit paths that did not record a reason are told apart by err
+func (m *Manager) reportShutdown(err error) error {
+	switch {
+	case errors.Is(err, errStartLimit):
+		m.setShutdownReason(ShutdownReason{Cause: ShutdownStartLimit})
+	case errors.Is(err, ErrSelfUpdate):
+		m.setShutdownReason(ShutdownReason{Cause: ShutdownSelfUpdate})
+	case err != nil:
+		m.setShutdownReason(ShutdownReason{Cause: ShutdownError})
+	default:
+		// Without a signal or error, only a cancelled context ends the run
+		m.setShutdownReason(ShutdownReason{Cause: ShutdownContextCancelled})
+	}
+
+	reason := m.ShutdownReason()
+	logger.Info("Shutdown reason: %v", reason)
+	if err != nil {
+		return &ExitError{ShutdownReason: reason, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// SignalAction selects how the manager reacts to a signal
+type SignalAction int
+
+const (
+	// SignalShutdown shuts the manager and the child down gracefully
+	SignalShutdown SignalAction = iota
+	// SignalReload restarts the child, like a config change, and keeps the
+	// manager running
+	SignalReload
+)
+
+func (a SignalAction) String() string {
+	switch a {
+	case SignalShutdown:
+		return "shutdown"
+	case SignalReload:
+		return "reload"
+	default:
+		return fmt.Sprintf("SignalAction(%d)", int(a))
+	}
+}
+
+// ParseSignalAction parses an action name as returned by String
+func ParseSignalAction(name string) (SignalAction, error) {
+	for _, a := range []SignalAction{SignalShutdown, SignalReload} {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown signal action %q (expected shutdown or reload)", name)
+}
+
+// notifySignals routes SIGINT, and SIGTERM unless it reloads, to sigChan.
+// With SigtermAction set to SignalReload, SIGTERM goes to the returned
+// channel instead, so it never interrupts a drain, backoff or lame-duck
+// wait the way shutdown signals do. The returned function stops both
+func (m *Manager) notifySignals() (<-chan os.Signal, func()) {
+	if m.config.SigtermAction != SignalReload {
+		signal.Notify(m.sigChan, syscall.SIGINT, syscall.SIGTERM)
+		logger.Debug("Signal handlers registered for SIGINT and SIGTERM")
+		return nil, func() { signal.Stop(m.sigChan) }
+	}
+
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(m.sigChan, syscall.SIGINT)
+	signal.Notify(reloadChan, syscall.SIGTERM)
+	logger.Debug("Signal handlers registered for SIGINT, and for SIGTERM to reload")
+	return reloadChan, func() {
+		signal.Stop(m.sigChan)
+		signal.Stop(reloadChan)
+	}
+}
+
+// onReloadSignal restarts the child on a reload signal. It reports whether
+// the run loop has to return, and with which error
+func (m *Manager) onReloadSignal(sig os.Signal) (bool, error) {
+	if m.keptStopped() {
+		return false, nil
+	}
+	if m.idle.Load() {
+		return m.wake()
+	}
+	logger.Info("Received signal: %v, restarting child process...", sig)
+	return m.reload(webhookRestart, "received SIGTERM")
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// listenSocket is a socket bound by the manager and inherited by the child
+type listenSocket struct {
+	listener net.Listener
+	file     *os.File // duplicate of the listener's descriptor passed to the child
+}
+
+// listenSockets binds addrs, each host:port for TCP or unix:PATH for a Unix
+// socket. The sockets stay open for the manager's lifetime, so connections
+// queue up rather than being refused while the child restarts
+func listenSockets(addrs []string) ([]*listenSocket, error) {
+	var sockets []*listenSocket
+	for _, addr := range addrs {
+		network, address := "tcp", addr
+		if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+			network, address = "unix", path
+		}
+
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			closeListenSockets(sockets)
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		file, err := listener.(interface{ File() (*os.File, error) }).File()
+		if err != nil {
+			listener.Close()
+			closeListenSockets(sockets)
+			return nil, fmt.Errorf("failed to get descriptor of %s: %w", addr, err)
+		}
+
+		logger.Info("Listening on %s for the child (fd %d)", listener.Addr(), 3+len(sockets))
+		sockets = append(sockets, &listenSocket{listener: listener, file: file})
+	}
+	return sockets, nil
+}
+
+// listenFiles returns the descriptors of sockets in order
+func listenFiles(sockets []*listenSocket) []*os.File {
+	var files []*os.File
+	for _, s := range sockets {
+		files = append(files, s.file)
+	}
+	return files
+}
+
+// closeListenSockets closes sockets, removing the files of Unix sockets
+func closeListenSockets(sockets []*listenSocket) {
+	for _, s := range sockets {
+		if err := s.file.Close(); err != nil {
+			logger.Error("Error closing listen socket %s: %v", s.listener.Addr(), err)
+		}
+		if err := s.listener.Close(); err != nil {
+			logger.Error("Error closing listen socket %s: %v", s.listener.Addr(), err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/watcher"
+)
+
+// startChangeSources starts the custom change sources and forwards their
+// events to the run loop, where they count as config changes
+func (m *Manager) startChangeSources() error {
+	for i, source := range m.config.ChangeSources {
+		if err := source.Start(m.watchCtx); err != nil {
+			return fmt.Errorf("failed to start change source %d: %w", i, err)
+		}
+		m.forwarders.Add(1)
+		go m.forwardSourceChanges(source)
+	}
+	return nil
+}
+
+// forwardSourceChanges passes the events of source on to sourceChanges,
+// coalescing them while one is pending
+func (m *Manager) forwardSourceChanges(source watcher.ChangeSource) {
+	defer m.forwarders.Done()
+	for {
+		select {
+		case event := <-source.Changes():
+			select {
+			case m.sourceChanges <- event:
+			default:
+				logger.Debug("Change from %s coalesced into a pending one", describeEvent(event))
+			}
+		case <-m.watchCtx.Done():
+			return
+		}
+	}
+}
+
+// closeChangeSources closes the custom change sources
+func closeChangeSources(sources []watcher.ChangeSource) {
+	for i, source := range sources {
+		if err := source.Close(); err != nil {
+			logger.Error("Error closing change source %d: %v", i, err)
+		}
+	}
+}
+
+// describeEvent names the origin of a change event for logging
+func describeEvent(event watcher.ChangeEvent) string {
+	switch {
+	case event.Source != "" && event.Path != "":
+		return fmt.Sprintf("%s (%s)", event.Path, event.Source)
+	case event.Source != "":
+		return event.Source
+	case event.Path != "":
+		return event.Path
+	default:
+		return "change source"
+	}
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// StartLimit bounds how often the child may be started, like systemd's
+// StartLimitIntervalSec and StartLimitBurst. A zero value disables the limit
+type StartLimit struct {
+	// Interval is the sliding window in which starts are counted
+	Interval time.Duration
+
+	// Burst is the number of starts allowed within Interval
+	Burst int
+}
+
+// errStartLimit is returned when starting the child would exceed the start limit
+var errStartLimit = errors.New("start limit hit")
+
+// startLimiter tracks recent start times in a ring buffer of Burst entries
+type startLimiter struct {
+	limit StartLimit
+	mu    sync.Mutex
+	times []time.Time
+	next  int
+}
+
+func newStartLimiter(limit StartLimit) *startLimiter {
+	l := &startLimiter{limit: limit}
+	if l.enabled() {
+		l.times = make([]time.Time, limit.Burst)
+	}
+	return l
+}
+
+func (l *startLimiter) enabled() bool {
+	return l.limit.Interval > 0 && l.limit.Burst > 0
+}
+
+// allow records a start at now, or reports false if Burst starts already
+// happened within the last Interval
+func (l *startLimiter) allow(now time.Time) bool {
+	if !l.enabled() {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The slot about to be overwritten holds the oldest of the last Burst starts
+	oldest := l.times[l.next]
+	if !oldest.IsZero() && now.Sub(oldest) < l.limit.Interval {
+		return false
+	}
+
+	l.times[l.next] = now
+	l.next = (l.next + 1) % len(l.times)
+	return true
+}
+
+// count returns the number of starts within the last Interval
+func (l *startLimiter) count(now time.Time) int {
+	if !l.enabled() {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for _, t := range l.times {
+		if !t.IsZero() && now.Sub(t) < l.limit.Interval {
+			n++
+		}
+	}
+	return n
+}
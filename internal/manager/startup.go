@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// ErrStartupTimeout is returned by Run when the manager did not become
+// healthy within MaxStartupTime
+var ErrStartupTimeout = errors.New("manager did not become healthy in time")
+
+// beginStartup bounds the startup sequence by MaxStartupTime, if set. Init
+// commands, readiness probes and retry backoff run under the deadline; the
+// child itself does not, so it is not killed by it once started
+func (m *Manager) beginStartup() {
+	if m.config.MaxStartupTime <= 0 {
+		return
+	}
+	m.startupCtx, m.endStartupCtx = context.WithTimeout(m.ctx, m.config.MaxStartupTime)
+}
+
+// endStartup lifts the startup deadline once the manager is healthy
+func (m *Manager) endStartup() {
+	if m.endStartupCtx == nil {
+		return
+	}
+	m.endStartupCtx()
+	m.startupCtx, m.endStartupCtx = nil, nil
+}
+
+// opCtx returns the context bounding the manager's own steps: the startup
+// deadline while starting up, otherwise the manager's lifetime
+func (m *Manager) opCtx() context.Context {
+	if m.startupCtx != nil {
+		return m.startupCtx
+	}
+	return m.ctx
+}
+
+// startupTimeout returns ErrStartupTimeout if the startup deadline passed,
+// nil otherwise
+func (m *Manager) startupTimeout() error {
+	if m.startupCtx == nil || !errors.Is(m.startupCtx.Err(), context.DeadlineExceeded) {
+		return nil
+	}
+	logger.Error("Manager not healthy within the max startup time of %v", m.config.MaxStartupTime)
+	return fmt.Errorf("%w: not healthy within %v", ErrStartupTimeout, m.config.MaxStartupTime)
+}
@@ -0,0 +1,120 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/probe"
+	"github.com/zlrrr/flush-manager/internal/process"
+	"github.com/zlrrr/flush-manager/internal/watcher"
+)
+
+// Status is a point-in-time snapshot of the manager's state
+type Status struct {
+	// Ready reports whether /ready currently returns 200
+	Ready bool
+
+	// Idle reports whether the child is stopped after IdleTimeout and waits
+	// for the next config change to start
+	Idle bool
+
+	// Paused reports whether watching is paused by PauseWatching
+	Paused bool
+
+	// ChildStopped reports whether the child is meant to be down: stopped by
+	// StopChild and kept stopped until StartChild
+	ChildStopped bool
+
+	// PendingReloads is the number of config reloads waiting to be executed
+	PendingReloads int
+
+	// DroppedReloads is the number of config changes that were coalesced into
+	// an already pending reload or discarded at shutdown
+	DroppedReloads uint64
+
+	// LastExit describes how the child last exited on its own, if it has
+	LastExit *process.ExitStatus
+
+	// LastProbe is the most recent readiness probe result, if a probe is
+	// configured and has run
+	LastProbe *probe.Result
+
+	// RecentStarts is the number of child starts within the start limit
+	// interval, or 0 if no start limit is configured
+	RecentStarts int
+
+	// Exits lists the most recent child exits, oldest first, including
+	// restarts by the manager
+	Exits []ExitRecord
+
+	// Generation counts the child's start attempts, including restarts; the
+	// same number is logged as gen=N with everything the manager logs
+	Generation uint64
+
+	// Watches lists the paths watched for changes: fsnotify watches and
+	// polled files with what the last poll saw
+	Watches []watcher.WatchInfo
+
+	// NextAllowedRestart is when a config-driven restart is next allowed by
+	// the restart window: now while it is open, otherwise its next start.
+	// Nil without a restart window
+	NextAllowedRestart *time.Time
+
+	// ReloadCircuitOpenUntil is when the open reload circuit closes again,
+	// see CircuitBreaker. Nil while it is closed
+	ReloadCircuitOpenUntil *time.Time
+}
+
+// Status returns a snapshot of the manager's current state
+func (m *Manager) Status() Status {
+	return Status{
+		Ready:          m.isReady(),
+		Idle:           m.idle.Load(),
+		Paused:         m.paused.Load(),
+		ChildStopped:   m.childStopped.Load(),
+		PendingReloads: int(m.pendingReloads.Load()),
+		DroppedReloads: m.droppedReloads.Load(),
+		LastExit:       m.lastExit.Load(),
+		LastProbe:      m.lastProbe(),
+		RecentStarts:   m.startLimiter.count(time.Now()),
+		Exits:          m.exitHistory.list(),
+		Generation:     m.generation.Load(),
+		Watches:        m.activeWatches(),
+
+		NextAllowedRestart:     m.nextAllowedRestart(),
+		ReloadCircuitOpenUntil: m.circuitOpenUntil.Load(),
+	}
+}
+
+// lastProbe returns the last readiness probe result, if any
+func (m *Manager) lastProbe() *probe.Result {
+	if m.prober == nil {
+		return nil
+	}
+	return m.prober.LastResult()
+}
+
+// errStoppedBeforeReady is returned by WaitReady if the manager shuts down
+// before it finished starting
+var errStoppedBeforeReady = errors.New("manager stopped before becoming ready")
+
+// WaitReady blocks until Run has started the child and the file watchers and,
+// if configured, the child passed its readiness probe. It returns early if
+// ctx expires or the manager shuts down first
+func (m *Manager) WaitReady(ctx context.Context) error {
+	select {
+	case <-m.started:
+		return nil
+	default:
+	}
+
+	select {
+	case <-m.started:
+		return nil
+	case <-m.ctx.Done():
+		return errStoppedBeforeReady
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// TransitionState is a lifecycle state of the child, reported to
+// Config.OnTransition
+type TransitionState string
+
+const (
+	// TransitionStarting means a child is about to be spawned, after the
+	// init and flush commands ran. With a restart, the running child is
+	// stopped next
+	TransitionStarting TransitionState = "starting"
+	// TransitionStarted means the child was spawned
+	TransitionStarted TransitionState = "started"
+	// TransitionReady means the child passed its readiness probe, or was
+	// started without one
+	TransitionReady TransitionState = "ready"
+	// TransitionReloading means the child is restarted for a change
+	TransitionReloading TransitionState = "reloading"
+	// TransitionStopping means the manager is stopping the running child
+	TransitionStopping TransitionState = "stopping"
+	// TransitionStopped means the child stopped by the manager has exited.
+	// With a restart, it is reported once the replacement was spawned
+	TransitionStopped TransitionState = "stopped"
+	// TransitionExited means the child exited on its own
+	TransitionExited TransitionState = "exited"
+)
+
+// Transition is a change of the child's lifecycle state
+type Transition struct {
+	State TransitionState
+	Time  time.Time
+	// Generation is the child generation the transition is about
+	Generation uint64
+	// PID is the child's process ID, or 0 before the first one was spawned
+	PID int
+	// Detail says why, such as the reload reason or how the child exited
+	Detail string
+}
+
+// transition records a lifecycle state change and reports it to
+// Config.OnTransition, if set
+func (m *Manager) transition(state TransitionState, detail string) {
+	m.state = state
+	if state == TransitionExited {
+		defer endGeneration()
+	}
+	if m.config.OnTransition == nil {
+		return
+	}
+
+	t := Transition{
+		State:      state,
+		Time:       time.Now(),
+		Generation: m.generation.Load(),
+		PID:        m.processManager.PID(),
+		Detail:     detail,
+	}
+	logger.Debug("Lifecycle transition: %s %s", state, detail)
+	m.config.OnTransition(t)
+}
+
+// childRunning reports whether the last transition left a child running
+func (m *Manager) childRunning() bool {
+	switch m.state {
+	case "", TransitionStopped, TransitionExited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stopChild stops the running child gracefully, reporting the stop as
+// transitions unless the child already exited
+func (m *Manager) stopChild(reason string) error {
+	running := m.childRunning()
+	if running {
+		m.transition(TransitionStopping, reason)
+	}
+	err := m.processManager.Stop(m.config.ShutdownTimeout)
+	defer endGeneration()
+	if err != nil {
+		return err
+	}
+	if running {
+		m.transition(TransitionStopped, reason)
+	}
+	return nil
+}
+
+// endGeneration stops tagging log lines with the generation of a child that
+// is gone, so lines logged until the next start don't carry a stale gen=N
+func endGeneration() {
+	logger.ClearField("gen")
+}
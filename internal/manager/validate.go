@@ -0,0 +1,223 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/process"
+	"github.com/zlrrr/flush-manager/internal/watcher"
+)
+
+// Validate checks the configuration for invariants that would otherwise only
+// surface at runtime. Zero values are valid and mean the default. All problems
+// are reported together, one per line
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Command == "" {
+		add("command cannot be empty")
+	} else if _, err := exec.LookPath(c.Command); err != nil {
+		add("command %s cannot be resolved: %w", c.Command, err)
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"shutdown timeout", c.ShutdownTimeout},
+		{"output wait delay", c.OutputWaitDelay},
+		{"HTTP shutdown timeout", c.HTTPShutdownTimeout},
+		{"post-exit delay", c.PostExitDelay},
+		{"idle timeout", c.IdleTimeout},
+		{"lame duck period", c.LameDuckPeriod},
+		{"readiness interval", c.ReadinessInterval},
+		{"readiness timeout", c.ReadinessTimeout},
+		{"on-change timeout", c.OnChangeTimeout},
+		{"init timeout", c.InitTimeout},
+		{"flush timeout", c.FlushTimeout},
+		{"max startup time", c.MaxStartupTime},
+		{"poll interval", c.PollInterval},
+		{"stability window", c.WatcherStabilityWindow},
+		{"memory check interval", c.MemoryCheckInterval},
+		{"heartbeat interval", c.HeartbeatInterval},
+		{"restart initial backoff", c.RestartPolicy.InitialBackoff},
+		{"restart max backoff", c.RestartPolicy.MaxBackoff},
+		{"start limit interval", c.StartLimit.Interval},
+		{"reload circuit cooldown", c.ReloadCircuitBreaker.Cooldown},
+	} {
+		if d.value < 0 {
+			add("%s must not be negative, got %v", d.name, d.value)
+		}
+	}
+	if c.RestartPolicy.InitialBackoff > 0 && c.RestartPolicy.MaxBackoff > 0 &&
+		c.RestartPolicy.MaxBackoff < c.RestartPolicy.InitialBackoff {
+		add("restart max backoff %v is less than initial backoff %v",
+			c.RestartPolicy.MaxBackoff, c.RestartPolicy.InitialBackoff)
+	}
+
+	if c.RestartPolicy.MaxRetries < 0 {
+		add("restart max retries must not be negative, got %d", c.RestartPolicy.MaxRetries)
+	}
+	if c.StartLimit.Burst < 0 {
+		add("start limit burst must not be negative, got %d", c.StartLimit.Burst)
+	}
+	if c.ReadinessSuccessThreshold < 0 {
+		add("readiness success threshold must not be negative, got %d", c.ReadinessSuccessThreshold)
+	}
+	if c.ReadinessFailureThreshold < 0 {
+		add("readiness failure threshold must not be negative, got %d", c.ReadinessFailureThreshold)
+	}
+	if c.FlushRetries < 0 {
+		add("flush retries must not be negative, got %d", c.FlushRetries)
+	}
+	if c.FlushRequired && len(c.FlushCommand) == 0 {
+		add("flush required needs a flush command")
+	}
+	if c.MaxConfigSize < 0 {
+		add("max config size must not be negative, got %d", c.MaxConfigSize)
+	}
+	if c.RejectOversizedConfig && c.MaxConfigSize == 0 {
+		add("rejecting oversized config requires a max config size")
+	}
+	if w := c.RestartWindow; w.Start < 0 || w.Start >= 24*time.Hour || w.End < 0 || w.End >= 24*time.Hour {
+		add("restart window %v must start and end within a day", c.RestartWindow)
+	}
+	if c.ReloadCircuitBreaker.Failures < 0 {
+		add("reload circuit failures must not be negative, got %d", c.ReloadCircuitBreaker.Failures)
+	}
+	if c.ReloadCircuitBreaker.enabled() && !c.RollbackOnFailedReload {
+		add("reload circuit breaker requires rollback on failed reload")
+	}
+	if c.ChildStdin == StdinConfig && c.ConfigFilePath == "" {
+		add("child stdin from the config file requires a config file path")
+	}
+	if c.MemoryRestartThreshold < 0 {
+		add("memory restart threshold must not be negative, got %d", c.MemoryRestartThreshold)
+	}
+	if c.WebhookURL != "" {
+		if u, err := url.Parse(c.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("webhook URL %q is not an http or https URL", c.WebhookURL)
+		}
+	}
+	if len(c.WatchStrategies) > 0 && c.WatchMode != watcher.WatchAuto {
+		add("watch strategies cannot be combined with watch mode %s", c.WatchMode)
+	}
+	if c.ExitHistorySize < 0 {
+		add("exit history size must not be negative, got %d", c.ExitHistorySize)
+	}
+	if c.TeeOutput && c.AllocatePTY {
+		add("tee output cannot be combined with a PTY, whose output is always logged")
+	}
+	if c.ChildStdoutRateLimit < 0 || c.ChildStderrRateLimit < 0 {
+		add("child output rate limits must not be negative")
+	}
+
+	for _, code := range slices.Concat(c.RestartOnExitCodes, c.FatalExitCodes) {
+		if code < 0 || code > 255 {
+			add("exit code %d is out of range 0-255", code)
+		}
+	}
+	for _, code := range c.RestartOnExitCodes {
+		if slices.Contains(c.FatalExitCodes, code) {
+			add("exit code %d is both a restart and a fatal exit code", code)
+		}
+	}
+	if _, err := ParseExitAction(c.DefaultExitAction.String()); err != nil {
+		add("invalid default exit action %v", c.DefaultExitAction)
+	}
+
+	if c.ForceKillSignal != 0 && !process.IsTerminatingSignal(c.ForceKillSignal) {
+		add("force kill signal %v does not terminate the process", c.ForceKillSignal)
+	}
+	if c.SigtermAction != SignalShutdown && c.SigtermAction != SignalReload {
+		add("invalid SIGTERM action %v", c.SigtermAction)
+	}
+	if c.ReopenSignal == syscall.SIGINT || c.ReopenSignal == syscall.SIGTERM {
+		add("reopen signal %v conflicts with shutdown handling", c.ReopenSignal)
+	}
+
+	if c.OnChange.runsCommand() && len(c.OnChangeCommand) == 0 {
+		add("config change action %v requires an on-change command", c.OnChange)
+	}
+	if c.OnChange == ChangeRestart && len(c.OnChangeCommand) > 0 {
+		add("on-change command is never run with config change action %v", c.OnChange)
+	}
+	if c.NoRestartOnConfig && c.OnChange.restarts() {
+		add("config change action %v conflicts with no-restart-on-config", c.OnChange)
+	}
+
+	if c.IdleTimeout > 0 && c.NoRestartOnConfig && !c.IdleExit {
+		add("idle timeout conflicts with no-restart-on-config: the child would never be started again")
+	}
+	if c.IdleExit && c.IdleTimeout <= 0 {
+		add("idle exit requires an idle timeout")
+	}
+
+	if c.RequireConfigFile {
+		if err := checkConfigFile(c.ConfigFilePath); err != nil {
+			add("%v", err)
+		}
+	}
+
+	seen := map[string]bool{c.ConfigFilePath: c.ConfigFilePath != ""}
+	for _, w := range c.Watches {
+		switch {
+		case w.Path == "":
+			add("watched path cannot be empty")
+		case seen[w.Path]:
+			add("%s is watched more than once", w.Path)
+		}
+		seen[w.Path] = true
+		if w.Action == WatchCommand && len(w.Command) == 0 {
+			add("watch action %v for %s requires a command", w.Action, w.Path)
+		}
+		if w.Signal < 0 {
+			add("invalid signal %d for %s", int(w.Signal), w.Path)
+		}
+	}
+
+	for i, source := range c.ChangeSources {
+		if source == nil {
+			add("change source %d is nil", i)
+		}
+	}
+
+	for _, addr := range c.ListenSockets {
+		if addr == "" || addr == "unix:" {
+			add("listen socket address cannot be empty")
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		add("both TLS certificate and key are required")
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		add("TLS client CA requires a TLS certificate and key")
+	}
+	if (c.BasicAuthUser == "") != (c.BasicAuthPassword == "") {
+		add("both basic auth user and password are required")
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkConfigFile reports an error if the required config file at path does
+// not exist
+func checkConfigFile(path string) error {
+	if path == "" {
+		return errors.New("config file is required but no path is set")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("required config file is missing: %w", err)
+	}
+	return nil
+}
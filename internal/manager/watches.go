@@ -0,0 +1,200 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+	"github.com/zlrrr/flush-manager/internal/watcher"
+)
+
+// WatchAction selects what a change to an additional watched path does
+type WatchAction int
+
+const (
+	// WatchRestart restarts the child, honoring DrainSentinel like a config
+	// file change
+	WatchRestart WatchAction = iota
+	// WatchSignal sends Signal to the child so it reloads in place
+	WatchSignal
+	// WatchCommand runs Command and leaves the child running
+	WatchCommand
+)
+
+func (a WatchAction) String() string {
+	switch a {
+	case WatchRestart:
+		return "restart"
+	case WatchSignal:
+		return "signal"
+	case WatchCommand:
+		return "command"
+	default:
+		return fmt.Sprintf("WatchAction(%d)", int(a))
+	}
+}
+
+// ParseWatchAction parses an action name as returned by String
+func ParseWatchAction(name string) (WatchAction, error) {
+	for _, a := range []WatchAction{WatchRestart, WatchSignal, WatchCommand} {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown watch action %q (expected restart, signal or command)", name)
+}
+
+// WatchSpec maps an additional watched path to the action its changes
+// trigger, for children whose files reload differently
+type WatchSpec struct {
+	Path   string
+	Action WatchAction
+
+	// Signal is sent with WatchSignal (default SIGHUP)
+	Signal syscall.Signal
+
+	// Command is run with WatchCommand, bounded by OnChangeTimeout
+	Command []string
+}
+
+// pathWatch is a WatchSpec with its watcher
+type pathWatch struct {
+	spec   WatchSpec
+	fw     watcher.FileWatcher
+	binary bool // watches the child's executable, for WatchBinary
+}
+
+// newPathWatches creates a watcher for each spec, using the config file
+// watcher options
+func newPathWatches(config Config) ([]*pathWatch, error) {
+	var watches []*pathWatch
+	for _, spec := range config.Watches {
+		if spec.Action == WatchSignal && spec.Signal == 0 {
+			spec.Signal = syscall.SIGHUP
+		}
+		fw, err := newConfigWatcher(spec.Path, config)
+		if err != nil {
+			closePathWatches(watches)
+			return nil, fmt.Errorf("failed to create watcher for %s: %w", spec.Path, err)
+		}
+		watches = append(watches, &pathWatch{spec: spec, fw: fw})
+	}
+	if config.WatchBinary {
+		w, err := newBinaryWatch(config)
+		if err != nil {
+			closePathWatches(watches)
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	return watches, nil
+}
+
+// closePathWatches closes the watchers of watches
+func closePathWatches(watches []*pathWatch) {
+	for _, w := range watches {
+		if err := w.fw.Close(); err != nil {
+			logger.Error("Error closing watcher for %s: %v", w.spec.Path, err)
+		}
+	}
+}
+
+// activeWatches lists what the config file watcher, the additional path
+// watchers and the self watcher observe
+func (m *Manager) activeWatches() []watcher.WatchInfo {
+	watches := m.configWatcher().ActiveWatches()
+	for _, w := range m.pathWatches {
+		watches = append(watches, w.fw.ActiveWatches()...)
+	}
+	return append(watches, m.selfWatcher.ActiveWatches()...)
+}
+
+// handleWatches serves the active watches, one per line, to verify the
+// right paths are watched when a change went unnoticed
+func (m *Manager) handleWatches(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	watches := m.activeWatches()
+	if len(watches) == 0 {
+		fmt.Fprintln(w, "no active watches")
+		return
+	}
+	for _, watch := range watches {
+		fmt.Fprintln(w, watch)
+	}
+}
+
+// startPathWatches starts the additional watchers and forwards their changes
+// to the run loop, tagged with the watch they came from
+func (m *Manager) startPathWatches() error {
+	for _, w := range m.pathWatches {
+		if err := w.fw.Start(m.watchCtx); err != nil {
+			return fmt.Errorf("failed to start watcher for %s: %w", w.spec.Path, err)
+		}
+		logger.Info("Watching %s (action: %v)", w.spec.Path, w.spec.Action)
+		m.forwarders.Add(1)
+		go m.forwardPathChanges(w)
+	}
+	return nil
+}
+
+// forwardPathChanges sends w to pathChanges on every change of its path
+func (m *Manager) forwardPathChanges(w *pathWatch) {
+	defer m.forwarders.Done()
+	for {
+		select {
+		case <-w.fw.Changes():
+			select {
+			case m.pathChanges <- w:
+			case <-m.watchCtx.Done():
+				return
+			}
+		case <-m.watchCtx.Done():
+			return
+		}
+	}
+}
+
+// onPathChange runs the action of the watch whose path changed. It reports
+// whether the run loop has to return, and with which error
+func (m *Manager) onPathChange(w *pathWatch) (bool, error) {
+	if m.ctx.Err() != nil {
+		// Picked over the cancellation; shutting down takes precedence
+		return true, m.shutdown(false)
+	}
+	if w.binary && !binaryExecutable(w.spec.Path) {
+		return false, nil
+	}
+	watchedChangesTotal.With(w.spec.Action.String()).Inc()
+	if m.keptStopped() {
+		return false, nil
+	}
+	if m.idle.Load() {
+		return m.wake()
+	}
+	m.resetIdleTimer()
+
+	switch w.spec.Action {
+	case WatchSignal:
+		logger.Info("Watched file %s changed, sending %v to child", w.spec.Path, w.spec.Signal)
+		if err := m.processManager.Signal(w.spec.Signal); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			logger.Error("Failed to signal child for %s: %v", w.spec.Path, err)
+		}
+		return false, nil
+
+	case WatchCommand:
+		logger.Info("Watched file %s changed, running command: %v", w.spec.Path, w.spec.Command)
+		m.runChangeCommand(w.spec.Command)
+		return false, nil
+
+	default:
+		if m.deferToRestartWindow() {
+			return false, nil
+		}
+		logger.Info("Watched file %s changed, restarting child process...", w.spec.Path)
+		return m.reload(webhookRestart, fmt.Sprintf("watched file %s changed", w.spec.Path))
+	}
+}
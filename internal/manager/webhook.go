@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// Webhook event types
+const (
+	// webhookRestart is sent when the child was restarted for a reason
+	// other than a config change
+	webhookRestart = "restart"
+	// webhookReload is sent when a config change was applied
+	webhookReload = "reload"
+)
+
+const (
+	// webhookTimeout bounds each webhook delivery attempt
+	webhookTimeout = 5 * time.Second
+
+	// webhookAttempts is how often a failed delivery is tried in total
+	webhookAttempts = 3
+
+	// webhookRetryDelay is the delay before the first retry, doubled on
+	// each further one
+	webhookRetryDelay = time.Second
+
+	// WebhookSignatureHeader carries the hex HMAC-SHA256 of the payload,
+	// keyed with WebhookSecret, as "sha256=<hex>"
+	WebhookSignatureHeader = "X-Flush-Manager-Signature"
+)
+
+// webhookPayload is the JSON body POSTed to WebhookURL
+type webhookPayload struct {
+	Event       string    `json:"event"`
+	Generation  uint64    `json:"generation"`
+	PID         int       `json:"pid"`
+	Reason      string    `json:"reason"`
+	Timestamp   time.Time `json:"timestamp"`
+	Fingerprint string    `json:"config_fingerprint,omitempty"`
+}
+
+// notifyWebhook reports event to WebhookURL, if set. The delivery runs in the
+// background, so a slow or failing receiver never holds up the run loop
+func (m *Manager) notifyWebhook(event, reason string) {
+	if m.config.WebhookURL == "" {
+		return
+	}
+
+	payload := webhookPayload{
+		Event:      event,
+		Generation: m.generation.Load(),
+		PID:        m.processManager.PID(),
+		Reason:     reason,
+		Timestamp:  time.Now().UTC(),
+	}
+	if fingerprint := m.fingerprint.Load(); fingerprint != nil {
+		payload.Fingerprint = *fingerprint
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("Failed to encode webhook payload: %v", err)
+		return
+	}
+	go m.deliverWebhook(event, payload.Generation, body)
+}
+
+// deliverWebhook POSTs body to WebhookURL, retrying failed attempts with
+// backoff. Deliveries still pending when the manager stops are dropped. Its
+// messages name the generation gen the event is about, which the gen field
+// of the log lines no longer is once another child started
+func (m *Manager) deliverWebhook(event string, gen uint64, body []byte) {
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := m.postWebhook(body)
+		if err == nil {
+			webhooksTotal.With("success").Inc()
+			logger.Debug("Webhook for %s event of generation %d delivered", event, gen)
+			return
+		}
+		if attempt == webhookAttempts {
+			webhooksTotal.With("failure").Inc()
+			logger.Error("Failed to deliver webhook for %s event of generation %d after %d attempts: %v", event, gen, attempt, err)
+			return
+		}
+		logger.Warn("Webhook for %s event of generation %d failed: %v, retrying in %v", event, gen, err, delay)
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// postWebhook makes a single delivery attempt
+func (m *Manager) postWebhook(body []byte) error {
+	ctx, cancel := context.WithTimeout(m.ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.config.WebhookSecret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhook(body, m.config.WebhookSecret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of body keyed with secret
+func signWebhook(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
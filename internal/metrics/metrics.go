@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// metric is a single named time series that can render itself
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds a set of metrics and renders them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// Default is the registry used by the package-level constructors
+var Default = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register adds m to the registry, panicking on duplicate names
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.metrics[m.name()]; exists {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", m.name()))
+	}
+	r.metrics[m.name()] = m
+}
+
+// Write renders all metrics in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler returns an HTTP handler serving the registry's metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.Write(w)
+	})
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	metricName string
+	help       string
+	value      atomic.Uint64
+}
+
+// NewCounter creates a counter and registers it in the default registry
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounter(name, help)
+}
+
+// NewCounter creates a counter and registers it in r
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{metricName: name, help: help}
+	r.register(c)
+	return c
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add increments the counter by n
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+func (c *Counter) name() string {
+	return c.metricName
+}
+
+func (c *Counter) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", c.metricName, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.metricName)
+	fmt.Fprintf(w, "%s %d\n", c.metricName, c.Value())
+}
+
+// CounterVec is a family of counters partitioned by the value of one label
+type CounterVec struct {
+	metricName string
+	help       string
+	label      string
+	mu         sync.Mutex
+	counters   map[string]*Counter
+}
+
+// NewCounterVec creates a counter family and registers it in the default
+// registry
+func NewCounterVec(name, help, label string) *CounterVec {
+	return Default.NewCounterVec(name, help, label)
+}
+
+// NewCounterVec creates a counter family and registers it in r
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{metricName: name, help: help, label: label, counters: make(map[string]*Counter)}
+	r.register(v)
+	return v
+}
+
+// With returns the counter for the given label value, creating it if needed
+func (v *CounterVec) With(value string) *Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c, ok := v.counters[value]
+	if !ok {
+		c = &Counter{metricName: v.metricName, help: v.help}
+		v.counters[value] = c
+	}
+	return c
+}
+
+func (v *CounterVec) name() string {
+	return v.metricName
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	v.mu.Lock()
+	values := make([]string, 0, len(v.counters))
+	for value := range v.counters {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	counters := make([]*Counter, 0, len(values))
+	for _, value := range values {
+		counters = append(counters, v.counters[value])
+	}
+	v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, v.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", v.metricName)
+	for i, c := range counters {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", v.metricName, v.label, values[i], c.Value())
+	}
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	metricName string
+	help       string
+	bits       atomic.Uint64
+}
+
+// NewGauge creates a gauge and registers it in the default registry
+func NewGauge(name, help string) *Gauge {
+	return Default.NewGauge(name, help)
+}
+
+// NewGauge creates a gauge and registers it in r
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{metricName: name, help: help}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) name() string {
+	return g.metricName
+}
+
+func (g *Gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.metricName, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.metricName)
+	fmt.Fprintf(w, "%s %g\n", g.metricName, g.Value())
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_events_total", "Number of test events")
+
+	c.Inc()
+	c.Add(2)
+	assert.Equal(t, uint64(3), c.Value())
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	assert.Contains(t, buf.String(), "# TYPE test_events_total counter\n")
+	assert.Contains(t, buf.String(), "test_events_total 3\n")
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("test_queue_depth", "Depth of the test queue")
+
+	g.Set(2)
+	g.Set(1.5)
+	assert.Equal(t, 1.5, g.Value())
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	assert.Contains(t, buf.String(), "# TYPE test_queue_depth gauge\n")
+	assert.Contains(t, buf.String(), "test_queue_depth 1.5\n")
+}
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewCounterVec("test_requests_total", "Number of test requests", "code")
+
+	v.With("500").Inc()
+	v.With("200").Add(2)
+	v.With("200").Inc()
+	assert.Equal(t, uint64(3), v.With("200").Value())
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	out := buf.String()
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("# TYPE test_requests_total counter\n")))
+	assert.Contains(t, out, "test_requests_total{code=\"200\"} 3\n")
+	assert.Contains(t, out, "test_requests_total{code=\"500\"} 1\n")
+	assert.Less(t, bytes.Index(buf.Bytes(), []byte(`code="200"`)), bytes.Index(buf.Bytes(), []byte(`code="500"`)))
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("duplicate names panic", func(t *testing.T) {
+		r := NewRegistry()
+		r.NewCounter("dup", "first")
+		assert.Panics(t, func() {
+			r.NewGauge("dup", "second")
+		})
+	})
+
+	t.Run("metrics are sorted by name", func(t *testing.T) {
+		r := NewRegistry()
+		r.NewCounter("b_total", "b")
+		r.NewCounter("a_total", "a")
+
+		var buf bytes.Buffer
+		r.Write(&buf)
+		assert.Less(t, bytes.Index(buf.Bytes(), []byte("a_total")), bytes.Index(buf.Bytes(), []byte("b_total")))
+	})
+
+	t.Run("handler serves text format", func(t *testing.T) {
+		r := NewRegistry()
+		r.NewCounter("served_total", "served").Inc()
+
+		rec := httptest.NewRecorder()
+		r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		body, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+		assert.Contains(t, string(body), "served_total 1\n")
+	})
+}
@@ -0,0 +1,340 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// Probe checks whether the child is ready to serve
+type Probe interface {
+	// Check returns nil if the child is ready
+	Check(ctx context.Context) error
+
+	// String describes the probe for logs
+	String() string
+}
+
+// TCP succeeds when a TCP connection to Address can be established
+type TCP struct {
+	Address string
+}
+
+// Check dials the address
+func (p TCP) Check(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p TCP) String() string {
+	return "tcp://" + p.Address
+}
+
+// HTTP succeeds when a GET to URL returns a 2xx or 3xx status
+type HTTP struct {
+	URL string
+}
+
+// Check performs the GET request
+func (p HTTP) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (p HTTP) String() string {
+	return p.URL
+}
+
+// maxExecOutput bounds how much of a failed command's output is reported
+const maxExecOutput = 512
+
+// Exec succeeds when Command, run without a shell, exits with code 0, e.g.
+// redis-cli ping. The check's timeout kills it
+type Exec struct {
+	Command []string
+}
+
+// Check runs the command. On failure the error includes its combined
+// output, for diagnostics
+func (p Exec) Check(ctx context.Context) error {
+	if len(p.Command) == 0 {
+		return errors.New("no command")
+	}
+
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = errors.New("timed out")
+	}
+
+	out := strings.TrimSpace(string(output))
+	if out == "" {
+		return err
+	}
+	if len(out) > maxExecOutput {
+		out = out[:maxExecOutput] + "..."
+	}
+	return fmt.Errorf("%w: %s", err, out)
+}
+
+func (p Exec) String() string {
+	return "exec:" + strings.Join(p.Command, " ")
+}
+
+// File succeeds once the child created the marker file Path, for processes
+// that signal readiness by touching a file. With Content, the file must also
+// contain it. As a Resetter, the manager deletes Path before every (re)start
+type File struct {
+	Path    string
+	Content string
+}
+
+// Check looks for the file and, with Content, reads it
+func (p File) Check(ctx context.Context) error {
+	if p.Content == "" {
+		_, err := os.Stat(p.Path)
+		return err
+	}
+
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), p.Content) {
+		return fmt.Errorf("%s does not contain %q", p.Path, p.Content)
+	}
+	return nil
+}
+
+// Reset deletes the file at Path, so a marker left by the previous child
+// does not count for the next. The manager calls it before every (re)start,
+// so Path must be a file only the child writes
+func (p File) Reset() error {
+	if err := os.Remove(p.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (p File) String() string {
+	return "file:" + p.Path
+}
+
+// Resetter is implemented by probes whose state outlives the child. The
+// manager resets them before every (re)start, once the previous child was
+// stopped
+type Resetter interface {
+	Reset() error
+}
+
+// Result is the outcome of a single probe check
+type Result struct {
+	Success bool
+	Time    time.Time
+	Latency time.Duration
+	Error   string
+}
+
+// String summarizes the result for logs and the /ready body
+func (r Result) String() string {
+	if r.Success {
+		return fmt.Sprintf("pass at %s (latency %v)", r.Time.Format(time.RFC3339), r.Latency)
+	}
+	return fmt.Sprintf("fail at %s (latency %v): %s", r.Time.Format(time.RFC3339), r.Latency, r.Error)
+}
+
+// Prober runs a probe on an interval and records the last result. Like
+// Kubernetes probes, a single result does not flip readiness: the probe has
+// to pass successThreshold times in a row to count as passing, and, once
+// passing, fail failureThreshold times in a row to count as failing
+type Prober struct {
+	probe            Probe
+	interval         time.Duration
+	successThreshold int
+	failureThreshold int
+	mu               sync.Mutex
+	last             *Result
+	successes        int  // consecutive successful checks
+	failures         int  // consecutive failed checks
+	failing          bool // failureThreshold was reached since the last pass
+}
+
+// NewProber creates a prober that checks p every interval
+// Each check is bounded by the interval as well. Thresholds below 1 mean 1
+func NewProber(p Probe, interval time.Duration, successThreshold, failureThreshold int) *Prober {
+	return &Prober{
+		probe:            p,
+		interval:         interval,
+		successThreshold: max(successThreshold, 1),
+		failureThreshold: max(failureThreshold, 1),
+	}
+}
+
+// Check runs the probe once and records the result
+func (p *Prober) Check(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	start := time.Now()
+	err := p.probe.Check(ctx)
+	result := Result{
+		Success: err == nil,
+		Time:    start,
+		Latency: time.Since(start),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	p.mu.Lock()
+	p.last = &result
+	if result.Success {
+		p.successes++
+		p.failures = 0
+		if p.successes >= p.successThreshold {
+			p.failing = false
+		}
+	} else {
+		p.failures++
+		p.successes = 0
+		if p.failures >= p.failureThreshold {
+			p.failing = true
+		}
+	}
+	p.mu.Unlock()
+
+	return result
+}
+
+// passing reports whether the probe passed successThreshold times in a row
+func (p *Prober) passing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.successes >= p.successThreshold
+}
+
+// Failing reports whether the probe failed failureThreshold times in a row
+// and has not passed successThreshold times in a row since
+func (p *Prober) Failing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.failing
+}
+
+// reset forgets the consecutive results, as for a newly started child
+func (p *Prober) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.successes = 0
+	p.failures = 0
+	p.failing = false
+}
+
+// WaitReady checks the probe until it passes successThreshold times in a
+// row or ctx is done
+func (p *Prober) WaitReady(ctx context.Context) error {
+	logger.Info("Waiting for readiness probe %s", p.probe)
+	p.reset()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		result := p.Check(ctx)
+		if p.passing() {
+			logger.Info("Readiness probe %s passed (latency %v)", p.probe, result.Latency)
+			return nil
+		}
+		if result.Success {
+			logger.Debug("Readiness probe %s passed, waiting for %d consecutive passes", p.probe, p.successThreshold)
+		} else {
+			logger.Debug("Readiness probe %s failed: %s", p.probe, result.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("readiness probe %s did not pass: %s", p.probe, result.Error)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Monitor keeps checking the probe every interval until ctx is done, skipping
+// checks while active reports false, e.g. while the child is restarting.
+// Whether the probe is failing is reported by Failing
+func (p *Prober) Monitor(ctx context.Context, active func() bool) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !active() {
+			continue
+		}
+
+		wasFailing := p.Failing()
+		result := p.Check(ctx)
+		switch failing := p.Failing(); {
+		case failing && !wasFailing:
+			logger.Warn("Readiness probe %s failed %d times in a row, reporting not ready: %s", p.probe, p.failureThreshold, result.Error)
+		case !failing && wasFailing:
+			logger.Info("Readiness probe %s passed %d times in a row, reporting ready again", p.probe, p.successThreshold)
+		case !result.Success:
+			logger.Debug("Readiness probe %s failed: %s", p.probe, result.Error)
+		}
+	}
+}
+
+// LastResult returns the most recent result, or nil if the probe never ran
+func (p *Prober) LastResult() *Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.last == nil {
+		return nil
+	}
+	result := *p.last
+	return &result
+}
+
+// String describes the underlying probe
+func (p *Prober) String() string {
+	return p.probe.String()
+}
@@ -0,0 +1,202 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+
+	p := TCP{Address: addr}
+	assert.NoError(t, p.Check(context.Background()))
+	assert.Equal(t, "tcp://"+addr, p.String())
+
+	listener.Close()
+	assert.Error(t, p.Check(context.Background()))
+}
+
+func TestHTTP(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	p := HTTP{URL: server.URL}
+	assert.NoError(t, p.Check(context.Background()))
+
+	status.Store(http.StatusServiceUnavailable)
+	assert.ErrorContains(t, p.Check(context.Background()), "503")
+}
+
+func TestExec(t *testing.T) {
+	p := Exec{Command: []string{"true"}}
+	assert.NoError(t, p.Check(context.Background()))
+	assert.Equal(t, "exec:true", p.String())
+
+	t.Run("failure reports output", func(t *testing.T) {
+		p := Exec{Command: []string{"sh", "-c", "echo 'Could not connect to Redis' >&2; exit 1"}}
+		err := p.Check(context.Background())
+		assert.ErrorContains(t, err, "exit status 1: Could not connect to Redis")
+	})
+
+	t.Run("timeout kills the command", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := Exec{Command: []string{"sleep", "10"}}.Check(ctx)
+		assert.ErrorContains(t, err, "timed out")
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("empty command", func(t *testing.T) {
+		assert.Error(t, Exec{}.Check(context.Background()))
+	})
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ready")
+	p := File{Path: path}
+	assert.Equal(t, "file:"+path, p.String())
+	assert.Error(t, p.Check(context.Background()))
+
+	require.NoError(t, os.WriteFile(path, []byte("starting\n"), 0644))
+	assert.NoError(t, p.Check(context.Background()))
+
+	t.Run("content", func(t *testing.T) {
+		p := File{Path: path, Content: "ready"}
+		assert.ErrorContains(t, p.Check(context.Background()), `does not contain "ready"`)
+		require.NoError(t, os.WriteFile(path, []byte("ready\n"), 0644))
+		assert.NoError(t, p.Check(context.Background()))
+	})
+
+	t.Run("reset removes the marker", func(t *testing.T) {
+		require.NoError(t, p.Reset())
+		assert.Error(t, p.Check(context.Background()))
+		assert.NoError(t, p.Reset())
+	})
+}
+
+// funcProbe adapts a function to the Probe interface
+type funcProbe func(ctx context.Context) error
+
+func (f funcProbe) Check(ctx context.Context) error { return f(ctx) }
+func (f funcProbe) String() string                  { return "func" }
+
+func TestProber_WaitReady(t *testing.T) {
+	t.Run("passes once probe succeeds", func(t *testing.T) {
+		var calls atomic.Int32
+		p := NewProber(funcProbe(func(ctx context.Context) error {
+			if calls.Add(1) < 3 {
+				return assert.AnError
+			}
+			return nil
+		}), 10*time.Millisecond, 1, 1)
+
+		err := p.WaitReady(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+
+		last := p.LastResult()
+		require.NotNil(t, last)
+		assert.True(t, last.Success)
+	})
+
+	t.Run("fails when context expires", func(t *testing.T) {
+		p := NewProber(funcProbe(func(ctx context.Context) error {
+			return assert.AnError
+		}), 10*time.Millisecond, 1, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := p.WaitReady(ctx)
+		assert.Error(t, err)
+
+		last := p.LastResult()
+		require.NotNil(t, last)
+		assert.False(t, last.Success)
+		assert.Equal(t, assert.AnError.Error(), last.Error)
+	})
+
+	t.Run("no result before first check", func(t *testing.T) {
+		p := NewProber(TCP{Address: "127.0.0.1:1"}, time.Second, 1, 1)
+		assert.Nil(t, p.LastResult())
+	})
+}
+
+func TestProber_Thresholds(t *testing.T) {
+	var healthy atomic.Bool
+	check := funcProbe(func(ctx context.Context) error {
+		if !healthy.Load() {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	t.Run("ready after consecutive passes", func(t *testing.T) {
+		var calls atomic.Int32
+		p := NewProber(funcProbe(func(ctx context.Context) error {
+			// Passes, fails once, then keeps passing
+			if calls.Add(1) == 2 {
+				return assert.AnError
+			}
+			return nil
+		}), 10*time.Millisecond, 3, 1)
+
+		assert.NoError(t, p.WaitReady(context.Background()))
+		assert.Equal(t, int32(5), calls.Load())
+	})
+
+	t.Run("failing after consecutive failures", func(t *testing.T) {
+		healthy.Store(true)
+		p := NewProber(check, time.Second, 2, 3)
+		ctx := context.Background()
+
+		healthy.Store(false)
+		p.Check(ctx)
+		p.Check(ctx)
+		assert.False(t, p.Failing())
+		p.Check(ctx)
+		assert.True(t, p.Failing())
+
+		healthy.Store(true)
+		p.Check(ctx)
+		assert.True(t, p.Failing(), "one pass is below the success threshold")
+		p.Check(ctx)
+		assert.False(t, p.Failing())
+	})
+
+	t.Run("monitor skips checks while inactive", func(t *testing.T) {
+		healthy.Store(false)
+		var active atomic.Bool
+		p := NewProber(check, 10*time.Millisecond, 1, 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go p.Monitor(ctx, active.Load)
+
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, p.LastResult())
+		assert.False(t, p.Failing())
+
+		active.Store(true)
+		assert.Eventually(t, p.Failing, time.Second, 10*time.Millisecond)
+		healthy.Store(true)
+		assert.Eventually(t, func() bool { return !p.Failing() }, time.Second, 10*time.Millisecond)
+	})
+}
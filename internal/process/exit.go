@@ -0,0 +1,69 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// ExitKind classifies how a process terminated
+type ExitKind int
+
+const (
+	ExitKindUnknown ExitKind = iota
+	ExitedWithCode           // Process called exit with a status code
+	Signaled                 // Process was terminated by a signal
+	Stopped                  // Process was ended by Stop, with a code or a signal
+)
+
+// ExitStatus describes how a process terminated
+type ExitStatus struct {
+	Kind   ExitKind
+	Code   int            // Exit code, valid when Kind is ExitedWithCode or Stopped
+	Signal syscall.Signal // Signal, valid when Kind is Signaled, or Stopped with a signal
+}
+
+// String returns a human-readable description of the exit status
+func (s ExitStatus) String() string {
+	switch s.Kind {
+	case ExitedWithCode:
+		return fmt.Sprintf("exited with code %d", s.Code)
+	case Signaled:
+		return fmt.Sprintf("killed by signal %d (%v)", int(s.Signal), s.Signal)
+	case Stopped:
+		if s.Signal != 0 {
+			return fmt.Sprintf("stopped, killed by signal %d (%v)", int(s.Signal), s.Signal)
+		}
+		return fmt.Sprintf("stopped, exited with code %d", s.Code)
+	default:
+		return "unknown exit"
+	}
+}
+
+// ClassifyExit inspects the error returned by Wait to determine whether the
+// process exited with a code or was killed by a signal
+func ClassifyExit(err error) ExitStatus {
+	if err == nil {
+		return ExitStatus{Kind: ExitedWithCode, Code: 0}
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return ExitStatus{Kind: ExitKindUnknown}
+	}
+
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ExitStatus{Kind: ExitedWithCode, Code: exitErr.ExitCode()}
+	}
+
+	switch {
+	case ws.Exited():
+		return ExitStatus{Kind: ExitedWithCode, Code: ws.ExitStatus()}
+	case ws.Signaled():
+		return ExitStatus{Kind: Signaled, Signal: ws.Signal()}
+	default:
+		return ExitStatus{Kind: ExitKindUnknown}
+	}
+}
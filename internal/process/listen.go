@@ -0,0 +1,22 @@
+package process
+
+import "fmt"
+
+// listenPIDScript exports the shell's PID, which the child keeps once the
+// shell execs it. systemd's convention requires LISTEN_PID to match the
+// receiving process, and exec.Cmd cannot set it after forking
+const listenPIDScript = `export LISTEN_PID=$$; exec "$0" "$@"`
+
+// withListenFDs wraps command and args so the child receives n listen files
+// following systemd's socket activation convention: descriptors 3 to 3+n-1,
+// LISTEN_FDS=n and LISTEN_PID set to the child's PID. It returns the command,
+// its arguments and the environment to add
+func withListenFDs(command string, args []string, n int) (string, []string, []string) {
+	wrapped := append([]string{"-c", listenPIDScript, command}, args...)
+	env := []string{
+		fmt.Sprintf("LISTEN_FDS=%d", n),
+		// Names inherited from a socket-activated manager don't apply
+		"LISTEN_FDNAMES=",
+	}
+	return "/bin/sh", wrapped, env
+}
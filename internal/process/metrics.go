@@ -0,0 +1,13 @@
+package process
+
+import "github.com/zlrrr/flush-manager/internal/metrics"
+
+// Metrics exported by the process manager on /metrics
+var (
+	droppedLinesTotal = metrics.NewCounter("flushmanager_child_log_lines_dropped_total",
+		"Number of child output lines dropped by the logger rate limit")
+	closedOutputsTotal = metrics.NewCounter("flushmanager_child_output_closed_total",
+		"Number of times the child closed its stdout or stderr while still running")
+	forcedKillsTotal = metrics.NewCounter("flushmanager_forced_kills_total",
+		"Number of child stops that escalated to the force kill signal after the stop timeout")
+)
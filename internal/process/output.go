@@ -0,0 +1,253 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// Special output destinations; any other value is a file path
+const (
+	OutputInherit = "inherit" // the manager's own stdout/stderr (default)
+	OutputLogger  = "logger"  // each line logged through the logger
+	OutputDiscard = "discard" // dropped
+)
+
+// Output is the destination of one of the child's output streams
+type Output struct {
+	dest    string
+	stream  string
+	mu      sync.Mutex
+	file    *os.File
+	line    []byte
+	limit   *tokenBucket
+	dropped int
+}
+
+// NewOutput creates the destination for stream ("stdout" or "stderr") from
+// dest, which is one of the Output* values or a file path. Files are opened
+// in append mode immediately, so misconfiguration fails fast
+func NewOutput(dest, stream string) (*Output, error) {
+	if dest == "" {
+		dest = OutputInherit
+	}
+
+	o := &Output{dest: dest, stream: stream}
+	if o.isFile() {
+		if err := o.Reopen(); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// SetRateLimit limits lines sent to the logger to linesPerSec, with bursts of
+// up to a second's worth. Excess lines are dropped and counted, and the count
+// is logged once lines are let through again. 0 disables the limit
+func (o *Output) SetRateLimit(linesPerSec float64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.limit = nil
+	if linesPerSec > 0 {
+		o.limit = newTokenBucket(linesPerSec, time.Now())
+	}
+}
+
+// isFile reports whether the destination is a file path
+func (o *Output) isFile() bool {
+	switch o.dest {
+	case OutputInherit, OutputLogger, OutputDiscard:
+		return false
+	}
+	return true
+}
+
+// target returns the writer to attach to the child. Inherited streams are
+// passed as files so the child writes to them directly, without a pipe
+func (o *Output) target() io.Writer {
+	switch o.dest {
+	case OutputInherit:
+		if o.stream == "stderr" {
+			return os.Stderr
+		}
+		return os.Stdout
+	case OutputDiscard:
+		return nil
+	}
+	return o
+}
+
+// tee returns a writer sending to both dest, which may be nil, and capture
+func tee(dest, capture io.Writer) io.Writer {
+	if dest == nil {
+		return capture
+	}
+	return io.MultiWriter(dest, capture)
+}
+
+// outputEOFGrace is how long the child may take to exit after closing an
+// output stream before it counts as having closed it while still running
+const outputEOFGrace = time.Second
+
+// outputPipe forwards one of the child's output streams to a writer. Owning
+// the pipe, instead of leaving it to os/exec, tells the child closing the
+// stream while it keeps running apart from the child exiting
+type outputPipe struct {
+	stream string
+	dest   io.Writer
+	r, w   *os.File
+	done   chan struct{} // closed once forwarding stopped
+}
+
+// pipeOutput returns dest if the child can write to it directly, and
+// otherwise the write end of a pipe to be forwarded to dest once started
+func pipeOutput(dest io.Writer, stream string) (io.Writer, *outputPipe, error) {
+	if _, ok := dest.(*os.File); ok || dest == nil {
+		return dest, nil, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s pipe: %w", stream, err)
+	}
+	return w, &outputPipe{stream: stream, dest: dest, r: r, w: w, done: make(chan struct{})}, nil
+}
+
+// forward copies the stream until the child closes it. If the child is still
+// running then, it warns that the stream is no longer forwarded; exited is
+// closed once the child has exited
+func (p *outputPipe) forward(exited <-chan struct{}) {
+	defer close(p.done)
+
+	if _, err := io.Copy(p.dest, p.r); err != nil && !errors.Is(err, os.ErrClosed) {
+		// Close the pipe so the child gets EPIPE instead of blocking on it
+		logger.Error("Failed to forward child %s, dropping it: %v", p.stream, err)
+		p.r.Close()
+		return
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(outputEOFGrace):
+		closedOutputsTotal.Inc()
+		logger.Warn("Child closed its %s but is still running, its %s output is no longer captured", p.stream, p.stream)
+	}
+}
+
+// drainOutputs waits for pipes to forward what the child wrote before it
+// exited, at most until deadline in case a grandchild holds them open, and
+// closes them
+func drainOutputs(pipes []*outputPipe, deadline time.Time) {
+	// Unlike a timer's channel, Done stays closed for every further pipe
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	for _, p := range pipes {
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			logger.Debug("Child %s still open after child exit, closing it", p.stream)
+		}
+		p.r.Close()
+	}
+}
+
+// Write writes child output to the destination
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.dest == OutputLogger {
+		o.line = append(o.line, p...)
+		for {
+			i := bytes.IndexByte(o.line, '\n')
+			if i < 0 {
+				break
+			}
+			o.logLine(bytes.TrimRight(o.line[:i], "\r"))
+			o.line = o.line[i+1:]
+		}
+		return len(p), nil
+	}
+
+	if o.file == nil {
+		return 0, fmt.Errorf("%s output %s is closed", o.stream, o.dest)
+	}
+	return o.file.Write(p)
+}
+
+// logLine logs a complete line unless the rate limit drops it
+func (o *Output) logLine(line []byte) {
+	if o.limit != nil && !o.limit.allow(time.Now()) {
+		o.dropped++
+		return
+	}
+	o.reportDropped()
+	logger.Info("[child %s] %s", o.stream, line)
+}
+
+// reportDropped logs how many lines the rate limit dropped since last time
+func (o *Output) reportDropped() {
+	if o.dropped == 0 {
+		return
+	}
+	logger.Warn("Dropped %d lines of child %s due to rate limit", o.dropped, o.stream)
+	droppedLinesTotal.Add(uint64(o.dropped))
+	o.dropped = 0
+}
+
+// Reopen reopens a file destination, so output moves to a new file after
+// logrotate renamed the old one. It is a no-op for other destinations
+func (o *Output) Reopen() error {
+	if !o.isFile() {
+		return nil
+	}
+
+	file, err := os.OpenFile(o.dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s output %s: %w", o.stream, o.dest, err)
+	}
+
+	o.mu.Lock()
+	old := o.file
+	o.file = file
+	o.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+		logger.Info("Reopened child %s output %s", o.stream, o.dest)
+	}
+	return nil
+}
+
+// Close flushes a partial logger line and closes a file destination
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.line) > 0 {
+		o.logLine(o.line)
+		o.line = nil
+	}
+	o.reportDropped()
+
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// String describes the destination for logs
+func (o *Output) String() string {
+	return o.dest
+}
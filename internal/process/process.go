@@ -2,9 +2,13 @@ package process
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,71 +19,407 @@ import (
 type ExitReason int
 
 const (
-	ExitReasonUnknown ExitReason = iota
-	ExitReasonAbnormal                    // Process crashed or exited unexpectedly
-	ExitReasonRestart                     // Process was restarted by manager
+	ExitReasonUnknown  ExitReason = iota
+	ExitReasonAbnormal            // Process crashed or exited unexpectedly
+	ExitReasonRestart             // Process was restarted by manager
 )
 
+func (r ExitReason) String() string {
+	switch r {
+	case ExitReasonAbnormal:
+		return "abnormal"
+	case ExitReasonRestart:
+		return "restart"
+	default:
+		return "unknown"
+	}
+}
+
 // Manager handles the lifecycle of a child process
 type Manager interface {
 	Start(ctx context.Context) error
 	Restart(ctx context.Context) error
 	Wait() (ExitReason, error)
+	WaitExit() Exit
 	Stop(timeout time.Duration) error
+	Signal(sig syscall.Signal) error
+	PID() int
+	Drained() bool
+	SetArgs(args []string)
+	SetEnv(env []string)
 }
 
+// Options configures optional behavior of the process manager
+type Options struct {
+	// AllocatePTY runs the child attached to a pseudo-terminal so it behaves
+	// as if interactive; its output is forwarded through the logger
+	AllocatePTY bool
+
+	// ResolveCommand re-resolves the command path and re-stats the binary
+	// before every start, so an in-place upgrade is picked up and logged
+	ResolveCommand bool
+
+	// Stdout and Stderr are the destinations of the child's output streams;
+	// nil inherits the manager's own. They are ignored with AllocatePTY
+	Stdout *Output
+	Stderr *Output
+
+	// Capture, if set, also receives both output streams, in addition to
+	// their destinations. It must not block; a RingBuffer never does.
+	// Ignored with AllocatePTY
+	Capture io.Writer
+
+	// ForceKillSignal is sent when the child does not stop within the stop
+	// timeout (default SIGKILL). It must be a terminating signal
+	ForceKillSignal syscall.Signal
+
+	// SignalGroup sends stop signals to the child's whole process group
+	// instead of the child alone, so processes started by a shell command
+	// are signalled directly rather than relying on the shell to forward
+	SignalGroup bool
+
+	// ListenFiles are inherited by every child as descriptors 3 onwards,
+	// announced with systemd's LISTEN_FDS and LISTEN_PID, so a listening
+	// socket stays open across restarts. The caller owns and closes them
+	ListenFiles []*os.File
+
+	// ConfigureCmd, if set, is called with the child's command on every
+	// start, after the manager set it up and before it is started, to
+	// customize it beyond these options, e.g. with namespaces in
+	// SysProcAttr. The manager sets Env, ExtraFiles (ListenFiles), Stdin,
+	// Stdout, Stderr, SysProcAttr (a new process group, or a session with
+	// AllocatePTY, which stop signals and the PTY rely on), Cancel (with
+	// SignalGroup) and WaitDelay; change them only with care. It must not
+	// start or wait for the command: the manager does, to monitor the exit
+	ConfigureCmd func(*exec.Cmd)
+
+	// OpenStdin, if set, returns the file the child reads as its stdin. It
+	// is called on every start, so a file is re-opened for each child, and
+	// the file is closed once the child holds its own copy, unless it is
+	// os.Stdin. Without it, stdin is /dev/null. Ignored with AllocatePTY
+	OpenStdin func() (*os.File, error)
+
+	// WaitDelay bounds how long the child's output is still forwarded after
+	// it exited, in case a grandchild holds its stdout, stderr or PTY open,
+	// so the exit is always reported (default 1s)
+	WaitDelay time.Duration
+
+	// StopTimeout bounds how long Restart lets the running child stop
+	// gracefully before it is killed (default 10s)
+	StopTimeout time.Duration
+
+	// BeforeStart, if set, is called at the beginning of every start. With
+	// Restart, that is once the running child was stopped, so it no longer
+	// touches what BeforeStart prepares for the next one
+	BeforeStart func()
+}
+
+// defaultWaitDelay is used when Options.WaitDelay is not set
+const defaultWaitDelay = time.Second
+
+// defaultStopTimeout is used when Options.StopTimeout is not set
+const defaultStopTimeout = 10 * time.Second
+
 type manager struct {
-	command     string
-	args        []string
-	cmd         *exec.Cmd
-	exitChan    chan exitInfo
-	restartFlag bool
+	command       string
+	args          []string
+	env           []string
+	opts          Options
+	binaryModTime time.Time
+	mu            sync.Mutex // guards args, env and gen
+	gen           *generation
+	exitChan      chan Exit
+}
+
+// generation is a single run of the child process. Restart intent is recorded
+// on the generation it applies to, so a slow-dying process is still classified
+// correctly after the next generation has started
+type generation struct {
+	cmd        *exec.Cmd
+	startedAt  time.Time
+	restarting atomic.Bool   // set before Restart signals the process
+	done       chan struct{} // closed once the process has been reaped
+	outputs    []*outputPipe // output streams forwarded through pipes
+	stopping   atomic.Bool   // set once Stop sent SIGTERM
+	drained    chan struct{} // closed by Drained while stopping
+	drainOnce  sync.Once
+	stopSigs   atomic.Uint64 // a bit per signal Stop sent, set before sending
+}
+
+// sendingStop records that Stop is about to send sig, so an exit it causes
+// is classified as stopped
+func (g *generation) sendingStop(sig syscall.Signal) {
+	g.stopSigs.Or(1 << uint(sig))
 }
 
-type exitInfo struct {
-	reason ExitReason
-	err    error
+// stoppedBy reports whether the process exited with status because of a
+// signal Stop sent: killed by one of them, or exiting after receiving one.
+// A kill from elsewhere, such as the context's, does not count
+func (g *generation) stoppedBy(status ExitStatus) bool {
+	sent := g.stopSigs.Load()
+	switch status.Kind {
+	case ExitedWithCode:
+		return sent != 0
+	case Signaled:
+		return sent&(1<<uint(status.Signal)) != 0
+	default:
+		return false
+	}
+}
+
+var errNotStarted = errors.New("process not started")
+
+// Exit describes one exit of the child process
+type Exit struct {
+	Reason    ExitReason
+	Err       error      // as returned by exec.Cmd.Wait
+	Status    ExitStatus // how the process exited, Stopped if Stop ended it
+	StartedAt time.Time
+	ExitedAt  time.Time
+}
+
+// Uptime is how long the process ran before it exited
+func (e Exit) Uptime() time.Duration {
+	return e.ExitedAt.Sub(e.StartedAt)
 }
 
 // NewManager creates a new process manager
 func NewManager(command string, args []string) Manager {
+	return NewManagerWithOptions(command, args, Options{})
+}
+
+// NewManagerWithOptions creates a new process manager with the given options
+func NewManagerWithOptions(command string, args []string, opts Options) Manager {
 	return &manager{
 		command:  command,
 		args:     args,
-		exitChan: make(chan exitInfo, 1),
+		opts:     opts,
+		exitChan: make(chan Exit, 1),
 	}
 }
 
 // Start starts the child process
 func (m *manager) Start(ctx context.Context) error {
-	logger.Info("Starting child process: %s %v", m.command, m.args)
+	m.mu.Lock()
+	args, env := m.args, m.env
+	m.mu.Unlock()
+	logger.Info("Starting child process: %s %v", m.command, args)
+	if m.opts.BeforeStart != nil {
+		m.opts.BeforeStart()
+	}
 
-	m.cmd = exec.CommandContext(ctx, m.command, m.args...)
-	m.cmd.Stdout = os.Stdout
-	m.cmd.Stderr = os.Stderr
-	m.cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true, // Create new process group
+	command := m.command
+	if m.opts.ResolveCommand {
+		resolved, err := m.resolveCommand()
+		if err != nil {
+			logger.Error("Failed to resolve command: %v", err)
+			return err
+		}
+		command = resolved
 	}
 
-	if err := m.cmd.Start(); err != nil {
+	if len(m.opts.ListenFiles) > 0 {
+		var listenEnv []string
+		command, args, listenEnv = withListenFDs(command, args, len(m.opts.ListenFiles))
+		env = append(env[:len(env):len(env)], listenEnv...)
+	}
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.ExtraFiles = m.opts.ListenFiles
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if m.opts.SignalGroup {
+		cmd.Cancel = func() error {
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+
+	var pty, tty *os.File
+	if m.opts.AllocatePTY {
+		var err error
+		pty, tty, err = openPTY()
+		if err != nil {
+			logger.Error("Failed to allocate PTY: %v", err)
+			return fmt.Errorf("failed to allocate pty: %w", err)
+		}
+		cmd.Stdin = tty
+		cmd.Stdout = tty
+		cmd.Stderr = tty
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Setsid:  true, // New session, which is also a new process group
+			Setctty: true, // Make the PTY (stdin) the controlling terminal
+		}
+	} else {
+		if m.opts.OpenStdin != nil {
+			stdin, err := m.opts.OpenStdin()
+			if err != nil {
+				logger.Error("Failed to open child stdin: %v", err)
+				return fmt.Errorf("failed to open stdin: %w", err)
+			}
+			if stdin != os.Stdin {
+				defer stdin.Close()
+			}
+			cmd.Stdin = stdin
+		}
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if m.opts.Stdout != nil {
+			cmd.Stdout = m.opts.Stdout.target()
+		}
+		if m.opts.Stderr != nil {
+			cmd.Stderr = m.opts.Stderr.target()
+		}
+		if m.opts.Capture != nil {
+			cmd.Stdout = tee(cmd.Stdout, m.opts.Capture)
+			cmd.Stderr = tee(cmd.Stderr, m.opts.Capture)
+		}
+		cmd.WaitDelay = m.waitDelay()
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Setpgid: true, // Create new process group
+		}
+	}
+
+	if m.opts.ConfigureCmd != nil {
+		m.opts.ConfigureCmd(cmd)
+	}
+
+	// closePTY releases the terminal when the child could not be started
+	closePTY := func() {
+		if pty != nil {
+			tty.Close()
+			pty.Close()
+		}
+	}
+
+	outputs, err := m.pipeOutputs(cmd)
+	if err != nil {
+		closePTY()
+		logger.Error("Failed to set up child output: %v", err)
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		closePTY()
+		for _, p := range outputs {
+			p.w.Close()
+			p.r.Close()
+		}
 		logger.Error("Failed to start process: %v", err)
 		return fmt.Errorf("failed to start process: %w", err)
 	}
 
-	logger.Info("Child process started with PID: %d", m.cmd.Process.Pid)
+	logger.Info("Child process started with PID: %d", cmd.Process.Pid)
+
+	gen := &generation{
+		cmd:       cmd,
+		startedAt: time.Now(),
+		done:      make(chan struct{}),
+		drained:   make(chan struct{}),
+		outputs:   outputs,
+	}
+	m.mu.Lock()
+	m.gen = gen
+	m.mu.Unlock()
+
+	// The child holds its own copies of the write ends
+	for _, p := range outputs {
+		p.w.Close()
+		go p.forward(gen.done)
+	}
+
+	// The child holds its own copy of the terminal; forward what it writes
+	var ptyDone chan struct{}
+	if pty != nil {
+		tty.Close()
+		ptyDone = make(chan struct{})
+		go forwardPTYOutput(pty, ptyDone)
+		go forwardWinsize(pty, ptyDone)
+		logger.Debug("Child process attached to PTY")
+	}
 
 	// Monitor process exit
-	go m.monitorProcess()
+	go m.monitorProcess(gen, pty, ptyDone)
 
 	return nil
 }
 
+// pipeOutputs replaces output writers the child cannot write to directly with
+// pipes, which are forwarded once the child started. With os/exec's own
+// pipes, the child closing a stream would silently stop its capture
+func (m *manager) pipeOutputs(cmd *exec.Cmd) ([]*outputPipe, error) {
+	var outputs []*outputPipe
+	for _, stream := range []struct {
+		name   string
+		writer *io.Writer
+	}{
+		{"stdout", &cmd.Stdout},
+		{"stderr", &cmd.Stderr},
+	} {
+		w, p, err := pipeOutput(*stream.writer, stream.name)
+		if err != nil {
+			for _, p := range outputs {
+				p.w.Close()
+				p.r.Close()
+			}
+			return nil, err
+		}
+		*stream.writer = w
+		if p != nil {
+			outputs = append(outputs, p)
+		}
+	}
+	return outputs, nil
+}
+
+// resolveCommand looks up the command binary and records its modification time,
+// warning if the binary changed since the previous start
+func (m *manager) resolveCommand() (string, error) {
+	path, err := exec.LookPath(m.command)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve command %s: %w", m.command, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat command %s: %w", path, err)
+	}
+
+	modTime := info.ModTime()
+	logger.Info("Resolved command %s to %s (mtime=%v)", m.command, path, modTime)
+
+	if !m.binaryModTime.IsZero() && !modTime.Equal(m.binaryModTime) {
+		logger.Warn("Command binary %s changed since previous start (mtime %v -> %v), starting new version",
+			path, m.binaryModTime, modTime)
+	}
+	m.binaryModTime = modTime
+
+	return path, nil
+}
+
+// SetArgs replaces the arguments used for subsequent starts
+func (m *manager) SetArgs(args []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.args = args
+}
+
+// SetEnv sets KEY=VALUE variables added to the inherited environment of
+// subsequent starts
+func (m *manager) SetEnv(env []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.env = env
+}
+
 // Restart gracefully restarts the child process
 func (m *manager) Restart(ctx context.Context) error {
 	logger.Info("Restarting child process...")
-	m.restartFlag = true
+	if gen := m.current(); gen != nil {
+		gen.restarting.Store(true)
+	}
 
-	if err := m.Stop(10 * time.Second); err != nil {
+	if err := m.Stop(m.stopTimeout()); err != nil {
 		logger.Error("Failed to stop process during restart: %v", err)
 		return fmt.Errorf("failed to stop process: %w", err)
 	}
@@ -87,31 +427,56 @@ func (m *manager) Restart(ctx context.Context) error {
 	// Wait a bit before restarting
 	time.Sleep(100 * time.Millisecond)
 
-	m.restartFlag = false
 	logger.Info("Restarting child process after stop")
 	return m.Start(ctx)
 }
 
 // Wait waits for the process to exit and returns the reason
+// It returns immediately with an error if the process was never started
 func (m *manager) Wait() (ExitReason, error) {
-	info := <-m.exitChan
-	return info.reason, info.err
+	exit := m.WaitExit()
+	return exit.Reason, exit.Err
+}
+
+// WaitExit is like Wait but also reports when the process started and exited
+func (m *manager) WaitExit() Exit {
+	if m.current() == nil {
+		return Exit{Reason: ExitReasonUnknown, Err: errNotStarted}
+	}
+	return <-m.exitChan
+}
+
+// current returns the most recently started generation, or nil
+func (m *manager) current() *generation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gen
 }
 
 // Stop stops the child process gracefully
 func (m *manager) Stop(timeout time.Duration) error {
-	if m.cmd == nil || m.cmd.Process == nil {
+	gen := m.current()
+	if gen == nil {
 		logger.Debug("No process to stop")
 		return nil
 	}
 
-	pid := m.cmd.Process.Pid
+	select {
+	case <-gen.done:
+		logger.Debug("Process already finished")
+		return nil
+	default:
+	}
+
+	pid := gen.cmd.Process.Pid
 	logger.Info("Stopping child process (PID: %d) with timeout: %v", pid, timeout)
 
 	// Send SIGTERM for graceful shutdown
-	if err := m.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+	gen.sendingStop(syscall.SIGTERM)
+	if err := m.signal(gen, syscall.SIGTERM); err != nil {
+		gen.stopSigs.Store(0)
 		// Process might already be dead
-		if err.Error() != "os: process already finished" {
+		if !alreadyExited(err) {
 			logger.Error("Failed to send SIGTERM to process: %v", err)
 			return err
 		}
@@ -120,43 +485,158 @@ func (m *manager) Stop(timeout time.Duration) error {
 	}
 
 	logger.Debug("Sent SIGTERM to process (PID: %d), waiting for graceful shutdown...", pid)
+	gen.stopping.Store(true)
+	stopStart := time.Now()
 
-	// Wait for process to exit gracefully
-	done := make(chan error, 1)
-	go func() {
-		_, err := m.cmd.Process.Wait()
-		done <- err
-	}()
+	sig := m.opts.ForceKillSignal
+	if sig == 0 {
+		sig = syscall.SIGKILL
+	}
 
+	// Wait for the monitor to reap the process
 	select {
-	case <-done:
-		logger.Info("Child process (PID: %d) stopped gracefully", pid)
+	case <-gen.done:
+		logger.Info("Child process (PID: %d) stopped gracefully after %v", pid, time.Since(stopStart).Round(time.Millisecond))
 		return nil
+	case <-gen.drained:
+		// The child finished flushing; there is nothing left to wait for
+		logger.Info("Child process (PID: %d) reported drained after %v, sending signal %d (%v)",
+			pid, time.Since(stopStart).Round(time.Millisecond), int(sig), sig)
 	case <-time.After(timeout):
-		// Force kill if timeout
-		logger.Info("Timeout waiting for graceful shutdown, sending SIGKILL to process (PID: %d)", pid)
-		return m.cmd.Process.Kill()
+		// Force kill if timeout. Frequent forced kills mean the timeout is
+		// too short or the child hangs on shutdown
+		forcedKillsTotal.Inc()
+		logger.Warn("Child process (PID: %d) did not stop within %v of SIGTERM, sending signal %d (%v)", pid, timeout, int(sig), sig)
+	}
+	gen.sendingStop(sig)
+	if err := m.signal(gen, sig); err != nil && !alreadyExited(err) {
+		return err
+	}
+	return nil
+}
+
+// Drained lets a Stop in progress kill the child right away instead of
+// waiting for it to exit, once the child reported that it finished
+// flushing. It reports false if no stop is in progress
+func (m *manager) Drained() bool {
+	gen := m.current()
+	if gen == nil || !gen.stopping.Load() {
+		return false
+	}
+	select {
+	case <-gen.done:
+		return false
+	default:
+	}
+
+	gen.drainOnce.Do(func() { close(gen.drained) })
+	return true
+}
+
+// Signal sends sig to the running child, or to its process group with
+// SignalGroup
+func (m *manager) Signal(sig syscall.Signal) error {
+	gen := m.current()
+	if gen == nil {
+		return errNotStarted
+	}
+
+	select {
+	case <-gen.done:
+		return os.ErrProcessDone
+	default:
+	}
+
+	logger.Info("Sending signal %v to child process (PID: %d)", sig, gen.cmd.Process.Pid)
+	return m.signal(gen, sig)
+}
+
+// PID returns the process ID of the running child, or 0 if it has not been
+// started or has already exited
+func (m *manager) PID() int {
+	gen := m.current()
+	if gen == nil {
+		return 0
+	}
+
+	select {
+	case <-gen.done:
+		return 0
+	default:
 	}
+	return gen.cmd.Process.Pid
+}
+
+// signal sends sig to the generation's process, or to its process group with
+// SignalGroup. The child always leads its own group (Setpgid or Setsid)
+func (m *manager) signal(gen *generation, sig syscall.Signal) error {
+	if m.opts.SignalGroup {
+		return syscall.Kill(-gen.cmd.Process.Pid, sig)
+	}
+	return gen.cmd.Process.Signal(sig)
+}
+
+// alreadyExited reports whether a signal error means the process is already
+// gone: os.ErrProcessDone once it has been reaped, ESRCH from a raw kill
+func alreadyExited(err error) bool {
+	return errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH)
+}
+
+// waitDelay returns Options.WaitDelay, or its default
+func (m *manager) waitDelay() time.Duration {
+	if m.opts.WaitDelay > 0 {
+		return m.opts.WaitDelay
+	}
+	return defaultWaitDelay
+}
+
+// stopTimeout returns Options.StopTimeout, or its default
+func (m *manager) stopTimeout() time.Duration {
+	if m.opts.StopTimeout > 0 {
+		return m.opts.StopTimeout
+	}
+	return defaultStopTimeout
 }
 
 // monitorProcess monitors the process and sends exit info when it exits
-func (m *manager) monitorProcess() {
-	err := m.cmd.Wait()
+func (m *manager) monitorProcess(gen *generation, pty *os.File, ptyDone <-chan struct{}) {
+	err := gen.cmd.Wait()
+	exitedAt := time.Now()
+	close(gen.done)
+	drainOutputs(gen.outputs, exitedAt.Add(m.waitDelay()))
+
+	if pty != nil {
+		// Let the remaining output drain, unless a grandchild keeps the PTY open
+		select {
+		case <-ptyDone:
+		case <-time.After(time.Until(exitedAt.Add(m.waitDelay()))):
+			logger.Debug("PTY still open after child exit, closing it")
+		}
+		pty.Close()
+	}
+
+	status := ClassifyExit(err)
+	if gen.stoppedBy(status) {
+		status.Kind = Stopped
+	}
 
 	reason := ExitReasonAbnormal
-	if m.restartFlag {
+	if gen.restarting.Load() {
 		reason = ExitReasonRestart
 		logger.Debug("Process exited due to restart request")
 	} else {
 		if err != nil {
-			logger.Info("Child process exited abnormally: %v", err)
+			logger.Info("Child process exited abnormally: %v", status)
 		} else {
 			logger.Info("Child process exited normally")
 		}
 	}
 
-	m.exitChan <- exitInfo{
-		reason: reason,
-		err:    err,
+	m.exitChan <- Exit{
+		Reason:    reason,
+		Err:       err,
+		Status:    status,
+		StartedAt: gen.startedAt,
+		ExitedAt:  exitedAt,
 	}
 }
@@ -2,6 +2,15 @@ package process
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -61,6 +70,14 @@ func TestManager_Wait(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("wait without start returns immediately", func(t *testing.T) {
+		m := NewManager("sleep", []string{"10"})
+
+		reason, err := m.Wait()
+		assert.Equal(t, ExitReasonUnknown, reason)
+		assert.Error(t, err)
+	})
+
 	t.Run("process exits with error", func(t *testing.T) {
 		m := NewManager("sh", []string{"-c", "exit 1"})
 		ctx := context.Background()
@@ -85,8 +102,10 @@ func TestManager_Stop(t *testing.T) {
 		// Give process time to start
 		time.Sleep(100 * time.Millisecond)
 
+		before := forcedKillsTotal.Value()
 		err = m.Stop(5 * time.Second)
 		assert.NoError(t, err)
+		assert.Equal(t, before, forcedKillsTotal.Value())
 	})
 
 	t.Run("stop already stopped process", func(t *testing.T) {
@@ -115,11 +134,260 @@ func TestManager_Stop(t *testing.T) {
 		time.Sleep(100 * time.Millisecond)
 
 		// Stop with very short timeout to force kill
+		before := forcedKillsTotal.Value()
+		err = m.Stop(100 * time.Millisecond)
+		assert.NoError(t, err)
+		assert.Equal(t, before+1, forcedKillsTotal.Value())
+	})
+
+	t.Run("custom force kill signal", func(t *testing.T) {
+		m := NewManagerWithOptions("sh", []string{"-c", "trap '' TERM; trap 'exit 3' QUIT; while :; do sleep 0.05; done"},
+			Options{ForceKillSignal: syscall.SIGQUIT})
+
+		err := m.Start(context.Background())
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+
 		err = m.Stop(100 * time.Millisecond)
 		assert.NoError(t, err)
+
+		_, err = m.Wait()
+		assert.Equal(t, ExitStatus{Kind: ExitedWithCode, Code: 3}, ClassifyExit(err))
+	})
+}
+
+func TestManager_Drained(t *testing.T) {
+	m := NewManager("sh", []string{"-c", "trap '' TERM; while :; do sleep 0.05; done"})
+	require.NoError(t, m.Start(context.Background()))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, m.Drained(), "no stop in progress")
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		m.Drained()
+	}()
+	before := forcedKillsTotal.Value()
+	start := time.Now()
+	require.NoError(t, m.Stop(10*time.Second))
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, before, forcedKillsTotal.Value(), "not a forced kill")
+
+	_, err := m.Wait()
+	assert.Equal(t, ExitStatus{Kind: Signaled, Signal: syscall.SIGKILL}, ClassifyExit(err))
+}
+
+func TestManager_SignalGroup(t *testing.T) {
+	// The shell ignores SIGTERM and would never forward it to the background
+	// sleep; only signalling the group stops the sleep
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	m := NewManagerWithOptions("sh", []string{"-c", "trap '' TERM; sleep 30 & echo $! > " + pidFile + "; wait"},
+		Options{SignalGroup: true})
+	require.NoError(t, m.Start(context.Background()))
+
+	var pid int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		_, err = fmt.Sscanf(string(data), "%d", &pid)
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	require.NoError(t, m.Stop(200*time.Millisecond))
+	_, _ = m.Wait()
+
+	assert.Eventually(t, func() bool { return !processRunning(pid) }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestManager_Signal(t *testing.T) {
+	t.Run("not started", func(t *testing.T) {
+		m := NewManager("sleep", []string{"30"})
+		assert.ErrorIs(t, m.Signal(syscall.SIGHUP), errNotStarted)
+	})
+
+	t.Run("delivers signal to child", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "hup")
+		m := NewManager("sh", []string{"-c", "trap 'touch " + marker + "' HUP; while true; do sleep 0.05; done"})
+		require.NoError(t, m.Start(context.Background()))
+		defer m.Stop(time.Second)
+
+		// Give the shell time to install its trap
+		time.Sleep(200 * time.Millisecond)
+		require.NoError(t, m.Signal(syscall.SIGHUP))
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(marker)
+			return err == nil
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("after exit", func(t *testing.T) {
+		m := NewManager("true", nil)
+		require.NoError(t, m.Start(context.Background()))
+		_, _ = m.Wait()
+		assert.ErrorIs(t, m.Signal(syscall.SIGHUP), os.ErrProcessDone)
+	})
+}
+
+func TestManager_PID(t *testing.T) {
+	m := NewManager("sleep", []string{"30"})
+	assert.Zero(t, m.PID())
+
+	require.NoError(t, m.Start(context.Background()))
+	pid := m.PID()
+	assert.NotZero(t, pid)
+	assert.True(t, processRunning(pid))
+
+	require.NoError(t, m.Stop(time.Second))
+	_, _ = m.Wait()
+	assert.Zero(t, m.PID())
+}
+
+func TestReadRSS(t *testing.T) {
+	if !RSSSupported {
+		t.Skip("not supported on this platform")
+	}
+
+	rss, err := ReadRSS(os.Getpid())
+	require.NoError(t, err)
+	assert.Greater(t, rss, int64(os.Getpagesize()))
+
+	_, err = ReadRSS(1 << 30)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestManager_ListenFiles(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	file, err := listener.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer file.Close()
+
+	out := filepath.Join(t.TempDir(), "out")
+	m := NewManagerWithOptions("sh", []string{"-c", `test -e /proc/$$/fd/3 && echo "$LISTEN_FDS $LISTEN_PID $$" > ` + out},
+		Options{ListenFiles: []*os.File{file}})
+	require.NoError(t, m.Start(context.Background()))
+	_, err = m.Wait()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	fields := strings.Fields(string(data))
+	require.Len(t, fields, 3)
+	assert.Equal(t, "1", fields[0])
+	assert.Equal(t, fields[2], fields[1], "LISTEN_PID must be the child's PID")
+}
+
+func TestManager_ConfigureCmd(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	starts := 0
+	m := NewManagerWithOptions("sh", []string{"-c", "pwd > " + out}, Options{
+		ConfigureCmd: func(cmd *exec.Cmd) {
+			starts++
+			// Called with the manager's setup in place
+			assert.True(t, cmd.SysProcAttr.Setpgid)
+			cmd.Dir = "/"
+		},
+	})
+	require.NoError(t, m.Start(context.Background()))
+	_, err := m.Wait()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "/\n", string(data))
+	assert.Equal(t, 1, starts)
+}
+
+func TestManager_OpenStdin(t *testing.T) {
+	dir := t.TempDir()
+	seed := filepath.Join(dir, "seed")
+	require.NoError(t, os.WriteFile(seed, []byte("seed\n"), 0644))
+	out := filepath.Join(dir, "out")
+
+	opens := 0
+	m := NewManagerWithOptions("sh", []string{"-c", "cat >> " + out}, Options{
+		OpenStdin: func() (*os.File, error) {
+			opens++
+			return os.Open(seed)
+		},
+	})
+	// Each start reads the file from the beginning
+	for i := 0; i < 2; i++ {
+		require.NoError(t, m.Start(context.Background()))
+		_, err := m.Wait()
+		require.NoError(t, err)
+	}
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "seed\nseed\n", string(data))
+	assert.Equal(t, 2, opens)
+
+	t.Run("open failure fails the start", func(t *testing.T) {
+		m := NewManagerWithOptions("true", nil, Options{
+			OpenStdin: func() (*os.File, error) { return os.Open(filepath.Join(dir, "missing")) },
+		})
+		assert.ErrorContains(t, m.Start(context.Background()), "failed to open stdin")
 	})
 }
 
+func TestManager_WaitDelay(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "grandchild.pid")
+	capture := NewRingBuffer(1024)
+	// The backgrounded sleep inherits the child's stdout and outlives it
+	m := NewManagerWithOptions("sh", []string{"-c", "sleep 30 & echo $! > " + pidFile + "; echo started; exec sleep 30"},
+		Options{Capture: capture, WaitDelay: 200 * time.Millisecond})
+	require.NoError(t, m.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		return string(capture.Bytes()) == "started\n"
+	}, 5*time.Second, 20*time.Millisecond)
+	data, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	grandchild, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	defer syscall.Kill(grandchild, syscall.SIGKILL)
+
+	require.NoError(t, m.Stop(5*time.Second))
+	exited := make(chan Exit, 1)
+	go func() {
+		exited <- m.WaitExit()
+	}()
+	select {
+	case exit := <-exited:
+		assert.Less(t, time.Since(exit.ExitedAt), 600*time.Millisecond)
+	case <-time.After(2 * time.Second):
+		t.Fatal("exit not reported while a grandchild holds stdout open")
+	}
+	assert.True(t, processRunning(grandchild))
+}
+
+// processRunning reports whether pid exists and is not a zombie
+func processRunning(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state follows the parenthesized command name
+	i := strings.LastIndexByte(string(data), ')')
+	return i < 0 || i+2 >= len(data) || data[i+2] != 'Z'
+}
+
+func TestAlreadyExited(t *testing.T) {
+	assert.True(t, alreadyExited(os.ErrProcessDone))
+	assert.True(t, alreadyExited(syscall.ESRCH))
+	assert.True(t, alreadyExited(fmt.Errorf("kill: %w", syscall.ESRCH)))
+	assert.False(t, alreadyExited(syscall.EPERM))
+}
+
+func TestIsTerminatingSignal(t *testing.T) {
+	assert.True(t, IsTerminatingSignal(syscall.SIGKILL))
+	assert.True(t, IsTerminatingSignal(syscall.SIGQUIT))
+	assert.False(t, IsTerminatingSignal(syscall.SIGSTOP))
+	assert.False(t, IsTerminatingSignal(syscall.SIGCHLD))
+	assert.False(t, IsTerminatingSignal(0))
+}
+
 func TestManager_Restart(t *testing.T) {
 	t.Run("restart process successfully", func(t *testing.T) {
 		m := NewManager("sleep", []string{"10"})
@@ -130,15 +398,16 @@ func TestManager_Restart(t *testing.T) {
 		require.NoError(t, err)
 
 		// Get PID of first process
-		mgr := m.(*manager)
-		firstPID := mgr.cmd.Process.Pid
+		firstPID := m.PID()
+		assert.NotZero(t, firstPID)
 
 		// Restart
 		err = m.Restart(ctx)
 		assert.NoError(t, err)
 
 		// Get PID of second process
-		secondPID := mgr.cmd.Process.Pid
+		secondPID := m.PID()
+		assert.NotZero(t, secondPID)
 
 		// PIDs should be different
 		assert.NotEqual(t, firstPID, secondPID)
@@ -147,6 +416,23 @@ func TestManager_Restart(t *testing.T) {
 		_ = m.Stop(1 * time.Second)
 	})
 
+	t.Run("restart uses the stop timeout", func(t *testing.T) {
+		m := NewManagerWithOptions("sh", []string{"-c", "trap '' TERM; while :; do sleep 0.05; done"},
+			Options{StopTimeout: 200 * time.Millisecond})
+		ctx := context.Background()
+
+		require.NoError(t, m.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+
+		before := forcedKillsTotal.Value()
+		start := time.Now()
+		require.NoError(t, m.Restart(ctx))
+		assert.Less(t, time.Since(start), 2*time.Second)
+		assert.Equal(t, before+1, forcedKillsTotal.Value())
+
+		_ = m.Stop(100 * time.Millisecond)
+	})
+
 	t.Run("restart marks old process exit as restart", func(t *testing.T) {
 		m := NewManager("sleep", []string{"10"})
 		ctx := context.Background()
@@ -176,6 +462,74 @@ func TestManager_Restart(t *testing.T) {
 		// Clean up
 		_ = m.Stop(1 * time.Second)
 	})
+
+	// Run with -race: each generation's exit must be classified as a restart,
+	// however the monitor and the next start interleave
+	t.Run("repeated restarts under stress", func(t *testing.T) {
+		const restarts = 20
+		m := NewManager("sh", []string{"-c", "trap 'exit 0' TERM; while :; do sleep 0.01; done"})
+		ctx := context.Background()
+		require.NoError(t, m.Start(ctx))
+
+		reasons := make(chan ExitReason, restarts)
+		go func() {
+			for i := 0; i < restarts; i++ {
+				reason, _ := m.Wait()
+				reasons <- reason
+			}
+		}()
+
+		for i := 0; i < restarts; i++ {
+			require.NoError(t, m.Restart(ctx))
+		}
+
+		for i := 0; i < restarts; i++ {
+			select {
+			case reason := <-reasons:
+				assert.Equal(t, ExitReasonRestart, reason, "exit %d", i)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("exit %d not reported", i)
+			}
+		}
+
+		_ = m.Stop(time.Second)
+	})
+}
+
+func TestManager_ResolveCommand(t *testing.T) {
+	t.Run("picks up replaced binary on restart", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		binary := filepath.Join(tmpDir, "app")
+		require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\nexec sleep 10\n"), 0755))
+		oldTime := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(binary, oldTime, oldTime))
+
+		m := NewManagerWithOptions(binary, nil, Options{ResolveCommand: true})
+		ctx := context.Background()
+
+		err := m.Start(ctx)
+		require.NoError(t, err)
+
+		mgr := m.(*manager)
+		assert.True(t, mgr.binaryModTime.Equal(oldTime))
+
+		// Replace the binary in place
+		require.NoError(t, os.Remove(binary))
+		require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\nexec sleep 20\n"), 0755))
+
+		err = m.Restart(ctx)
+		require.NoError(t, err)
+		assert.True(t, mgr.binaryModTime.After(oldTime))
+
+		_ = m.Stop(1 * time.Second)
+	})
+
+	t.Run("fails when command cannot be resolved", func(t *testing.T) {
+		m := NewManagerWithOptions("nonexistent-command-for-test", nil, Options{ResolveCommand: true})
+
+		err := m.Start(context.Background())
+		assert.Error(t, err)
+	})
 }
 
 func TestManager_ContextCancellation(t *testing.T) {
@@ -205,6 +559,201 @@ func TestManager_ContextCancellation(t *testing.T) {
 	})
 }
 
+func TestManager_AllocatePTY(t *testing.T) {
+	pty, tty, err := openPTY()
+	if err != nil {
+		t.Skipf("pty not available: %v", err)
+	}
+	tty.Close()
+	pty.Close()
+
+	t.Run("child sees a terminal", func(t *testing.T) {
+		m := NewManagerWithOptions("sh", []string{"-c", "test -t 0 && test -t 1"}, Options{AllocatePTY: true})
+
+		err := m.Start(context.Background())
+		require.NoError(t, err)
+
+		_, err = m.Wait()
+		assert.NoError(t, err)
+	})
+}
+
+func TestManager_Outputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	stdoutFile := filepath.Join(tmpDir, "stdout.log")
+	stderrFile := filepath.Join(tmpDir, "stderr.log")
+
+	stdout, err := NewOutput(stdoutFile, "stdout")
+	require.NoError(t, err)
+	defer stdout.Close()
+	stderr, err := NewOutput(stderrFile, "stderr")
+	require.NoError(t, err)
+	defer stderr.Close()
+
+	run := func(script string) {
+		m := NewManagerWithOptions("sh", []string{"-c", script}, Options{Stdout: stdout, Stderr: stderr})
+		require.NoError(t, m.Start(context.Background()))
+		_, err := m.Wait()
+		require.NoError(t, err)
+	}
+
+	run("echo out; echo err >&2")
+	run("echo again")
+
+	data, err := os.ReadFile(stdoutFile)
+	require.NoError(t, err)
+	assert.Equal(t, "out\nagain\n", string(data))
+	data, err = os.ReadFile(stderrFile)
+	require.NoError(t, err)
+	assert.Equal(t, "err\n", string(data))
+
+	t.Run("reopen after rotation", func(t *testing.T) {
+		rotated := stdoutFile + ".1"
+		require.NoError(t, os.Rename(stdoutFile, rotated))
+		require.NoError(t, stdout.Reopen())
+
+		run("echo rotated")
+
+		data, err := os.ReadFile(stdoutFile)
+		require.NoError(t, err)
+		assert.Equal(t, "rotated\n", string(data))
+		data, err = os.ReadFile(rotated)
+		require.NoError(t, err)
+		assert.Equal(t, "out\nagain\n", string(data))
+	})
+}
+
+func TestManager_Capture(t *testing.T) {
+	stdoutFile := filepath.Join(t.TempDir(), "stdout.log")
+	stdout, err := NewOutput(stdoutFile, "stdout")
+	require.NoError(t, err)
+	defer stdout.Close()
+	stderr, err := NewOutput(OutputDiscard, "stderr")
+	require.NoError(t, err)
+
+	capture := NewRingBuffer(1024)
+	m := NewManagerWithOptions("sh", []string{"-c", "echo out; sleep 0.1; echo err >&2"},
+		Options{Stdout: stdout, Stderr: stderr, Capture: capture})
+	require.NoError(t, m.Start(context.Background()))
+	_, err = m.Wait()
+	require.NoError(t, err)
+
+	// Output still reaches its destination, and the capture gets both streams
+	data, err := os.ReadFile(stdoutFile)
+	require.NoError(t, err)
+	assert.Equal(t, "out\n", string(data))
+	assert.Equal(t, "out\nerr\n", string(capture.Bytes()))
+}
+
+func TestManager_OutputClosed(t *testing.T) {
+	t.Run("child keeps running after closing stdout", func(t *testing.T) {
+		capture := NewRingBuffer(1024)
+		m := NewManagerWithOptions("sh", []string{"-c", "echo before; exec >&-; exec sleep 30"},
+			Options{Stderr: &Output{dest: OutputDiscard}, Capture: capture})
+		before := closedOutputsTotal.Value()
+		require.NoError(t, m.Start(context.Background()))
+		defer m.Stop(time.Second)
+
+		assert.Eventually(t, func() bool {
+			return closedOutputsTotal.Value() == before+1
+		}, 3*time.Second, 50*time.Millisecond)
+		assert.NotZero(t, m.PID(), "closing stdout is not an exit")
+		assert.Equal(t, "before\n", string(capture.Bytes()))
+	})
+
+	t.Run("exiting is not reported as closing", func(t *testing.T) {
+		capture := NewRingBuffer(1024)
+		m := NewManagerWithOptions("sh", []string{"-c", "echo out"}, Options{Capture: capture})
+		before := closedOutputsTotal.Value()
+		require.NoError(t, m.Start(context.Background()))
+		_, err := m.Wait()
+		require.NoError(t, err)
+
+		time.Sleep(outputEOFGrace + 200*time.Millisecond)
+		assert.Equal(t, before, closedOutputsTotal.Value())
+		assert.Equal(t, "out\n", string(capture.Bytes()))
+	})
+}
+
+func TestRingBuffer(t *testing.T) {
+	b := NewRingBuffer(8)
+	assert.Empty(t, b.Bytes())
+
+	b.Write([]byte("abc"))
+	assert.Equal(t, "abc", string(b.Bytes()))
+
+	b.Write([]byte("defgh"))
+	assert.Equal(t, "abcdefgh", string(b.Bytes()))
+
+	n, err := b.Write([]byte("ij"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "cdefghij", string(b.Bytes()))
+
+	// Writes larger than the buffer keep their tail
+	n, err = b.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, "23456789", string(b.Bytes()))
+}
+
+func TestNewOutput(t *testing.T) {
+	t.Run("special destinations", func(t *testing.T) {
+		for _, dest := range []string{"", OutputInherit, OutputLogger, OutputDiscard} {
+			o, err := NewOutput(dest, "stdout")
+			require.NoError(t, err)
+			assert.NoError(t, o.Reopen())
+			assert.NoError(t, o.Close())
+		}
+	})
+
+	t.Run("discard gives the child no stream", func(t *testing.T) {
+		o, err := NewOutput(OutputDiscard, "stdout")
+		require.NoError(t, err)
+		assert.Nil(t, o.target())
+	})
+
+	t.Run("fails fast on unwritable file", func(t *testing.T) {
+		_, err := NewOutput("/nonexistent/dir/out.log", "stdout")
+		assert.Error(t, err)
+	})
+}
+
+func TestOutput_RateLimit(t *testing.T) {
+	t.Run("token bucket", func(t *testing.T) {
+		now := time.Now()
+		b := newTokenBucket(2, now)
+		assert.True(t, b.allow(now))
+		assert.True(t, b.allow(now))
+		assert.False(t, b.allow(now))
+
+		// Refills at the rate, capped at the burst
+		assert.True(t, b.allow(now.Add(500*time.Millisecond)))
+		assert.False(t, b.allow(now.Add(500*time.Millisecond)))
+		later := now.Add(time.Hour)
+		assert.True(t, b.allow(later))
+		assert.True(t, b.allow(later))
+		assert.False(t, b.allow(later))
+	})
+
+	t.Run("excess logger lines are dropped and counted", func(t *testing.T) {
+		o, err := NewOutput(OutputLogger, "stdout")
+		require.NoError(t, err)
+		o.SetRateLimit(10)
+
+		before := droppedLinesTotal.Value()
+		for i := 0; i < 100; i++ {
+			_, err := o.Write([]byte("spam\n"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, o.Close())
+
+		dropped := droppedLinesTotal.Value() - before
+		assert.GreaterOrEqual(t, dropped, uint64(85))
+		assert.LessOrEqual(t, dropped, uint64(90))
+	})
+}
+
 func TestNewManager(t *testing.T) {
 	t.Run("create manager with valid params", func(t *testing.T) {
 		m := NewManager("echo", []string{"hello"})
@@ -217,11 +766,122 @@ func TestNewManager(t *testing.T) {
 	})
 }
 
+func TestClassifyExit(t *testing.T) {
+	run := func(t *testing.T, script string) error {
+		t.Helper()
+		m := NewManager("sh", []string{"-c", script})
+		require.NoError(t, m.Start(context.Background()))
+		_, err := m.Wait()
+		return err
+	}
+
+	t.Run("normal exit", func(t *testing.T) {
+		status := ClassifyExit(run(t, "exit 0"))
+		assert.Equal(t, ExitStatus{Kind: ExitedWithCode, Code: 0}, status)
+	})
+
+	t.Run("exit with code", func(t *testing.T) {
+		status := ClassifyExit(run(t, "exit 3"))
+		assert.Equal(t, ExitStatus{Kind: ExitedWithCode, Code: 3}, status)
+		assert.Equal(t, "exited with code 3", status.String())
+	})
+
+	t.Run("killed by signal", func(t *testing.T) {
+		status := ClassifyExit(run(t, "kill -SEGV $$"))
+		assert.Equal(t, ExitStatus{Kind: Signaled, Signal: syscall.SIGSEGV}, status)
+		assert.Contains(t, status.String(), "killed by signal 11")
+	})
+
+	t.Run("non-exit error", func(t *testing.T) {
+		status := ClassifyExit(errors.New("wait failed"))
+		assert.Equal(t, ExitKindUnknown, status.Kind)
+	})
+}
+
 func TestExitReason(t *testing.T) {
 	// Test that ExitReason constants have expected values
 	assert.Equal(t, ExitReason(0), ExitReasonUnknown)
 	assert.Equal(t, ExitReason(1), ExitReasonAbnormal)
 	assert.Equal(t, ExitReason(2), ExitReasonRestart)
+	assert.Equal(t, "restart", ExitReasonRestart.String())
+}
+
+func TestManager_WaitExit(t *testing.T) {
+	m := NewManager("sh", []string{"-c", "sleep 0.2; exit 4"})
+	assert.Equal(t, errNotStarted, m.WaitExit().Err)
+
+	require.NoError(t, m.Start(context.Background()))
+	exit := m.WaitExit()
+
+	assert.Equal(t, ExitReasonAbnormal, exit.Reason)
+	assert.Equal(t, ExitStatus{Kind: ExitedWithCode, Code: 4}, ClassifyExit(exit.Err))
+	assert.Equal(t, ExitStatus{Kind: ExitedWithCode, Code: 4}, exit.Status)
+	assert.GreaterOrEqual(t, exit.Uptime(), 200*time.Millisecond)
+	assert.Less(t, exit.Uptime(), 2*time.Second)
+}
+
+func TestManager_WaitExitStopped(t *testing.T) {
+	t.Run("killed by SIGTERM", func(t *testing.T) {
+		m := NewManager("sleep", []string{"10"})
+		require.NoError(t, m.Start(context.Background()))
+		require.NoError(t, m.Stop(5*time.Second))
+
+		exit := m.WaitExit()
+		assert.Equal(t, ExitReasonAbnormal, exit.Reason)
+		assert.Equal(t, ExitStatus{Kind: Stopped, Signal: syscall.SIGTERM}, exit.Status)
+		assert.Equal(t, "stopped, killed by signal 15 (terminated)", exit.Status.String())
+	})
+
+	t.Run("exits with a code on SIGTERM", func(t *testing.T) {
+		m := NewManager("sh", []string{"-c", "trap 'exit 0' TERM; while true; do sleep 0.05; done"})
+		require.NoError(t, m.Start(context.Background()))
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, m.Stop(5*time.Second))
+
+		exit := m.WaitExit()
+		assert.Equal(t, ExitStatus{Kind: Stopped, Code: 0}, exit.Status)
+		assert.Equal(t, "stopped, exited with code 0", exit.Status.String())
+	})
+
+	t.Run("force killed", func(t *testing.T) {
+		m := NewManager("sh", []string{"-c", "trap '' TERM; while :; do sleep 0.05; done"})
+		require.NoError(t, m.Start(context.Background()))
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, m.Stop(100*time.Millisecond))
+
+		exit := m.WaitExit()
+		assert.Equal(t, ExitStatus{Kind: Stopped, Signal: syscall.SIGKILL}, exit.Status)
+	})
+
+	t.Run("killed by the context while stopping", func(t *testing.T) {
+		m := NewManager("sh", []string{"-c", "trap '' TERM; while :; do sleep 0.05; done"})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, m.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- m.Stop(10 * time.Second)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+
+		exit := m.WaitExit()
+		assert.Equal(t, ExitStatus{Kind: Signaled, Signal: syscall.SIGKILL}, exit.Status)
+		assert.NoError(t, <-stopped)
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		m := NewManager("sleep", []string{"10"})
+		require.NoError(t, m.Start(context.Background()))
+		require.NoError(t, m.Restart(context.Background()))
+		defer m.Stop(time.Second)
+
+		exit := m.WaitExit()
+		assert.Equal(t, ExitReasonRestart, exit.Reason)
+		assert.Equal(t, Stopped, exit.Status.Kind)
+	})
 }
 
 // BenchmarkManager_StartStop benchmarks the start/stop cycle
@@ -234,3 +894,24 @@ func BenchmarkManager_StartStop(b *testing.B) {
 		_, _ = m.Wait()
 	}
 }
+
+func TestRaiseDefault(t *testing.T) {
+	if name := os.Getenv("RAISE_DEFAULT_SIGNAL"); name != "" {
+		sig := map[string]syscall.Signal{"SEGV": syscall.SIGSEGV, "TERM": syscall.SIGTERM}[name]
+		fmt.Println(RaiseDefault(sig))
+		os.Exit(3)
+	}
+
+	for name, sig := range map[string]syscall.Signal{"SEGV": syscall.SIGSEGV, "TERM": syscall.SIGTERM} {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestRaiseDefault$")
+			cmd.Env = append(os.Environ(), "RAISE_DEFAULT_SIGNAL="+name)
+			output, err := cmd.CombinedOutput()
+			require.Error(t, err, string(output))
+
+			status := ClassifyExit(err)
+			assert.Equal(t, Signaled, status.Kind, string(output))
+			assert.Equal(t, sig, status.Signal)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package process
+
+import (
+	"bufio"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// forwardPTYOutput copies the child's terminal output to the logger line by line
+// It returns when the PTY is closed or all slave ends are gone (EIO)
+func forwardPTYOutput(pty *os.File, done chan<- struct{}) {
+	defer close(done)
+
+	scanner := bufio.NewScanner(pty)
+	for scanner.Scan() {
+		logger.Info("[child] %s", strings.TrimRight(scanner.Text(), "\r"))
+	}
+	logger.Debug("PTY output stream closed")
+}
+
+// forwardWinsize keeps the PTY window size in sync with the manager's own
+// terminal, if it has one, until stop is closed
+func forwardWinsize(pty *os.File, stop <-chan struct{}) {
+	if _, err := getWinsize(os.Stdin); err != nil {
+		logger.Debug("No controlling terminal, skipping window size forwarding")
+		return
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGWINCH)
+	defer signal.Stop(sigChan)
+
+	for {
+		if ws, err := getWinsize(os.Stdin); err == nil {
+			if err := setWinsize(pty, ws); err != nil {
+				logger.Debug("Failed to set PTY window size: %v", err)
+			}
+		}
+
+		select {
+		case <-sigChan:
+			logger.Debug("Received SIGWINCH, resizing PTY")
+		case <-stop:
+			return
+		}
+	}
+}
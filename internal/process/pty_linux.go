@@ -0,0 +1,69 @@
+//go:build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// winsize mirrors struct winsize from <sys/ioctl.h>
+type winsize struct {
+	rows   uint16
+	cols   uint16
+	xpixel uint16
+	ypixel uint16
+}
+
+// openPTY allocates a new pseudo-terminal and returns its master and slave ends
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open /dev/ptmx: %w", err)
+	}
+
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+
+	var n uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+
+	slaveName := "/dev/pts/" + strconv.Itoa(int(n))
+	slave, err := os.OpenFile(slaveName, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to open %s: %w", slaveName, err)
+	}
+
+	return master, slave, nil
+}
+
+// getWinsize reads the window size of the terminal behind f
+func getWinsize(f *os.File) (*winsize, error) {
+	ws := &winsize{}
+	if err := ioctl(f.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(ws))); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// setWinsize sets the window size of the terminal behind f
+func setWinsize(f *os.File, ws *winsize) error {
+	return ioctl(f.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(ws)))
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
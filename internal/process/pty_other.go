@@ -0,0 +1,33 @@
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"os"
+)
+
+// winsize mirrors struct winsize from <sys/ioctl.h>
+type winsize struct {
+	rows   uint16
+	cols   uint16
+	xpixel uint16
+	ypixel uint16
+}
+
+var errPTYUnsupported = errors.New("pty allocation is only supported on linux")
+
+// openPTY is not supported on this platform
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errPTYUnsupported
+}
+
+// getWinsize is not supported on this platform
+func getWinsize(f *os.File) (*winsize, error) {
+	return nil, errPTYUnsupported
+}
+
+// setWinsize is not supported on this platform
+func setWinsize(f *os.File, ws *winsize) error {
+	return errPTYUnsupported
+}
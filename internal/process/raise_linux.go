@@ -0,0 +1,45 @@
+//go:build linux && (amd64 || arm64)
+
+package process
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// sigaction mirrors the kernel's struct sigaction on amd64 and arm64
+type sigaction struct {
+	handler  uintptr
+	flags    uint64
+	restorer uintptr
+	mask     uint64
+}
+
+// RaiseDefault terminates the process with sig as if it had no handler, so
+// the parent sees the same cause of death. The handler is reset in the kernel
+// directly, since the Go runtime's own would turn signals such as SIGSEGV
+// into a stack dump. It only returns if sig did not terminate the process
+func RaiseDefault(sig syscall.Signal) error {
+	act := sigaction{} // SIG_DFL
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_RT_SIGACTION, uintptr(sig),
+		uintptr(unsafe.Pointer(&act)), 0, unsafe.Sizeof(act.mask), 0, 0); errno != 0 {
+		return fmt.Errorf("failed to reset handler of %v: %w", sig, errno)
+	}
+
+	// Deliver to this thread with the signal unblocked
+	runtime.LockOSThread()
+	set := uint64(1) << (sig - 1)
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_RT_SIGPROCMASK, 1, // SIG_UNBLOCK
+		uintptr(unsafe.Pointer(&set)), 0, unsafe.Sizeof(set), 0, 0); errno != 0 {
+		return fmt.Errorf("failed to unblock %v: %w", sig, errno)
+	}
+	if err := syscall.Tgkill(syscall.Getpid(), syscall.Gettid(), sig); err != nil {
+		return fmt.Errorf("failed to raise %v: %w", sig, err)
+	}
+
+	time.Sleep(time.Second)
+	return fmt.Errorf("signal %v did not terminate the process", sig)
+}
@@ -0,0 +1,25 @@
+//go:build !linux || !(amd64 || arm64)
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// RaiseDefault terminates the process with sig after restoring its default
+// handling. Signals the Go runtime always handles itself, such as SIGSEGV,
+// end in a stack dump instead on this platform. It only returns if sig did
+// not terminate the process
+func RaiseDefault(sig syscall.Signal) error {
+	signal.Reset(sig)
+	if err := syscall.Kill(os.Getpid(), sig); err != nil {
+		return fmt.Errorf("failed to raise %v: %w", sig, err)
+	}
+
+	time.Sleep(time.Second)
+	return fmt.Errorf("signal %v did not terminate the process", sig)
+}
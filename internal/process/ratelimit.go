@@ -0,0 +1,30 @@
+package process
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket allows up to burst events at once, refilled at rate per second
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, now time.Time) *tokenBucket {
+	burst := math.Max(1, rate)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// allow takes a token if one is available
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
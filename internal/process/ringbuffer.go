@@ -0,0 +1,54 @@
+package process
+
+import "sync"
+
+// RingBuffer keeps the most recent output written to it, up to a fixed size,
+// overwriting the oldest once full. Writes only copy into memory under a
+// short lock and never fail, so teeing the child's output into it can never
+// hold up or break the child's writes
+type RingBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	pos  int  // where the next write starts
+	full bool // buf wrapped around, so all of it is valid
+}
+
+// NewRingBuffer creates a ring buffer keeping the last size bytes
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{buf: make([]byte, size)}
+}
+
+// Write appends p, dropping the oldest output that no longer fits
+func (b *RingBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(b.buf) == 0 {
+		return n, nil
+	}
+	if len(p) > len(b.buf) {
+		p = p[len(p)-len(b.buf):]
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	copied := copy(b.buf[b.pos:], p)
+	copy(b.buf, p[copied:])
+	if b.pos+len(p) >= len(b.buf) {
+		b.full = true
+	}
+	b.pos = (b.pos + len(p)) % len(b.buf)
+	return n, nil
+}
+
+// Bytes returns a copy of the buffered output, oldest first
+func (b *RingBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]byte(nil), b.buf[:b.pos]...)
+	}
+	out := make([]byte, 0, len(b.buf))
+	out = append(out, b.buf[b.pos:]...)
+	return append(out, b.buf[:b.pos]...)
+}
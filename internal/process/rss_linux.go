@@ -0,0 +1,44 @@
+//go:build linux
+
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RSSSupported reports whether ReadRSS works on this platform
+const RSSSupported = true
+
+// rssField is the index of rss among the fields of /proc/<pid>/stat that
+// follow the parenthesized command name, starting with the state
+const rssField = 21
+
+// ReadRSS returns the resident set size of process pid in bytes, read from
+// /proc/<pid>/stat
+func ReadRSS(pid int) (int64, error) {
+	path := fmt.Sprintf("/proc/%d/stat", pid)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name may contain spaces and parentheses
+	stat := string(data)
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("malformed %s", path)
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) <= rssField {
+		return 0, fmt.Errorf("malformed %s: %d fields", path, len(fields))
+	}
+
+	pages, err := strconv.ParseInt(fields[rssField], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed rss in %s: %w", path, err)
+	}
+	return pages * int64(os.Getpagesize()), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package process
+
+import "errors"
+
+// RSSSupported reports whether ReadRSS works on this platform
+const RSSSupported = false
+
+// ReadRSS is not supported on this platform
+func ReadRSS(pid int) (int64, error) {
+	return 0, errors.New("reading the resident set size is only supported on linux")
+}
@@ -0,0 +1,22 @@
+package process
+
+import "syscall"
+
+// nonTerminatingSignals are signals whose default action does not end the
+// process, so they can't be used to force a child to stop
+var nonTerminatingSignals = map[syscall.Signal]bool{
+	syscall.SIGCHLD:  true,
+	syscall.SIGCONT:  true,
+	syscall.SIGSTOP:  true,
+	syscall.SIGTSTP:  true,
+	syscall.SIGTTIN:  true,
+	syscall.SIGTTOU:  true,
+	syscall.SIGURG:   true,
+	syscall.SIGWINCH: true,
+}
+
+// IsTerminatingSignal reports whether sig terminates a process that does not
+// handle it
+func IsTerminatingSignal(sig syscall.Signal) bool {
+	return sig > 0 && !nonTerminatingSignals[sig]
+}
@@ -0,0 +1,47 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Errors returned by NewFileWatcher, wrapped together with the underlying
+// error so callers can react with errors.Is
+var (
+	// ErrWatchLimit means the inotify watch or instance limit was reached
+	// (fs.inotify.max_user_watches or max_user_instances)
+	ErrWatchLimit = errors.New("inotify watch or instance limit reached")
+
+	// ErrPermission means the file or its directory can't be accessed
+	ErrPermission = errors.New("permission denied")
+
+	// ErrNotRegularFile means the path is a directory or another special file
+	ErrNotRegularFile = errors.New("not a regular file")
+
+	// ErrWatchUnsupported means fsnotify cannot watch the path for another
+	// reason, such as a filesystem without inotify support
+	ErrWatchUnsupported = errors.New("fsnotify not supported")
+)
+
+// classify wraps err with the error above matching its cause, if any
+func classify(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EMFILE):
+		return fmt.Errorf("%w: %w", ErrWatchLimit, err)
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	}
+	return err
+}
+
+// classifyWatch is classify for errors setting up fsnotify, where any other
+// cause means fsnotify is not supported for the path
+func classifyWatch(err error) error {
+	err = classify(err)
+	if errors.Is(err, ErrWatchLimit) || errors.Is(err, ErrPermission) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrWatchUnsupported, err)
+}
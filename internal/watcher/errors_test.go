@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	t.Run("watch limit", func(t *testing.T) {
+		for _, errno := range []syscall.Errno{syscall.ENOSPC, syscall.EMFILE} {
+			err := classify(fmt.Errorf("add watch: %w", errno))
+			assert.ErrorIs(t, err, ErrWatchLimit)
+			assert.ErrorIs(t, err, errno)
+			assert.Contains(t, err.Error(), errno.Error())
+		}
+	})
+
+	t.Run("permission", func(t *testing.T) {
+		err := classify(&os.PathError{Op: "lstat", Path: "/x", Err: syscall.EACCES})
+		assert.ErrorIs(t, err, ErrPermission)
+		assert.ErrorIs(t, err, os.ErrPermission)
+
+		var pathErr *os.PathError
+		assert.True(t, errors.As(err, &pathErr))
+	})
+
+	t.Run("other errors unchanged", func(t *testing.T) {
+		orig := errors.New("boom")
+		assert.Equal(t, orig, classify(orig))
+	})
+
+	t.Run("unsupported watch", func(t *testing.T) {
+		err := classifyWatch(fmt.Errorf("add watch: %w", syscall.ENOTSUP))
+		assert.ErrorIs(t, err, ErrWatchUnsupported)
+		assert.ErrorIs(t, err, syscall.ENOTSUP)
+
+		assert.NotErrorIs(t, classifyWatch(syscall.ENOSPC), ErrWatchUnsupported)
+		assert.NotErrorIs(t, classifyWatch(syscall.EACCES), ErrWatchUnsupported)
+	})
+}
+
+func TestFilesystemType(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("statfs filesystem types are only named on linux")
+	}
+	assert.NotEqual(t, "unknown", FilesystemType(t.TempDir()))
+	assert.Equal(t, "unknown", FilesystemType(filepath.Join(t.TempDir(), "missing")))
+}
+
+func TestNewFileWatcher_NotRegularFile(t *testing.T) {
+	t.Run("directory", func(t *testing.T) {
+		_, err := NewFileWatcher(t.TempDir())
+		assert.ErrorIs(t, err, ErrNotRegularFile)
+	})
+
+	t.Run("symlink to directory", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		link := filepath.Join(tmpDir, "link")
+		require.NoError(t, os.Symlink(t.TempDir(), link))
+
+		_, err := NewFileWatcher(link)
+		assert.ErrorIs(t, err, ErrNotRegularFile)
+	})
+}
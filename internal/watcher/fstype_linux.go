@@ -0,0 +1,41 @@
+//go:build linux
+
+package watcher
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// filesystemNames maps statfs magic numbers to filesystem names, for the
+// filesystems config files commonly live on
+var filesystemNames = map[uint32]string{
+	0xEF53:     "ext4",
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x858458F6: "ramfs",
+	0x794C7630: "overlayfs",
+	0x65735546: "fuse",
+	0x6969:     "nfs",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x01021997: "9p",
+	0x786F4256: "vboxsf",
+	0x00C36400: "ceph",
+	0x73717368: "squashfs",
+}
+
+// FilesystemType names the filesystem path is on, from statfs, to explain
+// why file events may not be delivered there
+func FilesystemType(path string) string {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return "unknown"
+	}
+	if name, ok := filesystemNames[uint32(fs.Type)]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", uint32(fs.Type))
+}
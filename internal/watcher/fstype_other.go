@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watcher
+
+// FilesystemType names the filesystem path is on; only supported on linux
+func FilesystemType(path string) string {
+	return "unknown"
+}
@@ -0,0 +1,28 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileMetadata is the part of a file's state compared with WatchMetadata
+type fileMetadata struct {
+	mode os.FileMode
+	uid  uint32
+	gid  uint32
+}
+
+// metadataOf returns the permissions and ownership from stat
+func metadataOf(stat os.FileInfo) fileMetadata {
+	meta := fileMetadata{mode: stat.Mode()}
+	if sysStat, ok := stat.Sys().(*syscall.Stat_t); ok {
+		meta.uid = sysStat.Uid
+		meta.gid = sysStat.Gid
+	}
+	return meta
+}
+
+func (m fileMetadata) String() string {
+	return fmt.Sprintf("%v %d:%d", m.mode, m.uid, m.gid)
+}
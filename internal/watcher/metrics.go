@@ -0,0 +1,22 @@
+package watcher
+
+import "github.com/zlrrr/flush-manager/internal/metrics"
+
+// Metrics exported by the watcher on /metrics
+var (
+	changesDetectedTotal = metrics.NewCounterVec("flushmanager_changes_detected_total",
+		"Number of config file changes detected, by detection source", "source")
+	fsnotifyChangesTotal = changesDetectedTotal.With(sourceFsnotify)
+	pollChangesTotal     = changesDetectedTotal.With(sourcePoll)
+	hashChangesTotal     = changesDetectedTotal.With(sourcePollHash)
+
+	oversizedConfigsTotal = metrics.NewCounter("flushmanager_config_oversized_total",
+		"Number of times the config file grew beyond the size limit")
+)
+
+// Change detection sources
+const (
+	sourceFsnotify = "fsnotify"
+	sourcePoll     = "poll"
+	sourcePollHash = "poll-hash"
+)
@@ -0,0 +1,243 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// RecursiveOptions configures a recursive directory watcher
+type RecursiveOptions struct {
+	// MaxDepth is how many directory levels below the root are watched;
+	// 0 watches the root directory only. Every watched directory uses one
+	// inotify watch, so this bounds the cost of a deep tree
+	MaxDepth int
+
+	// Pattern filters the files whose changes are reported, as a
+	// filepath.Match glob on the file name (e.g. "*.yml"). Empty matches
+	// every file
+	Pattern string
+}
+
+type recursiveWatcher struct {
+	root       string
+	opts       RecursiveOptions
+	watcher    *fsnotify.Watcher
+	changeChan chan ChangeEvent
+	debounce   time.Duration
+	mu         sync.Mutex
+	dirs       map[string]bool // watched directories
+}
+
+// NewRecursiveWatcher watches the directory tree at root, up to
+// opts.MaxDepth levels deep, and reports a coalesced change whenever a file
+// matching opts.Pattern is written, created, removed or renamed. Directories
+// created later are watched as well, within the depth limit
+func NewRecursiveWatcher(root string, opts RecursiveOptions) (FileWatcher, error) {
+	if opts.MaxDepth < 0 {
+		return nil, fmt.Errorf("max depth must not be negative, got %d", opts.MaxDepth)
+	}
+	if _, err := filepath.Match(opts.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", opts.Pattern, err)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat directory %s: %w", root, classify(err))
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("cannot watch %s recursively: not a directory", root)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", classify(err))
+	}
+
+	rw := &recursiveWatcher{
+		root:       filepath.Clean(root),
+		opts:       opts,
+		watcher:    watcher,
+		changeChan: make(chan ChangeEvent, 1),
+		debounce:   500 * time.Millisecond,
+		dirs:       make(map[string]bool),
+	}
+	if _, err := rw.addTree(rw.root); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	logger.Info("Watching %d directories under %s (max depth %d, pattern %q)",
+		rw.watchCount(), rw.root, opts.MaxDepth, opts.Pattern)
+
+	return rw, nil
+}
+
+// depth returns how many levels below the root dir is
+func (rw *recursiveWatcher) depth(dir string) int {
+	rel, err := filepath.Rel(rw.root, dir)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// matches reports whether changes to the file at path are reported
+func (rw *recursiveWatcher) matches(path string) bool {
+	if rw.opts.Pattern == "" {
+		return true
+	}
+	ok, _ := filepath.Match(rw.opts.Pattern, filepath.Base(path))
+	return ok
+}
+
+// addTree watches dir and its subdirectories within the depth limit. It
+// reports whether the tree already contains matching files
+func (rw *recursiveWatcher) addTree(dir string) (bool, error) {
+	found := false
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// A subdirectory vanished or is unreadable; skip it
+			logger.Debug("Skipping %s: %v", path, err)
+			return nil
+		}
+		if !d.IsDir() {
+			found = found || rw.matches(path)
+			return nil
+		}
+		if rw.depth(path) > rw.opts.MaxDepth {
+			return filepath.SkipDir
+		}
+
+		if err := rw.watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch directory %s: %w", path, classify(err))
+		}
+		rw.mu.Lock()
+		rw.dirs[path] = true
+		rw.mu.Unlock()
+		return nil
+	})
+	return found, err
+}
+
+// watchCount returns the number of watched directories
+func (rw *recursiveWatcher) watchCount() int {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return len(rw.dirs)
+}
+
+// ActiveWatches lists the watched directories, sorted
+func (rw *recursiveWatcher) ActiveWatches() []WatchInfo {
+	return fsnotifyWatches(rw.watcher)
+}
+
+// Start starts watching for changes
+func (rw *recursiveWatcher) Start(ctx context.Context) error {
+	logger.Info("Starting recursive watcher for %s", rw.root)
+	go rw.watch(ctx)
+	return nil
+}
+
+// Changes returns a channel that receives notifications when a matching
+// file changes
+func (rw *recursiveWatcher) Changes() <-chan ChangeEvent {
+	return rw.changeChan
+}
+
+// Close closes the watcher
+func (rw *recursiveWatcher) Close() error {
+	logger.Debug("Closing recursive watcher for %s", rw.root)
+	return rw.watcher.Close()
+}
+
+// watch handles fsnotify events, debouncing matching ones into a single
+// change notification
+func (rw *recursiveWatcher) watch(ctx context.Context) {
+	var debounceTimer *time.Timer
+	defer func() {
+		if debounceTimer != nil {
+			debounceTimer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-rw.watcher.Events:
+			if !ok {
+				return
+			}
+			if !rw.handleEvent(event) {
+				continue
+			}
+
+			if debounceTimer != nil {
+				debounceTimer.Stop()
+			}
+			debounceTimer = time.AfterFunc(rw.debounce, func() {
+				logger.Info("Change under %s confirmed after debounce period", rw.root)
+				select {
+				case rw.changeChan <- ChangeEvent{Path: rw.root, Source: sourceFsnotify, Time: time.Now()}:
+				default:
+					logger.Debug("Change notification already pending")
+				}
+			})
+
+		case err, ok := <-rw.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Error("Recursive watcher error: %v", err)
+		}
+	}
+}
+
+// handleEvent keeps the set of watched directories up to date and reports
+// whether event is a change to report
+func (rw *recursiveWatcher) handleEvent(event fsnotify.Event) bool {
+	logger.Debug("Fsnotify event: %s", event)
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		rw.mu.Lock()
+		watched := rw.dirs[event.Name]
+		delete(rw.dirs, event.Name)
+		rw.mu.Unlock()
+		if watched {
+			logger.Info("Directory %s removed, %d directories watched", event.Name, rw.watchCount())
+			return false
+		}
+	}
+
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if rw.depth(event.Name) > rw.opts.MaxDepth {
+				logger.Debug("Not watching %s beyond max depth %d", event.Name, rw.opts.MaxDepth)
+				return false
+			}
+			found, err := rw.addTree(event.Name)
+			if err != nil {
+				logger.Error("Failed to watch new directory: %v", err)
+			}
+			logger.Info("Directory %s created, %d directories watched", event.Name, rw.watchCount())
+			return found
+		}
+	}
+
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	return rw.matches(event.Name)
+}
@@ -0,0 +1,140 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startRecursive creates and starts a recursive watcher for root
+func startRecursive(t *testing.T, root string, opts RecursiveOptions) *recursiveWatcher {
+	t.Helper()
+	fw, err := NewRecursiveWatcher(root, opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { fw.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, fw.Start(ctx))
+	return fw.(*recursiveWatcher)
+}
+
+// expectChange asserts whether a change is reported within the debounce
+// period plus some slack
+func expectChange(t *testing.T, fw FileWatcher, want bool) {
+	t.Helper()
+	select {
+	case <-fw.Changes():
+		assert.True(t, want, "unexpected change notification")
+	case <-time.After(1500 * time.Millisecond):
+		assert.False(t, want, "expected change notification")
+	}
+}
+
+func TestNewRecursiveWatcher(t *testing.T) {
+	t.Run("watches subdirectories up to max depth", func(t *testing.T) {
+		root := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "d"), 0755))
+
+		fw, err := NewRecursiveWatcher(root, RecursiveOptions{MaxDepth: 2})
+		require.NoError(t, err)
+		defer fw.Close()
+
+		// root, a, a/b and d; a/b/c is too deep
+		assert.Equal(t, 4, fw.(*recursiveWatcher).watchCount())
+	})
+
+	t.Run("rejects a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+
+		_, err := NewRecursiveWatcher(path, RecursiveOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects an invalid pattern", func(t *testing.T) {
+		_, err := NewRecursiveWatcher(t.TempDir(), RecursiveOptions{Pattern: "["})
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects a negative depth", func(t *testing.T) {
+		_, err := NewRecursiveWatcher(t.TempDir(), RecursiveOptions{MaxDepth: -1})
+		assert.Error(t, err)
+	})
+}
+
+func TestRecursiveWatcher_Changes(t *testing.T) {
+	t.Run("detect change in nested file", func(t *testing.T) {
+		root := t.TempDir()
+		dir := filepath.Join(root, "a", "b")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		fw := startRecursive(t, root, RecursiveOptions{MaxDepth: 2})
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yml"), []byte("x"), 0644))
+		expectChange(t, fw, true)
+	})
+
+	t.Run("coalesce changes to several files", func(t *testing.T) {
+		root := t.TempDir()
+		fw := startRecursive(t, root, RecursiveOptions{})
+
+		for _, name := range []string{"one.yml", "two.yml", "three.yml"} {
+			require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("x"), 0644))
+		}
+		expectChange(t, fw, true)
+		expectChange(t, fw, false)
+	})
+
+	t.Run("ignore files not matching the pattern", func(t *testing.T) {
+		root := t.TempDir()
+		fw := startRecursive(t, root, RecursiveOptions{Pattern: "*.yml"})
+
+		require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("x"), 0644))
+		expectChange(t, fw, false)
+
+		require.NoError(t, os.WriteFile(filepath.Join(root, "app.yml"), []byte("x"), 0644))
+		expectChange(t, fw, true)
+	})
+
+	t.Run("ignore changes beyond max depth", func(t *testing.T) {
+		root := t.TempDir()
+		deep := filepath.Join(root, "a", "b")
+		require.NoError(t, os.MkdirAll(deep, 0755))
+		fw := startRecursive(t, root, RecursiveOptions{MaxDepth: 1})
+
+		require.NoError(t, os.WriteFile(filepath.Join(deep, "config.yml"), []byte("x"), 0644))
+		expectChange(t, fw, false)
+	})
+
+	t.Run("watch newly created subdirectories", func(t *testing.T) {
+		root := t.TempDir()
+		fw := startRecursive(t, root, RecursiveOptions{MaxDepth: 1, Pattern: "*.yml"})
+
+		dir := filepath.Join(root, "new")
+		require.NoError(t, os.Mkdir(dir, 0755))
+		require.Eventually(t, func() bool { return fw.watchCount() == 2 }, time.Second, 10*time.Millisecond)
+		expectChange(t, fw, false)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yml"), []byte("x"), 0644))
+		expectChange(t, fw, true)
+
+		require.NoError(t, os.RemoveAll(dir))
+		assert.Eventually(t, func() bool { return fw.watchCount() == 1 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("do not watch new subdirectories beyond max depth", func(t *testing.T) {
+		root := t.TempDir()
+		fw := startRecursive(t, root, RecursiveOptions{MaxDepth: 1})
+
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+		require.Eventually(t, func() bool { return fw.watchCount() == 2 }, time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, 2, fw.watchCount())
+	})
+}
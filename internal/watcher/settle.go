@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// maxSettleWait bounds how long a change waits for the config file to
+// settle; a file that keeps changing is reported anyway, so no change is lost
+const maxSettleWait = 10 * time.Second
+
+// resolvedState identifies the file a config path currently resolves to
+type resolvedState struct {
+	path    string
+	inode   uint64
+	size    int64
+	modTime time.Time
+}
+
+// resolveState resolves path through any symlinks and checks that the target
+// is a readable regular file
+func resolveState(path string) (resolvedState, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return resolvedState{}, err
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		return resolvedState{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return resolvedState{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return resolvedState{}, fmt.Errorf("%s: %w", target, ErrNotRegularFile)
+	}
+
+	state := resolvedState{path: target, size: info.Size(), modTime: info.ModTime()}
+	if sysStat, ok := info.Sys().(*syscall.Stat_t); ok {
+		state.inode = sysStat.Ino
+	}
+	return state, nil
+}
+
+// settle blocks until the config path resolves to a readable regular file
+// that stays the same for a whole stability window, so a half-applied
+// ConfigMap update (..data swapped, file not there yet) or a file still
+// being written is not reported. Only one settle runs at a time; it reports
+// false if another one already covers the change
+func (fw *fileWatcher) settle() bool {
+	if !fw.settling.CompareAndSwap(false, true) {
+		logger.Debug("Change already waiting for %s to settle", fw.filePath)
+		return false
+	}
+	defer fw.settling.Store(false)
+
+	window := fw.stabilityWindow()
+	// A window longer than maxSettleWait still gets a second look
+	maxWait := max(maxSettleWait, 2*window)
+	deadline := time.Now().Add(maxWait)
+	prev, prevErr := resolveState(fw.filePath)
+	for {
+		time.Sleep(window)
+		cur, err := resolveState(fw.filePath)
+		if fw.stable(prev, prevErr, cur, err) {
+			logger.Debug("Config file %s settled at %s", fw.filePath, cur.path)
+			return true
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				logger.Warn("Config file %s did not settle within %v (%v), reporting the change anyway",
+					fw.filePath, maxWait, err)
+			} else {
+				logger.Warn("Config file %s did not settle within %v, reporting the change anyway",
+					fw.filePath, maxWait)
+			}
+			return true
+		}
+		if err != nil {
+			logger.Debug("Waiting for config file %s to settle: %v", fw.filePath, err)
+		}
+		prev, prevErr = cur, err
+	}
+}
+
+// stabilityWindow is how long the file has to stay unchanged to settle
+func (fw *fileWatcher) stabilityWindow() time.Duration {
+	if fw.opts.StabilityWindow > 0 {
+		return fw.opts.StabilityWindow
+	}
+	return fw.debounce
+}
+
+// stable reports whether the file stayed the same between two checks. With
+// only a stability window, a file that stayed missing counts as stable, so
+// its removal is reported without waiting for maxSettleWait
+func (fw *fileWatcher) stable(prev resolvedState, prevErr error, cur resolvedState, err error) bool {
+	if err == nil && prevErr == nil {
+		return cur == prev
+	}
+	return !fw.opts.Settle && errors.Is(err, os.ErrNotExist) && errors.Is(prevErr, os.ErrNotExist)
+}
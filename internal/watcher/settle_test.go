@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configMapDir lays out a mounted ConfigMap: config.yml -> ..data/config.yml
+// and ..data -> ..v1, the way the kubelet does
+func configMapDir(t *testing.T, content string) (dir, file string) {
+	t.Helper()
+	dir = t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..v1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "..v1", "config.yml"), []byte(content), 0644))
+	require.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+	file = filepath.Join(dir, "config.yml")
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config.yml"), file))
+	return dir, file
+}
+
+// swapData atomically points ..data at version, as the kubelet does: a
+// ..data_tmp symlink renamed over ..data, then the old version removed
+func swapData(t *testing.T, dir, version, old string) {
+	t.Helper()
+	tmp := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(version, tmp))
+	require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, old)))
+}
+
+// startSettling starts a watcher on file with Settle and a short debounce
+func startSettling(t *testing.T, file string) FileWatcher {
+	t.Helper()
+	fw, err := NewFileWatcherWithOptions(file, Options{Settle: true, Mode: WatchFsnotify})
+	require.NoError(t, err)
+	fw.(*fileWatcher).debounce = 200 * time.Millisecond
+	t.Cleanup(func() { fw.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, fw.Start(ctx))
+	time.Sleep(100 * time.Millisecond)
+	return fw
+}
+
+func TestFileWatcher_Settle(t *testing.T) {
+	t.Run("configmap update sequence yields one change", func(t *testing.T) {
+		dir, file := configMapDir(t, "v1")
+		fw := startSettling(t, file)
+
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..v2"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "..v2", "config.yml"), []byte("v2"), 0644))
+		swapData(t, dir, "..v2", "..v1")
+
+		select {
+		case event := <-fw.Changes():
+			assert.Equal(t, file, event.Path)
+			content, err := os.ReadFile(file)
+			require.NoError(t, err)
+			assert.Equal(t, "v2", string(content))
+		case <-time.After(3 * time.Second):
+			t.Fatal("timeout waiting for change notification")
+		}
+
+		select {
+		case <-fw.Changes():
+			t.Fatal("one update must yield one change")
+		case <-time.After(time.Second):
+		}
+	})
+
+	t.Run("waits until the new file stops changing", func(t *testing.T) {
+		dir, file := configMapDir(t, "v1")
+		fw := startSettling(t, file)
+
+		// The new version is still being written when ..data is swapped
+		newFile := filepath.Join(dir, "..v2", "config.yml")
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..v2"), 0755))
+		require.NoError(t, os.WriteFile(newFile, []byte("v"), 0644))
+		swapData(t, dir, "..v2", "..v1")
+
+		content := "v"
+		var lastWrite time.Time
+		for i := 0; i < 5; i++ {
+			time.Sleep(150 * time.Millisecond)
+			content += "2"
+			require.NoError(t, os.WriteFile(newFile, []byte(content), 0644))
+			lastWrite = time.Now()
+		}
+
+		select {
+		case event := <-fw.Changes():
+			assert.True(t, event.Time.After(lastWrite), "change reported before the file settled")
+			data, err := os.ReadFile(file)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(data))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for change notification")
+		}
+	})
+
+	t.Run("resolve state", func(t *testing.T) {
+		dir, file := configMapDir(t, "v1")
+		state, err := resolveState(file)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "..v1", "config.yml"), state.path)
+		assert.Equal(t, int64(2), state.size)
+
+		// ..data pointing at a version without the file
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..v2"), 0755))
+		swapData(t, dir, "..v2", "..v1")
+		_, err = resolveState(file)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		_, err = resolveState(dir)
+		assert.ErrorIs(t, err, ErrNotRegularFile)
+	})
+}
+
+func TestFileWatcher_StabilityWindow(t *testing.T) {
+	const window = 400 * time.Millisecond
+
+	file := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(file, []byte("a: 1\n"), 0644))
+
+	fw, err := NewFileWatcherWithOptions(file, Options{StabilityWindow: window, Mode: WatchFsnotify})
+	require.NoError(t, err)
+	fw.(*fileWatcher).debounce = 100 * time.Millisecond
+	t.Cleanup(func() { fw.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, fw.Start(ctx))
+	time.Sleep(100 * time.Millisecond)
+
+	// Each append comes within the window of the previous one
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	var lastWrite time.Time
+	for i := 0; i < 6; i++ {
+		_, err := f.WriteString("b: 2\n")
+		require.NoError(t, err)
+		lastWrite = time.Now()
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	select {
+	case event := <-fw.Changes():
+		assert.GreaterOrEqual(t, event.Time.Sub(lastWrite), window, "change reported while the file was still being written")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for change notification")
+	}
+
+	select {
+	case <-fw.Changes():
+		t.Fatal("one write sequence must yield one change")
+	case <-time.After(time.Second):
+	}
+}
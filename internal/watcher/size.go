@@ -0,0 +1,36 @@
+package watcher
+
+import (
+	"os"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// oversized reports whether the watched file is larger than MaxConfigSize.
+// It warns and counts when the file grows beyond the limit, not on every
+// check while it stays there
+func (fw *fileWatcher) oversized() bool {
+	if fw.opts.MaxConfigSize <= 0 {
+		return false
+	}
+	stat, err := os.Stat(fw.filePath)
+	if err != nil {
+		return false
+	}
+
+	over := stat.Size() > fw.opts.MaxConfigSize
+	switch {
+	case over && !fw.overLimit:
+		oversizedConfigsTotal.Inc()
+		action := "comparing its stat instead of hashing its content"
+		if fw.opts.RejectOversized {
+			action = "ignoring its changes"
+		}
+		logger.Warn("Config file %s is %d bytes, over the limit of %d bytes; %s",
+			fw.filePath, stat.Size(), fw.opts.MaxConfigSize, action)
+	case !over && fw.overLimit:
+		logger.Info("Config file %s is within the size limit again", fw.filePath)
+	}
+	fw.overLimit = over
+	return over
+}
@@ -0,0 +1,37 @@
+package watcher
+
+import (
+	"context"
+	"time"
+)
+
+// ChangeEvent describes a change detected by a ChangeSource
+type ChangeEvent struct {
+	// Path is what changed, e.g. the watched file; empty if not applicable
+	Path string
+
+	// Source names how the change was detected, e.g. "fsnotify" or "poll"
+	Source string
+
+	// Time is when the change was detected
+	Time time.Time
+
+	// Reason qualifies the change, e.g. ReasonMetadata; empty for a change
+	// of content or if unknown
+	Reason string
+}
+
+// ReasonMetadata marks a change to a file's permissions or ownership that
+// left its content unchanged
+const ReasonMetadata = "metadata"
+
+// ChangeSource detects changes the manager reacts to. fsnotify and polling
+// are built in; other backends, such as an HTTP endpoint or a message bus,
+// implement this interface to trigger the manager without changes to it.
+// Changes should hold at most one pending event, coalescing further changes
+// until it is received. A nil channel means the source never fires
+type ChangeSource interface {
+	Start(ctx context.Context) error
+	Changes() <-chan ChangeEvent
+	Close() error
+}
@@ -0,0 +1,39 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// statRetries is how often a stat failing with a transient error is
+	// retried, such as in the moment of an atomic replace
+	statRetries = 2
+
+	// statRetryDelay is the pause before each retry
+	statRetryDelay = 5 * time.Millisecond
+)
+
+// statRetrying stats the watched file, retrying briefly on transient
+// errors. A file still missing after the retries is most likely removed for
+// good. It sleeps between retries, so it must not be called with fw.mu held
+func (fw *fileWatcher) statRetrying() (os.FileInfo, error) {
+	stat, err := fw.stat(fw.filePath)
+	for i := 0; i < statRetries && err != nil && transientStatError(err); i++ {
+		time.Sleep(statRetryDelay)
+		stat, err = fw.stat(fw.filePath)
+	}
+	return stat, err
+}
+
+// transientStatError reports whether a stat error may go away on its own:
+// the path missing between the unlink and rename of a replace, an
+// interrupted call, or a stale NFS handle
+func transientStatError(err error) bool {
+	return errors.Is(err, os.ErrNotExist) ||
+		errors.Is(err, syscall.EINTR) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.ESTALE)
+}
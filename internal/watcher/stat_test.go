@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWatcher_StatRetry(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.conf")
+	require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+	w, err := NewFileWatcherWithOptions(filePath, Options{Mode: WatchPoll})
+	require.NoError(t, err)
+	defer w.Close()
+	fw := w.(*fileWatcher)
+
+	t.Run("change found mid-rename", func(t *testing.T) {
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filePath, later, later))
+
+		// The poll lands between the unlink and the rename of a replace
+		calls := 0
+		fw.stat = func(name string) (os.FileInfo, error) {
+			calls++
+			if calls == 1 {
+				return nil, &fs.PathError{Op: "stat", Path: name, Err: syscall.ENOENT}
+			}
+			return os.Stat(name)
+		}
+		defer func() { fw.stat = os.Stat }()
+
+		assert.True(t, fw.changed())
+		assert.Equal(t, 2, calls)
+		assert.False(t, fw.missing)
+	})
+
+	t.Run("removed file", func(t *testing.T) {
+		require.NoError(t, os.Remove(filePath))
+		assert.False(t, fw.changed())
+		assert.True(t, fw.missing)
+		assert.False(t, fw.changed())
+
+		// Recreated, it is a change again
+		require.NoError(t, os.WriteFile(filePath, []byte("recreated"), 0644))
+		later := time.Now().Add(2 * time.Minute)
+		require.NoError(t, os.Chtimes(filePath, later, later))
+		assert.True(t, fw.changed())
+		assert.False(t, fw.missing)
+	})
+
+	t.Run("retries run without the lock", func(t *testing.T) {
+		retrying := make(chan struct{})
+		release := make(chan struct{})
+		fw.stat = func(name string) (os.FileInfo, error) {
+			select {
+			case retrying <- struct{}{}:
+				<-release
+			default:
+			}
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: syscall.ENOENT}
+		}
+		defer func() { fw.stat = os.Stat }()
+
+		done := make(chan bool)
+		go func() {
+			done <- fw.changed()
+		}()
+		<-retrying
+		// Another strategy can take the lock while the stat is in flight
+		fw.mu.Lock()
+		fw.mu.Unlock()
+		close(release)
+		assert.False(t, <-done)
+	})
+
+	t.Run("transient errors", func(t *testing.T) {
+		assert.True(t, transientStatError(&fs.PathError{Op: "stat", Err: syscall.ENOENT}))
+		assert.True(t, transientStatError(syscall.ESTALE))
+		assert.False(t, transientStatError(&fs.PathError{Op: "stat", Err: syscall.EACCES}))
+	})
+}
@@ -0,0 +1,175 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/zlrrr/flush-manager/internal/logger"
+)
+
+// Strategy is one way of detecting changes to the watched file. Several can
+// run together on one path; a change seen by more than one of them within
+// the debounce period is still reported once
+type Strategy int
+
+const (
+	// StrategyFsnotify reacts to file system events
+	StrategyFsnotify Strategy = iota
+	// StrategyPollStat polls the modification time, size and inode
+	StrategyPollStat
+	// StrategyPollHash polls a SHA-256 of the content, catching rewrites
+	// that keep the modification time and size, at the cost of reading the
+	// whole file on every poll
+	StrategyPollHash
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case StrategyFsnotify:
+		return "fsnotify"
+	case StrategyPollStat:
+		return "poll-stat"
+	case StrategyPollHash:
+		return "poll-hash"
+	default:
+		return fmt.Sprintf("Strategy(%d)", int(s))
+	}
+}
+
+// ParseStrategy parses a strategy name as returned by String
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range []Strategy{StrategyFsnotify, StrategyPollStat, StrategyPollHash} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown watch strategy %q (expected fsnotify, poll-stat or poll-hash)", name)
+}
+
+// ParseStrategies parses a comma-separated list of strategy names
+func ParseStrategies(list string) ([]Strategy, error) {
+	var strategies []Strategy
+	for _, name := range strings.Split(list, ",") {
+		s, err := ParseStrategy(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(strategies, s) {
+			return nil, fmt.Errorf("watch strategy %v listed twice", s)
+		}
+		strategies = append(strategies, s)
+	}
+	return strategies, nil
+}
+
+// strategies returns the strategies opts selects: Strategies if set,
+// otherwise the ones implied by Mode
+func (opts Options) strategies() []Strategy {
+	if len(opts.Strategies) > 0 {
+		return opts.Strategies
+	}
+	switch opts.Mode {
+	case WatchFsnotify:
+		return []Strategy{StrategyFsnotify}
+	case WatchPoll:
+		return []Strategy{StrategyPollStat}
+	default:
+		return []Strategy{StrategyFsnotify, StrategyPollStat}
+	}
+}
+
+// uses reports whether the watcher runs strategy s
+func (fw *fileWatcher) uses(s Strategy) bool {
+	return slices.Contains(fw.strategies, s)
+}
+
+// polls reports whether the watcher runs a polling strategy
+func (fw *fileWatcher) polls() bool {
+	return fw.uses(StrategyPollStat) || fw.uses(StrategyPollHash)
+}
+
+// contentHash is the SHA-256 of a file's content
+type contentHash = [sha256.Size]byte
+
+// hashFile returns the SHA-256 of the content of the file at path
+func hashFile(path string) (contentHash, error) {
+	var sum contentHash
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// refreshHash records the current content hash as seen, so a change already
+// detected by another strategy is not reported again by hash polling
+func (fw *fileWatcher) refreshHash() {
+	if !fw.uses(StrategyPollHash) || fw.oversized() {
+		return
+	}
+	if sum, err := hashFile(fw.filePath); err == nil {
+		fw.lastHash = sum
+	}
+}
+
+// hashChanged checks whether the content hash of the watched file changed
+func (fw *fileWatcher) hashChanged() bool {
+	stat, err := fw.statRetrying()
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.triggered(fw.checkHashChanged(stat, err))
+}
+
+// checkHashChanged compares the content hash with the last one seen. On a
+// change it also records the file's current stat, so the other strategies
+// do not report the same change again. An oversized file is not hashed but
+// checked by stat and err, the result of statRetrying
+func (fw *fileWatcher) checkHashChanged(stat os.FileInfo, err error) bool {
+	if fw.oversized() {
+		if fw.opts.RejectOversized {
+			return false
+		}
+		return fw.checkFileChanged(stat, err)
+	}
+
+	sum, err := hashFile(fw.filePath)
+	if err != nil {
+		logger.Error("Failed to hash file %s: %v", fw.filePath, err)
+		return false
+	}
+	if sum == fw.lastHash {
+		return false
+	}
+	fw.lastHash = sum
+
+	fw.lastKind = ChangeReplace
+	if stat, err := os.Stat(fw.filePath); err == nil {
+		var inode uint64
+		if sysStat, ok := stat.Sys().(*syscall.Stat_t); ok {
+			inode = sysStat.Ino
+		}
+		if inode == fw.lastInode && stat.Size() > fw.lastSize {
+			fw.lastKind = ChangeAppend
+		}
+		fw.lastModTime = stat.ModTime()
+		fw.lastInode = inode
+		fw.lastSize = stat.Size()
+		fw.lastMeta = metadataOf(stat)
+	}
+	fw.lastMetaOnly = false
+	logger.Info("File content change detected (%s) for %s", fw.lastKind, fw.filePath)
+	return true
+}
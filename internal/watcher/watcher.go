@@ -2,9 +2,14 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -12,25 +17,270 @@ import (
 	"github.com/zlrrr/flush-manager/internal/logger"
 )
 
-// FileWatcher watches for file changes
+// FileWatcher is a ChangeSource watching a file
 type FileWatcher interface {
-	Start(ctx context.Context) error
-	Changes() <-chan struct{}
-	Close() error
+	ChangeSource
+
+	// ActiveWatches lists the paths currently watched, for diagnostics
+	ActiveWatches() []WatchInfo
 }
 
 type fileWatcher struct {
 	filePath       string
 	watcher        *fsnotify.Watcher
-	changeChan     chan struct{}
+	changeChan     chan ChangeEvent
 	debounce       time.Duration
 	lastModTime    time.Time
 	lastInode      uint64
+	lastSize       int64
+	lastKind       ChangeKind   // kind of the change last detected
+	lastMeta       fileMetadata // permissions and ownership as last seen
+	lastMetaOnly   bool         // the change last detected was to metadata only
 	pollInterval   time.Duration
 	isSymlink      bool
 	realPath       string
+	watchFile      bool
+	opts           Options
+	pollOnly       bool
+	strategies     []Strategy
+	lastHash       contentHash // content hash as last seen, with StrategyPollHash
+	overLimit      bool        // the file exceeded MaxConfigSize when last checked
+	missing        bool        // the file was missing when last checked
+	mu             sync.Mutex // guards the file state shared by the poll and fsnotify loops
+	held           *os.File   // the file as last seen, with HoldOpen
+	fsnotifyCount  atomic.Uint64 // changes detected via fsnotify since the last summary
+	pollCount      atomic.Uint64 // changes detected via polling since the last summary
+	hashCount      atomic.Uint64 // changes detected via content hashing since the last summary
+	settling       atomic.Bool   // a change is waiting for the file to settle
+	debounceMu     sync.Mutex    // guards debounceTimer and pendingMeta
+	debounceTimer  *time.Timer   // pending change notification, shared by all strategies
+	pendingMeta    bool          // the pending notification is for metadata changes only
+
+	// stat is os.Stat, replaced in tests to simulate a rename race
+	stat func(string) (os.FileInfo, error)
+}
+
+// Options configures optional behavior of the file watcher
+type Options struct {
+	// SelfTest verifies on Start that fsnotify actually delivers events, and
+	// falls back to polling only if it does not
+	SelfTest bool
+
+	// SymlinkFollowMode selects how changes behind a symlinked config file
+	// are detected
+	SymlinkFollowMode SymlinkFollowMode
+
+	// Mode selects fsnotify, polling or both (default WatchAuto)
+	Mode WatchMode
+
+	// Strategies selects the change detection strategies to run together,
+	// overriding Mode. A change seen by several of them is reported once
+	Strategies []Strategy
+
+	// PollInterval is how often the file is polled (default 5s)
+	PollInterval time.Duration
+
+	// Trigger selects which kinds of change are reported (default any)
+	Trigger ChangeTrigger
+
+	// HoldOpen keeps the file open and detects replacement by comparing the
+	// held file with the one at the path. Holding the old file keeps its
+	// inode from being reused, which could otherwise hide a replacement
+	HoldOpen bool
+
+	// WatchMetadata also reports changes to the file's permissions or
+	// ownership when its content is unchanged, with ReasonMetadata
+	WatchMetadata bool
+
+	// MaxConfigSize, if set, is the largest file in bytes whose content is
+	// hashed. A larger file, e.g. from a runaway templating bug, is compared
+	// by its stat instead, or with RejectOversized its changes are not
+	// reported at all, whatever the strategy
+	MaxConfigSize int64
+
+	// RejectOversized drops changes that leave the file larger than
+	// MaxConfigSize, instead of falling back to comparing its stat
+	RejectOversized bool
+
+	// Settle holds a detected change back until the path resolves to a
+	// readable regular file that stays unchanged for the debounce window.
+	// A Kubernetes ConfigMap update swaps the ..data symlink in several
+	// steps; this avoids reloading on a half-applied one
+	Settle bool
+
+	// StabilityWindow holds a detected change back until the file's size
+	// and modification time stayed the same for this long, waiting longer
+	// while it is still being written. With Settle and no window set, the
+	// debounce period is used
+	StabilityWindow time.Duration
+
+	// DataMarker also treats an entry named data next to a symlinked config
+	// file as a ConfigMap marker, like ..data, for mounts that swap a data
+	// symlink instead
+	DataMarker bool
+}
+
+// ChangeKind classifies a detected change
+type ChangeKind int
+
+const (
+	// ChangeReplace is a new file at the path, or its content rewritten or
+	// truncated in place
+	ChangeReplace ChangeKind = iota
+	// ChangeAppend is the same file grown
+	ChangeAppend
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeReplace:
+		return "replace"
+	case ChangeAppend:
+		return "append"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+// ChangeTrigger selects which kinds of change are reported on Changes
+type ChangeTrigger int
+
+const (
+	// TriggerAny reports every change (default)
+	TriggerAny ChangeTrigger = iota
+	// TriggerAppend reports appends only
+	TriggerAppend
+	// TriggerReplace reports replacements only, ignoring appends to
+	// log-style files
+	TriggerReplace
+)
+
+func (t ChangeTrigger) String() string {
+	switch t {
+	case TriggerAny:
+		return "any"
+	case TriggerAppend:
+		return "append"
+	case TriggerReplace:
+		return "replace"
+	default:
+		return fmt.Sprintf("ChangeTrigger(%d)", int(t))
+	}
+}
+
+// ParseChangeTrigger parses a trigger name as returned by String
+func ParseChangeTrigger(name string) (ChangeTrigger, error) {
+	for _, t := range []ChangeTrigger{TriggerAny, TriggerAppend, TriggerReplace} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown change trigger %q (expected any, append or replace)", name)
+}
+
+// matches reports whether a change of kind is reported
+func (t ChangeTrigger) matches(kind ChangeKind) bool {
+	switch t {
+	case TriggerAppend:
+		return kind == ChangeAppend
+	case TriggerReplace:
+		return kind == ChangeReplace
+	default:
+		return true
+	}
+}
+
+// defaultPollInterval is how often the file is polled as a fallback
+const defaultPollInterval = 5 * time.Second
+
+// checkThrottle is the minimum time between two file state checks triggered
+// by fsnotify events. Events arriving faster are coalesced into one check at
+// the end of the interval, so a runaway writer cannot make the event loop
+// stat the file thousands of times per second
+const checkThrottle = 50 * time.Millisecond
+
+// WatchMode determines how file changes are detected
+type WatchMode int
+
+const (
+	// WatchAuto uses fsnotify with polling as a fallback (default)
+	WatchAuto WatchMode = iota
+	// WatchFsnotify uses fsnotify only, without polling
+	WatchFsnotify
+	// WatchPoll skips fsnotify entirely and only polls, for filesystems
+	// where inotify silently fails
+	WatchPoll
+)
+
+func (m WatchMode) String() string {
+	switch m {
+	case WatchAuto:
+		return "auto"
+	case WatchFsnotify:
+		return "fsnotify"
+	case WatchPoll:
+		return "poll"
+	default:
+		return fmt.Sprintf("WatchMode(%d)", int(m))
+	}
+}
+
+// ParseWatchMode parses a mode name as returned by String
+func ParseWatchMode(name string) (WatchMode, error) {
+	for _, m := range []WatchMode{WatchAuto, WatchFsnotify, WatchPoll} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown watch mode %q (expected auto, fsnotify or poll)", name)
 }
 
+// SymlinkFollowMode determines how a symlinked config file is followed
+type SymlinkFollowMode int
+
+const (
+	// WatchGrandparent also watches the grandparent directory and reacts to
+	// ConfigMap ..data updates (default)
+	WatchGrandparent SymlinkFollowMode = iota
+	// PollTarget only re-resolves the symlink and reports a change when its
+	// target path changes. Suits setups that atomically repoint the symlink,
+	// without reloads for unrelated files in a busy directory
+	PollTarget
+)
+
+func (m SymlinkFollowMode) String() string {
+	switch m {
+	case WatchGrandparent:
+		return "grandparent"
+	case PollTarget:
+		return "poll-target"
+	default:
+		return fmt.Sprintf("SymlinkFollowMode(%d)", int(m))
+	}
+}
+
+// ParseSymlinkFollowMode parses a mode name as returned by String
+func ParseSymlinkFollowMode(name string) (SymlinkFollowMode, error) {
+	for _, m := range []SymlinkFollowMode{WatchGrandparent, PollTarget} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown symlink follow mode %q (expected grandparent or poll-target)", name)
+}
+
+// selfTestTimeout is how long the self-test waits for its event
+var selfTestTimeout = 2 * time.Second
+
+// summaryInterval is how often the number of changes detected by each source
+// is logged
+var summaryInterval = 10 * time.Minute
+
+// directFileWatch adds a watch on the file itself in addition to its directory.
+// kqueue (macOS) does not reliably report changes to files inside a watched
+// directory, so there the file has to be watched directly
+var directFileWatch = runtime.GOOS == "darwin"
+
 // noopWatcher is a no-op implementation of FileWatcher
 type noopWatcher struct{}
 
@@ -38,7 +288,7 @@ func (nw *noopWatcher) Start(ctx context.Context) error {
 	return nil
 }
 
-func (nw *noopWatcher) Changes() <-chan struct{} {
+func (nw *noopWatcher) Changes() <-chan ChangeEvent {
 	return nil
 }
 
@@ -46,9 +296,18 @@ func (nw *noopWatcher) Close() error {
 	return nil
 }
 
+func (nw *noopWatcher) ActiveWatches() []WatchInfo {
+	return nil
+}
+
 // NewFileWatcher creates a new file watcher
 // If the file doesn't exist, it returns a no-op watcher
 func NewFileWatcher(filePath string) (FileWatcher, error) {
+	return NewFileWatcherWithOptions(filePath, Options{})
+}
+
+// NewFileWatcherWithOptions creates a new file watcher with the given options
+func NewFileWatcherWithOptions(filePath string, opts Options) (FileWatcher, error) {
 	if filePath == "" {
 		logger.Debug("No config file path specified, using no-op watcher")
 		return &noopWatcher{}, nil
@@ -61,7 +320,7 @@ func NewFileWatcher(filePath string) (FileWatcher, error) {
 		return &noopWatcher{}, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat file %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to stat file %s: %w", filePath, classify(err))
 	}
 
 	// Check if file is a symlink (common in Kubernetes ConfigMap mounts)
@@ -71,89 +330,221 @@ func NewFileWatcher(filePath string) (FileWatcher, error) {
 	if isSymlink {
 		realPath, err = filepath.EvalSymlinks(filePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to resolve symlink %s: %w", filePath, err)
+			return nil, fmt.Errorf("failed to resolve symlink %s: %w", filePath, classify(err))
 		}
 		logger.Info("Config file %s is a symlink pointing to %s", filePath, realPath)
 	} else {
 		logger.Info("Config file %s is a regular file", filePath)
 	}
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", err)
+	if target, err := os.Stat(realPath); err == nil && !target.Mode().IsRegular() {
+		return nil, fmt.Errorf("cannot watch %s: %w (%v)", realPath, ErrNotRegularFile, target.Mode().Type())
 	}
 
-	// Watch the parent directory to catch symlink updates
-	dir := filepath.Dir(filePath)
-	if err := watcher.Add(dir); err != nil {
-		watcher.Close()
-		return nil, fmt.Errorf("failed to watch directory %s: %w", dir, err)
+	strategies := opts.strategies()
+	var watcher *fsnotify.Watcher
+	if !slices.Contains(strategies, StrategyFsnotify) {
+		logger.Info("Not using fsnotify for %s, watch strategies are %v", filePath, strategies)
+	} else {
+		watcher, err = newFsnotifyWatcher(filePath, realPath, isSymlink, opts)
+		if err != nil {
+			return nil, err
+		}
 	}
-	logger.Info("Watching directory: %s", dir)
 
-	// Also watch the parent of the real path for ConfigMap scenarios
-	if isSymlink {
-		realDir := filepath.Dir(realPath)
-		// In Kubernetes ConfigMaps, watch the grandparent directory which contains ..data
-		configDir := filepath.Dir(dir)
-		if configDir != realDir {
-			if err := watcher.Add(configDir); err != nil {
-				logger.Error("Failed to watch config directory %s: %v", configDir, err)
-			} else {
-				logger.Info("Watching config directory for ConfigMap updates: %s", configDir)
-			}
-		}
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
 	}
 
 	fw := &fileWatcher{
 		filePath:     filePath,
 		watcher:      watcher,
-		changeChan:   make(chan struct{}, 1),
+		changeChan:   make(chan ChangeEvent, 1),
 		debounce:     500 * time.Millisecond,
-		pollInterval: 5 * time.Second, // Poll every 5 seconds as fallback
+		pollInterval: pollInterval,
 		isSymlink:    isSymlink,
 		realPath:     realPath,
+		watchFile:    directFileWatch && watcher != nil,
+		opts:         opts,
+		pollOnly:     watcher == nil,
+		strategies:   strategies,
+		stat:         os.Stat,
+	}
+
+	if fw.watchFile {
+		fw.addFileWatch()
+	}
+
+	if opts.HoldOpen {
+		fw.reopenHeld()
 	}
 
 	// Get initial modification time and inode
 	if stat, err := os.Stat(filePath); err == nil {
 		fw.lastModTime = stat.ModTime()
+		fw.lastSize = stat.Size()
+		fw.lastMeta = metadataOf(stat)
 		if sysStat, ok := stat.Sys().(*syscall.Stat_t); ok {
 			fw.lastInode = sysStat.Ino
 			logger.Debug("Initial file state: mtime=%v, inode=%d", fw.lastModTime, fw.lastInode)
 		}
 	}
+	fw.refreshHash()
 
 	return fw, nil
 }
 
+// newFsnotifyWatcher sets up the fsnotify watches for filePath
+func newFsnotifyWatcher(filePath, realPath string, isSymlink bool, opts Options) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", classifyWatch(err))
+	}
+
+	// Watch the parent directory to catch symlink updates
+	dir := filepath.Dir(filePath)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch directory %s: %w", dir, classifyWatch(err))
+	}
+	logger.Info("Watching directory: %s", dir)
+
+	// Also watch the parent of the real path for ConfigMap scenarios
+	if isSymlink && opts.SymlinkFollowMode == WatchGrandparent {
+		realDir := filepath.Dir(realPath)
+		// In Kubernetes ConfigMaps, watch the grandparent directory which contains ..data
+		configDir := filepath.Dir(dir)
+		if configDir != realDir {
+			if err := watcher.Add(configDir); err != nil {
+				logger.Error("Failed to watch config directory %s: %v", configDir, err)
+			} else {
+				logger.Info("Watching config directory for ConfigMap updates: %s", configDir)
+			}
+		}
+	}
+
+	return watcher, nil
+}
+
 // Start starts watching for file changes
 func (fw *fileWatcher) Start(ctx context.Context) error {
 	logger.Info("Starting file watcher for %s", fw.filePath)
 
+	if fw.opts.SelfTest && !fw.pollOnly && !fw.selfTest() {
+		fsType := FilesystemType(filepath.Dir(fw.filePath))
+		if !fw.polls() {
+			logger.Warn("Fsnotify self-test failed: no event within %v on a %s filesystem; no polling strategy is enabled, so changes may go unnoticed",
+				selfTestTimeout, fsType)
+		} else {
+			logger.Info("Fsnotify self-test failed: no event within %v, the %s filesystem does not seem to deliver file events; falling back to polling every %v",
+				selfTestTimeout, fsType, fw.pollInterval)
+			fw.pollOnly = true
+		}
+	}
+
 	// Start fsnotify watcher
-	go fw.watch(ctx)
+	if !fw.pollOnly {
+		go fw.watch(ctx)
+	}
 
 	// Start polling as a fallback (important for ConfigMaps)
-	go fw.poll(ctx)
+	if fw.polls() {
+		go fw.poll(ctx)
+	}
+
+	go fw.summarize(ctx)
 
 	return nil
 }
 
 // Changes returns a channel that receives notifications when the file changes
-func (fw *fileWatcher) Changes() <-chan struct{} {
+func (fw *fileWatcher) Changes() <-chan ChangeEvent {
 	return fw.changeChan
 }
 
 // Close closes the file watcher
 func (fw *fileWatcher) Close() error {
 	logger.Debug("Closing file watcher")
+	fw.mu.Lock()
+	if fw.held != nil {
+		fw.held.Close()
+		fw.held = nil
+	}
+	fw.mu.Unlock()
 	if fw.watcher != nil {
 		return fw.watcher.Close()
 	}
 	return nil
 }
 
+// selfTest touches a temp file in the watched directory and reports whether
+// fsnotify delivered an event for it. Some restricted container runtimes accept
+// watches but never deliver events. Events for the config file itself that
+// arrive meanwhile are dropped; polling still catches those changes
+func (fw *fileWatcher) selfTest() bool {
+	dir := filepath.Dir(fw.filePath)
+	probe, err := os.CreateTemp(dir, ".flush-manager-selftest-*")
+	if err != nil {
+		// Read-only mounts (e.g. ConfigMaps) can't be tested; trust fsnotify
+		logger.Warn("Cannot run fsnotify self-test in %s, keeping fsnotify: %v", dir, err)
+		return true
+	}
+	name := probe.Name()
+	probe.Close()
+	defer os.Remove(name)
+
+	timeout := time.After(selfTestTimeout)
+	for {
+		select {
+		case event, ok := <-fw.watcher.Events:
+			if !ok {
+				return false
+			}
+			if event.Name == name {
+				logger.Debug("Fsnotify self-test passed")
+				return true
+			}
+
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return false
+			}
+			logger.Debug("Fsnotify error during self-test: %v", err)
+
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// addFileWatch watches the file itself, resolving symlinks so the watch is
+// placed on the file that actually changes
+func (fw *fileWatcher) addFileWatch() {
+	if realPath, err := filepath.EvalSymlinks(fw.filePath); err == nil {
+		fw.realPath = realPath
+	}
+
+	if err := fw.watcher.Add(fw.realPath); err != nil {
+		logger.Debug("Failed to watch file %s directly: %v", fw.realPath, err)
+		return
+	}
+	logger.Debug("Watching file directly: %s", fw.realPath)
+}
+
+// rewatchFile replaces the direct file watch after the file was renamed,
+// removed or recreated. An atomic write (write temp file, rename over) leaves
+// kqueue watching the old, now unlinked file
+func (fw *fileWatcher) rewatchFile() {
+	_ = fw.watcher.Remove(fw.realPath)
+	fw.addFileWatch()
+}
+
+// event describes a change of the watched file detected by source
+func (fw *fileWatcher) event(source string) ChangeEvent {
+	return ChangeEvent{Path: fw.filePath, Source: source, Time: time.Now()}
+}
+
 // poll checks for file changes periodically (fallback for ConfigMap scenarios)
 func (fw *fileWatcher) poll(ctx context.Context) {
 	ticker := time.NewTicker(fw.pollInterval)
@@ -167,26 +558,260 @@ func (fw *fileWatcher) poll(ctx context.Context) {
 			logger.Debug("Polling stopped due to context cancellation")
 			return
 		case <-ticker.C:
-			if fw.checkFileChanged() {
-				logger.Info("File change detected via polling")
-				select {
-				case fw.changeChan <- struct{}{}:
-					logger.Debug("Change notification sent via polling")
-				default:
-					logger.Debug("Change notification already pending")
-				}
+			if source, ok := fw.pollChanged(); ok {
+				logger.Info("File change detected via %s", source)
+				fw.recordChange(source)
+				fw.notify(source)
 			}
 		}
 	}
 }
 
-// checkFileChanged checks if the file has been modified
-func (fw *fileWatcher) checkFileChanged() bool {
-	stat, err := os.Stat(fw.filePath)
+// pollChanged runs the polling strategies in turn and returns the source of
+// the first one that detects a change
+func (fw *fileWatcher) pollChanged() (string, bool) {
+	if fw.uses(StrategyPollStat) && fw.changed() {
+		return sourcePoll, true
+	}
+	if fw.uses(StrategyPollHash) && fw.hashChanged() {
+		return sourcePollHash, true
+	}
+	return "", false
+}
+
+// notify reports a change detected by source once no further change was
+// detected for the debounce period. All strategies share the one timer, so
+// a change seen by several of them is reported once
+func (fw *fileWatcher) notify(source string) {
+	fw.mu.Lock()
+	metaOnly := fw.lastMetaOnly
+	fw.mu.Unlock()
+
+	fw.debounceMu.Lock()
+	defer fw.debounceMu.Unlock()
+
+	// Debounce: reset timer if already running
+	if fw.debounceTimer != nil && fw.debounceTimer.Stop() {
+		// Metadata only if the change already pending was, too
+		metaOnly = metaOnly && fw.pendingMeta
+	}
+	fw.pendingMeta = metaOnly
+
+	fw.debounceTimer = time.AfterFunc(fw.debounce, func() {
+		if (fw.opts.Settle || fw.opts.StabilityWindow > 0) && !fw.settle() {
+			return
+		}
+		logger.Info("File change confirmed after debounce period")
+		event := fw.event(source)
+		if metaOnly {
+			event.Reason = ReasonMetadata
+		}
+		select {
+		case fw.changeChan <- event:
+			logger.Debug("Change notification sent via %s", source)
+		default:
+			logger.Debug("Change notification already pending")
+		}
+	})
+}
+
+// recordChange counts a change detected by source
+func (fw *fileWatcher) recordChange(source string) {
+	switch source {
+	case sourcePoll:
+		pollChangesTotal.Inc()
+		fw.pollCount.Add(1)
+	case sourcePollHash:
+		hashChangesTotal.Inc()
+		fw.hashCount.Add(1)
+	default:
+		fsnotifyChangesTotal.Inc()
+		fw.fsnotifyCount.Add(1)
+	}
+}
+
+// summarize periodically logs how many changes each source detected. Changes
+// caught only by polling mean fsnotify is missing events in this environment
+func (fw *fileWatcher) summarize(ctx context.Context) {
+	ticker := time.NewTicker(summaryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fw.logSummary()
+		}
+	}
+}
+
+// logSummary logs and resets the per-source change counts
+func (fw *fileWatcher) logSummary() {
+	fsnotifyCount := fw.fsnotifyCount.Swap(0)
+	pollCount := fw.pollCount.Swap(0)
+	hashCount := fw.hashCount.Swap(0)
+	if fsnotifyCount == 0 && pollCount == 0 && hashCount == 0 {
+		return
+	}
+
+	logger.Info("Config changes detected in the last %v: %d via fsnotify, %d via polling, %d via content hashing",
+		summaryInterval, fsnotifyCount, pollCount, hashCount)
+	if pollCount > 0 && !fw.pollOnly {
+		logger.Warn("Polling caught %d changes that fsnotify missed; file events may be unreliable here", pollCount)
+	}
+}
+
+// followTarget reports whether changes are detected by the symlink target
+// path alone (PollTarget)
+func (fw *fileWatcher) followTarget() bool {
+	return fw.isSymlink && fw.opts.SymlinkFollowMode == PollTarget
+}
+
+// changed checks whether the watched file changed, according to the mode
+func (fw *fileWatcher) changed() bool {
+	// Stat before taking the lock: retrying a missing file must not hold up
+	// the other strategies
+	stat, err := fw.statRetrying()
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	var changed bool
+	switch {
+	case fw.followTarget():
+		changed = fw.checkTargetChanged()
+		fw.lastKind = ChangeReplace
+		fw.lastMetaOnly = false
+	case fw.opts.HoldOpen && fw.checkHeldReplaced():
+		changed = true
+		fw.lastMetaOnly = false
+	default:
+		changed = fw.checkFileChanged(stat, err)
+	}
+
+	if changed {
+		fw.refreshHash()
+	}
+	return fw.triggered(changed)
+}
+
+// triggered filters a detected content change by the configured trigger,
+// and by the size limit with RejectOversized
+func (fw *fileWatcher) triggered(changed bool) bool {
+	if changed && fw.opts.RejectOversized && fw.oversized() {
+		logger.Warn("Ignoring change of %s, it exceeds the size limit of %d bytes", fw.filePath, fw.opts.MaxConfigSize)
+		return false
+	}
+	if changed && !fw.lastMetaOnly && !fw.opts.Trigger.matches(fw.lastKind) {
+		logger.Info("Ignoring %s of %s, trigger is %s", fw.lastKind, fw.filePath, fw.opts.Trigger)
+		return false
+	}
+	return changed
+}
+
+// checkHeldReplaced reports whether the file at the path is no longer the
+// held file, and then holds the new one instead
+func (fw *fileWatcher) checkHeldReplaced() bool {
+	current, err := os.Stat(fw.filePath)
+	if err != nil {
+		// Gone for now; checkFileChanged reports the error
+		return false
+	}
+
+	if fw.held == nil {
+		// Not held yet (the open failed before); the usual checks apply
+		fw.reopenHeld()
+		return false
+	}
+	if held, err := fw.held.Stat(); err == nil && os.SameFile(held, current) {
+		return false
+	}
+
+	logger.Info("File %s was replaced, reopening it", fw.filePath)
+	fw.reopenHeld()
+	fw.lastModTime = current.ModTime()
+	fw.lastSize = current.Size()
+	fw.lastKind = ChangeReplace
+	fw.lastMeta = metadataOf(current)
+	if sysStat, ok := current.Sys().(*syscall.Stat_t); ok {
+		fw.lastInode = sysStat.Ino
+	}
+	return true
+}
+
+// reopenHeld closes the held file, if any, and opens the one at the path
+func (fw *fileWatcher) reopenHeld() {
+	if fw.held != nil {
+		fw.held.Close()
+		fw.held = nil
+	}
+
+	f, err := os.Open(fw.filePath)
+	if err != nil {
+		logger.Error("Failed to open %s to hold it: %v", fw.filePath, err)
+		return
+	}
+	fw.held = f
+}
+
+// configMapMarker reports whether name is the ..data symlink, or the
+// ..data_tmp one renamed over it, that a ConfigMap update swaps next to the
+// symlinked config file. An entry named data only counts with DataMarker,
+// so a config legitimately named data does not trigger spurious checks
+func (fw *fileWatcher) configMapMarker(name string) bool {
+	if !fw.isSymlink || filepath.Dir(name) != filepath.Dir(fw.filePath) {
+		return false
+	}
+	switch filepath.Base(name) {
+	case "..data", "..data_tmp":
+		return true
+	case "data":
+		return fw.opts.DataMarker
+	}
+	return false
+}
+
+// checkTargetChanged re-resolves the symlink and reports whether it now
+// points at a different file
+func (fw *fileWatcher) checkTargetChanged() bool {
+	target, err := filepath.EvalSymlinks(fw.filePath)
+	if err != nil {
+		logger.Error("Failed to resolve symlink %s: %v", fw.filePath, err)
+		return false
+	}
+	if target == fw.realPath {
+		return false
+	}
+
+	logger.Info("Symlink target changed: %s -> %s", fw.realPath, target)
+	if fw.watchFile {
+		fw.rewatchFile()
+	} else {
+		fw.realPath = target
+	}
+	return true
+}
+
+// checkFileChanged checks if the file has been modified, given the result of
+// statRetrying, which is taken before fw.mu
+func (fw *fileWatcher) checkFileChanged(stat os.FileInfo, err error) bool {
+	if errors.Is(err, os.ErrNotExist) {
+		// Still missing after the retries, so not just mid-rename
+		if !fw.missing {
+			fw.missing = true
+			logger.Warn("File %s was removed, waiting for it to reappear", fw.filePath)
+		}
+		return false
+	}
 	if err != nil {
 		logger.Error("Failed to stat file %s: %v", fw.filePath, err)
 		return false
 	}
+	if fw.missing {
+		fw.missing = false
+		logger.Info("File %s reappeared", fw.filePath)
+	}
 
 	modTime := stat.ModTime()
 	var inode uint64
@@ -197,27 +822,71 @@ func (fw *fileWatcher) checkFileChanged() bool {
 	// Check if either modification time or inode changed
 	// Inode change indicates symlink was updated (ConfigMap scenario)
 	if modTime.After(fw.lastModTime) || (inode != 0 && inode != fw.lastInode) {
-		logger.Info("File change detected: old_mtime=%v, new_mtime=%v, old_inode=%d, new_inode=%d",
-			fw.lastModTime, modTime, fw.lastInode, inode)
+		// The same file grown is an append; anything else replaced the content
+		fw.lastKind = ChangeReplace
+		if inode == fw.lastInode && stat.Size() > fw.lastSize {
+			fw.lastKind = ChangeAppend
+		}
+		logger.Info("File change detected (%s): old_mtime=%v, new_mtime=%v, old_inode=%d, new_inode=%d, old_size=%d, new_size=%d",
+			fw.lastKind, fw.lastModTime, modTime, fw.lastInode, inode, fw.lastSize, stat.Size())
 		fw.lastModTime = modTime
 		fw.lastInode = inode
+		fw.lastSize = stat.Size()
+		fw.lastMeta = metadataOf(stat)
+		fw.lastMetaOnly = false
 		return true
 	}
 
+	// chmod and chown leave the modification time alone
+	if fw.opts.WatchMetadata {
+		if meta := metadataOf(stat); meta != fw.lastMeta {
+			logger.Info("File metadata change detected: old=%v, new=%v", fw.lastMeta, meta)
+			fw.lastMeta = meta
+			fw.lastMetaOnly = true
+			return true
+		}
+	}
+
 	return false
 }
 
 // watch processes file system events
 func (fw *fileWatcher) watch(ctx context.Context) {
-	var debounceTimer *time.Timer
+	var throttleTimer *time.Timer
+	var throttled <-chan time.Time // fires when a coalesced check is due
+	var lastCheck time.Time
+	coalesced := 0
 	logger.Debug("Started fsnotify event loop")
 
+	defer func() {
+		if throttleTimer != nil {
+			throttleTimer.Stop()
+		}
+	}()
+
+	// check verifies the file actually changed and (re)starts the debounce
+	check := func() {
+		lastCheck = time.Now()
+		if !fw.changed() {
+			logger.Debug("File state unchanged, ignoring event")
+			return
+		}
+		fw.recordChange(sourceFsnotify)
+		fw.notify(sourceFsnotify)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Debug("Fsnotify watcher stopped due to context cancellation")
 			return
 
+		case <-throttled:
+			throttled = nil
+			logger.Debug("Checking file after coalescing %d events", coalesced)
+			coalesced = 0
+			check()
+
 		case event, ok := <-fw.watcher.Events:
 			if !ok {
 				logger.Debug("Fsnotify events channel closed")
@@ -229,16 +898,29 @@ func (fw *fileWatcher) watch(ctx context.Context) {
 			// For symlinks (ConfigMap scenario), watch for changes to the symlink itself or ..data
 			shouldCheck := false
 
-			if event.Name == fw.filePath {
+			if event.Name == fw.filePath || (fw.watchFile && event.Name == fw.realPath) {
 				// Direct file event
 				shouldCheck = true
-				logger.Debug("Event on target file: %s", fw.filePath)
-			} else if fw.isSymlink {
-				// Check for ..data or data directory changes (ConfigMap update pattern)
-				eventBase := filepath.Base(event.Name)
-				if eventBase == "..data" || eventBase == "..data_tmp" || eventBase == "data" {
+				logger.Debug("Event on target file: %s", event.Name)
+
+				// In PollTarget mode checkTargetChanged moves the watch itself
+				if fw.watchFile && !fw.followTarget() && event.Op&(fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+					fw.mu.Lock()
+					fw.rewatchFile()
+					fw.mu.Unlock()
+				}
+			} else if fw.isSymlink && !fw.followTarget() {
+				// Check for ..data directory changes (ConfigMap update pattern)
+				if fw.configMapMarker(event.Name) {
 					shouldCheck = true
 					logger.Debug("Event on ConfigMap metadata: %s", event.Name)
+
+					// The symlink now points at a new file; move the direct watch to it
+					if fw.watchFile {
+						fw.mu.Lock()
+						fw.rewatchFile()
+						fw.mu.Unlock()
+					}
 				}
 			}
 
@@ -246,33 +928,31 @@ func (fw *fileWatcher) watch(ctx context.Context) {
 				continue
 			}
 
-			// Check for write, create, or remove events
+			// Check for write, create, rename, or remove events, and chmod
+			// (also sent for chown) with WatchMetadata
+			// (kqueue reports an atomic replace as a rename of the watched file)
 			if event.Op&fsnotify.Write == fsnotify.Write ||
 				event.Op&fsnotify.Create == fsnotify.Create ||
-				event.Op&fsnotify.Remove == fsnotify.Remove {
+				event.Op&fsnotify.Rename == fsnotify.Rename ||
+				event.Op&fsnotify.Remove == fsnotify.Remove ||
+				(fw.opts.WatchMetadata && event.Op&fsnotify.Chmod == fsnotify.Chmod) {
 
-				logger.Debug("Detected relevant file event: %s", event.Op)
-
-				// Verify file actually changed
-				if !fw.checkFileChanged() {
-					logger.Debug("File state unchanged, ignoring event")
+				// A check is already due; it covers this event too
+				if throttled != nil {
+					coalesced++
 					continue
 				}
 
-				// Debounce: reset timer if already running
-				if debounceTimer != nil {
-					debounceTimer.Stop()
-				}
+				logger.Debug("Detected relevant file event: %s", event.Op)
 
-				debounceTimer = time.AfterFunc(fw.debounce, func() {
-					logger.Info("File change confirmed after debounce period")
-					select {
-					case fw.changeChan <- struct{}{}:
-						logger.Debug("Change notification sent via fsnotify")
-					default:
-						logger.Debug("Change notification already pending")
-					}
-				})
+				// Checked very recently: coalesce the burst into one later check
+				if wait := checkThrottle - time.Since(lastCheck); wait > 0 {
+					coalesced = 1
+					throttleTimer = time.NewTimer(wait)
+					throttled = throttleTimer.C
+					continue
+				}
+				check()
 			}
 
 		case err, ok := <-fw.watcher.Errors:
@@ -2,11 +2,14 @@ package watcher
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -76,8 +79,11 @@ func TestFileWatcher_Changes(t *testing.T) {
 
 		// Wait for change notification with timeout
 		select {
-		case <-fw.Changes():
+		case event := <-fw.Changes():
 			// Success - change detected
+			assert.Equal(t, filePath, event.Path)
+			assert.Contains(t, []string{sourceFsnotify, sourcePoll}, event.Source)
+			assert.WithinDuration(t, time.Now(), event.Time, 2*time.Second)
 		case <-time.After(2 * time.Second):
 			t.Fatal("timeout waiting for file change notification")
 		}
@@ -249,6 +255,95 @@ func TestFileWatcher_FileRecreation(t *testing.T) {
 	})
 }
 
+// TestFileWatcher_DirectFileWatch exercises the macOS (kqueue) watch setup,
+// which also watches the file itself and must survive atomic replaces
+func TestFileWatcher_DirectFileWatch(t *testing.T) {
+	saved := directFileWatch
+	directFileWatch = true
+	defer func() { directFileWatch = saved }()
+
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "test.conf")
+	require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+	fw, err := NewFileWatcher(filePath)
+	require.NoError(t, err)
+	defer fw.Close()
+	assert.True(t, fw.(*fileWatcher).watchFile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, fw.Start(ctx))
+	time.Sleep(100 * time.Millisecond)
+
+	// Write a temp file and rename it over the config, twice: the second
+	// replace is only seen if the watch moved to the new file
+	for _, content := range []string{"first", "second"} {
+		tmpFile := filepath.Join(tmpDir, "test.conf.tmp")
+		require.NoError(t, os.WriteFile(tmpFile, []byte(content), 0644))
+		require.NoError(t, os.Rename(tmpFile, filePath))
+
+		select {
+		case <-fw.Changes():
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for atomic replace %q", content)
+		}
+	}
+}
+
+func TestFileWatcher_SelfTest(t *testing.T) {
+	newWatcher := func(t *testing.T) (*fileWatcher, string) {
+		t.Helper()
+		tmpDir := t.TempDir()
+		filePath := filepath.Join(tmpDir, "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+		fw, err := NewFileWatcherWithOptions(filePath, Options{SelfTest: true})
+		require.NoError(t, err)
+		t.Cleanup(func() { fw.Close() })
+		return fw.(*fileWatcher), tmpDir
+	}
+
+	t.Run("keeps fsnotify when events are delivered", func(t *testing.T) {
+		fw, tmpDir := newWatcher(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, fw.Start(ctx))
+		assert.False(t, fw.pollOnly)
+
+		// The temp file is cleaned up
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("falls back to polling when no events arrive", func(t *testing.T) {
+		saved := selfTestTimeout
+		selfTestTimeout = 200 * time.Millisecond
+		defer func() { selfTestTimeout = saved }()
+
+		fw, tmpDir := newWatcher(t)
+		// Simulate a runtime that accepts watches but never delivers events
+		require.NoError(t, fw.watcher.Remove(tmpDir))
+		fw.pollInterval = 100 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, fw.Start(ctx))
+		assert.True(t, fw.pollOnly)
+
+		// Changes are still detected by polling
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, os.WriteFile(fw.filePath, []byte("modified"), 0644))
+		select {
+		case <-fw.Changes():
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for change in poll-only mode")
+		}
+	})
+}
+
 // TestFileWatcher_ModTimeCheck tests that the watcher properly checks modification time
 func TestFileWatcher_ModTimeCheck(t *testing.T) {
 	t.Run("ignore events without modtime change", func(t *testing.T) {
@@ -319,3 +414,710 @@ func BenchmarkFileWatcher_Changes(b *testing.B) {
 		<-fw.Changes()
 	}
 }
+
+// floodWatcher starts an fsnotify-only watcher whose directory watch is
+// removed, so only events injected into its Events channel reach it
+func floodWatcher(tb testing.TB) (*fileWatcher, string) {
+	tb.Helper()
+	filePath := filepath.Join(tb.TempDir(), "test.conf")
+	require.NoError(tb, os.WriteFile(filePath, []byte("initial"), 0644))
+
+	w, err := NewFileWatcherWithOptions(filePath, Options{Mode: WatchFsnotify})
+	require.NoError(tb, err)
+	tb.Cleanup(func() { w.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.Cleanup(cancel)
+	require.NoError(tb, w.Start(ctx))
+	fw := w.(*fileWatcher)
+	require.NoError(tb, fw.watcher.Remove(filepath.Dir(filePath)))
+	return fw, filePath
+}
+
+func TestFileWatcher_EventFlood(t *testing.T) {
+	fw, filePath := floodWatcher(t)
+	write := fsnotify.Event{Name: filePath, Op: fsnotify.Write}
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filePath, []byte("changed"), 0644))
+	for i := 0; i < 5000; i++ {
+		fw.watcher.Events <- write
+	}
+	// A change in the middle of the burst is found by the coalesced check
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filePath, []byte("changed again"), 0644))
+	for i := 0; i < 5000; i++ {
+		fw.watcher.Events <- write
+	}
+
+	select {
+	case <-fw.Changes():
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for change notification")
+	}
+	assert.Equal(t, uint64(2), fw.fsnotifyCount.Load())
+
+	select {
+	case <-fw.Changes():
+		t.Fatal("burst reported more than once")
+	case <-time.After(time.Second):
+	}
+}
+
+// BenchmarkFileWatcher_EventFlood benchmarks the event loop under a runaway
+// writer, with every event on the watched file
+func BenchmarkFileWatcher_EventFlood(b *testing.B) {
+	fw, filePath := floodWatcher(b)
+	write := fsnotify.Event{Name: filePath, Op: fsnotify.Write}
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(b, os.WriteFile(filePath, []byte("changed"), 0644))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fw.watcher.Events <- write
+	}
+	b.StopTimer()
+
+	// The change is still reported once the flood stops
+	<-fw.Changes()
+}
+
+func TestFileWatcher_PollTarget(t *testing.T) {
+	root := t.TempDir()
+	confDir := filepath.Join(root, "conf")
+	targetDir := filepath.Join(root, "targets")
+	require.NoError(t, os.Mkdir(confDir, 0755))
+	require.NoError(t, os.Mkdir(targetDir, 0755))
+
+	v1 := filepath.Join(targetDir, "v1.conf")
+	v2 := filepath.Join(targetDir, "v2.conf")
+	require.NoError(t, os.WriteFile(v1, []byte("v1"), 0644))
+	require.NoError(t, os.WriteFile(v2, []byte("v2"), 0644))
+
+	filePath := filepath.Join(confDir, "app.conf")
+	require.NoError(t, os.Symlink(v1, filePath))
+
+	w, err := NewFileWatcherWithOptions(filePath, Options{SymlinkFollowMode: PollTarget})
+	require.NoError(t, err)
+	defer w.Close()
+	fw := w.(*fileWatcher)
+	fw.pollInterval = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, fw.Start(ctx))
+	time.Sleep(100 * time.Millisecond)
+
+	// Activity that doesn't repoint the symlink is ignored
+	require.NoError(t, os.WriteFile(filepath.Join(root, "unrelated"), []byte("x"), 0644))
+	require.NoError(t, os.WriteFile(v1, []byte("v1 edited"), 0644))
+	select {
+	case <-fw.Changes():
+		t.Fatal("unexpected change notification")
+	case <-time.After(700 * time.Millisecond):
+	}
+
+	// Atomically repoint the symlink
+	tmpLink := filepath.Join(confDir, "app.conf.tmp")
+	require.NoError(t, os.Symlink(v2, tmpLink))
+	require.NoError(t, os.Rename(tmpLink, filePath))
+
+	select {
+	case <-fw.Changes():
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for symlink repoint notification")
+	}
+	assert.Equal(t, v2, fw.realPath)
+}
+
+func TestParseSymlinkFollowMode(t *testing.T) {
+	for _, mode := range []SymlinkFollowMode{WatchGrandparent, PollTarget} {
+		parsed, err := ParseSymlinkFollowMode(mode.String())
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+
+	_, err := ParseSymlinkFollowMode("inotify")
+	assert.Error(t, err)
+}
+
+func TestFileWatcher_DetectionSource(t *testing.T) {
+	start := func(t *testing.T, pollInterval time.Duration) (*fileWatcher, string) {
+		t.Helper()
+		tmpDir := t.TempDir()
+		filePath := filepath.Join(tmpDir, "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+		w, err := NewFileWatcher(filePath)
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+		fw := w.(*fileWatcher)
+		fw.pollInterval = pollInterval
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		require.NoError(t, fw.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+		return fw, filePath
+	}
+
+	waitChange := func(t *testing.T, fw *fileWatcher) {
+		t.Helper()
+		select {
+		case <-fw.Changes():
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for change notification")
+		}
+	}
+
+	t.Run("fsnotify", func(t *testing.T) {
+		fw, filePath := start(t, time.Hour)
+		before := fsnotifyChangesTotal.Value()
+
+		// Replace atomically: truncating and writing in place may be seen as
+		// two changes when they fall into different timestamp ticks
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("changed"), 0644))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+		waitChange(t, fw)
+
+		assert.Equal(t, before+1, fsnotifyChangesTotal.Value())
+		assert.Equal(t, uint64(1), fw.fsnotifyCount.Load())
+		assert.Zero(t, fw.pollCount.Load())
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		fw, filePath := start(t, 100*time.Millisecond)
+		// Simulate fsnotify missing the change
+		require.NoError(t, fw.watcher.Remove(filepath.Dir(filePath)))
+		before := pollChangesTotal.Value()
+
+		// Replace atomically so a poll can't observe a half-written file
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("changed"), 0644))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+		waitChange(t, fw)
+
+		assert.Equal(t, before+1, pollChangesTotal.Value())
+		assert.Equal(t, uint64(1), fw.pollCount.Load())
+
+		fw.logSummary()
+		assert.Zero(t, fw.pollCount.Load())
+	})
+}
+
+func TestFileWatcher_WatchMode(t *testing.T) {
+	newWatcher := func(t *testing.T, mode WatchMode) (*fileWatcher, string) {
+		t.Helper()
+		filePath := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+		w, err := NewFileWatcherWithOptions(filePath, Options{Mode: mode, PollInterval: 100 * time.Millisecond})
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		require.NoError(t, w.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+		return w.(*fileWatcher), filePath
+	}
+
+	t.Run("poll skips fsnotify", func(t *testing.T) {
+		fw, filePath := newWatcher(t, WatchPoll)
+		assert.Nil(t, fw.watcher)
+		assert.True(t, fw.pollOnly)
+
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("changed"), 0644))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+
+		select {
+		case <-fw.Changes():
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for polled change")
+		}
+		assert.Equal(t, uint64(1), fw.pollCount.Load())
+	})
+
+	t.Run("fsnotify does not poll", func(t *testing.T) {
+		fw, filePath := newWatcher(t, WatchFsnotify)
+		// Without fsnotify events nothing notices the change
+		require.NoError(t, fw.watcher.Remove(filepath.Dir(filePath)))
+		require.NoError(t, os.WriteFile(filePath, []byte("changed"), 0644))
+
+		select {
+		case <-fw.Changes():
+			t.Fatal("unexpected change notification")
+		case <-time.After(500 * time.Millisecond):
+		}
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		for _, mode := range []WatchMode{WatchAuto, WatchFsnotify, WatchPoll} {
+			parsed, err := ParseWatchMode(mode.String())
+			require.NoError(t, err)
+			assert.Equal(t, mode, parsed)
+		}
+		_, err := ParseWatchMode("inotify")
+		assert.Error(t, err)
+	})
+}
+
+func TestFileWatcher_Strategies(t *testing.T) {
+	newWatcher := func(t *testing.T, strategies ...Strategy) (*fileWatcher, string) {
+		t.Helper()
+		filePath := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+		w, err := NewFileWatcherWithOptions(filePath, Options{Strategies: strategies, PollInterval: 50 * time.Millisecond})
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+		return w.(*fileWatcher), filePath
+	}
+
+	t.Run("hash catches rewrite keeping mtime and size", func(t *testing.T) {
+		fw, filePath := newWatcher(t, StrategyPollStat, StrategyPollHash)
+		assert.Nil(t, fw.watcher)
+
+		stat, err := os.Stat(filePath)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filePath, []byte("changed"), 0644))
+		require.NoError(t, os.Chtimes(filePath, stat.ModTime(), stat.ModTime()))
+
+		source, ok := fw.pollChanged()
+		assert.True(t, ok)
+		assert.Equal(t, sourcePollHash, source)
+
+		// Reported once, by neither strategy again
+		_, ok = fw.pollChanged()
+		assert.False(t, ok)
+	})
+
+	t.Run("stat change is not reported again by hash", func(t *testing.T) {
+		fw, filePath := newWatcher(t, StrategyPollStat, StrategyPollHash)
+
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("changed"), 0644))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+
+		source, ok := fw.pollChanged()
+		assert.True(t, ok)
+		assert.Equal(t, sourcePoll, source)
+		_, ok = fw.pollChanged()
+		assert.False(t, ok)
+	})
+
+	t.Run("notifications collapse into one", func(t *testing.T) {
+		fw, filePath := newWatcher(t, StrategyFsnotify, StrategyPollStat, StrategyPollHash)
+		require.NotNil(t, fw.watcher)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		require.NoError(t, fw.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("changed"), 0644))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+
+		select {
+		case <-fw.Changes():
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for change notification")
+		}
+		select {
+		case event := <-fw.Changes():
+			t.Fatalf("unexpected second notification via %s", event.Source)
+		case <-time.After(time.Second):
+		}
+		assert.Equal(t, uint64(1), fw.fsnotifyCount.Load()+fw.pollCount.Load()+fw.hashCount.Load())
+
+		// Detections by different strategies within the debounce period
+		fw.notify(sourceFsnotify)
+		fw.notify(sourcePollHash)
+		select {
+		case event := <-fw.Changes():
+			assert.Equal(t, sourcePollHash, event.Source)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for change notification")
+		}
+		select {
+		case <-fw.Changes():
+			t.Fatal("unexpected second notification")
+		case <-time.After(time.Second):
+		}
+	})
+
+	t.Run("mode defaults", func(t *testing.T) {
+		assert.Equal(t, []Strategy{StrategyFsnotify, StrategyPollStat}, Options{}.strategies())
+		assert.Equal(t, []Strategy{StrategyFsnotify}, Options{Mode: WatchFsnotify}.strategies())
+		assert.Equal(t, []Strategy{StrategyPollStat}, Options{Mode: WatchPoll}.strategies())
+		assert.Equal(t, []Strategy{StrategyPollHash}, Options{Mode: WatchFsnotify, Strategies: []Strategy{StrategyPollHash}}.strategies())
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		strategies, err := ParseStrategies("fsnotify, poll-stat,poll-hash")
+		require.NoError(t, err)
+		assert.Equal(t, []Strategy{StrategyFsnotify, StrategyPollStat, StrategyPollHash}, strategies)
+
+		_, err = ParseStrategies("fsnotify,poll")
+		assert.Error(t, err)
+		_, err = ParseStrategies("poll-hash,poll-hash")
+		assert.Error(t, err)
+	})
+}
+
+func TestFileWatcher_WatchMetadata(t *testing.T) {
+	newWatcher := func(t *testing.T, opts Options) (*fileWatcher, string) {
+		t.Helper()
+		filePath := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+		opts.Mode = WatchPoll
+		opts.PollInterval = 50 * time.Millisecond
+		w, err := NewFileWatcherWithOptions(filePath, opts)
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+		return w.(*fileWatcher), filePath
+	}
+
+	t.Run("permission change is reported", func(t *testing.T) {
+		fw, filePath := newWatcher(t, Options{WatchMetadata: true})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, fw.Start(ctx))
+
+		require.NoError(t, os.Chmod(filePath, 0600))
+		select {
+		case event := <-fw.Changes():
+			assert.Equal(t, ReasonMetadata, event.Reason)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for metadata change")
+		}
+
+		// A content change is not reported as metadata
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("changed"), 0600))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+		select {
+		case event := <-fw.Changes():
+			assert.Empty(t, event.Reason)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for content change")
+		}
+	})
+
+	t.Run("via fsnotify", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+		w, err := NewFileWatcherWithOptions(filePath, Options{Mode: WatchFsnotify, WatchMetadata: true})
+		require.NoError(t, err)
+		defer w.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, w.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+
+		require.NoError(t, os.Chmod(filePath, 0600))
+		select {
+		case event := <-w.Changes():
+			assert.Equal(t, ReasonMetadata, event.Reason)
+			assert.Equal(t, sourceFsnotify, event.Source)
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for metadata change")
+		}
+	})
+
+	t.Run("ignored by default", func(t *testing.T) {
+		fw, filePath := newWatcher(t, Options{})
+		require.NoError(t, os.Chmod(filePath, 0600))
+		assert.False(t, fw.changed())
+	})
+
+	t.Run("not filtered by trigger", func(t *testing.T) {
+		fw, filePath := newWatcher(t, Options{WatchMetadata: true, Trigger: TriggerAppend})
+		require.NoError(t, os.Chmod(filePath, 0600))
+		assert.True(t, fw.changed())
+		assert.False(t, fw.changed())
+	})
+}
+
+func TestFileWatcher_HoldOpen(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.conf")
+	require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+	mtime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filePath, mtime, mtime))
+
+	w, err := NewFileWatcherWithOptions(filePath, Options{
+		Mode:         WatchPoll,
+		PollInterval: 50 * time.Millisecond,
+		HoldOpen:     true,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+	fw := w.(*fileWatcher)
+	require.NotNil(t, fw.held)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, fw.Start(ctx))
+
+	openFiles := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		require.NoError(t, err)
+		return len(entries)
+	}
+	before := openFiles()
+
+	// Atomic replaces that keep the modification time are still detected
+	for i := 0; i < 10; i++ {
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("replaced"), 0644))
+		require.NoError(t, os.Chtimes(tmpPath, mtime, mtime))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+
+		select {
+		case <-fw.Changes():
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for replace %d", i)
+		}
+	}
+
+	// Only the current file is held
+	assert.LessOrEqual(t, openFiles(), before+1)
+}
+
+func TestFileWatcher_ChangeTrigger(t *testing.T) {
+	start := func(t *testing.T, trigger ChangeTrigger) (*fileWatcher, string) {
+		t.Helper()
+		filePath := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("line 1\n"), 0644))
+
+		w, err := NewFileWatcherWithOptions(filePath, Options{Trigger: trigger})
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		require.NoError(t, w.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+		return w.(*fileWatcher), filePath
+	}
+
+	appendLine := func(t *testing.T, filePath string) {
+		t.Helper()
+		f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		_, err = f.WriteString("appended\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+
+	replace := func(t *testing.T, filePath string) {
+		t.Helper()
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte("replaced\n"), 0644))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+	}
+
+	expect := func(t *testing.T, fw *fileWatcher, want bool) {
+		t.Helper()
+		select {
+		case <-fw.Changes():
+			assert.True(t, want, "unexpected change notification")
+		case <-time.After(time.Second):
+			assert.False(t, want, "timeout waiting for change notification")
+		}
+	}
+
+	t.Run("replace ignores appends", func(t *testing.T) {
+		fw, filePath := start(t, TriggerReplace)
+		appendLine(t, filePath)
+		expect(t, fw, false)
+		replace(t, filePath)
+		expect(t, fw, true)
+	})
+
+	t.Run("append ignores replacements", func(t *testing.T) {
+		fw, filePath := start(t, TriggerAppend)
+		replace(t, filePath)
+		expect(t, fw, false)
+		appendLine(t, filePath)
+		expect(t, fw, true)
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		for _, trigger := range []ChangeTrigger{TriggerAny, TriggerAppend, TriggerReplace} {
+			parsed, err := ParseChangeTrigger(trigger.String())
+			require.NoError(t, err)
+			assert.Equal(t, trigger, parsed)
+		}
+		_, err := ParseChangeTrigger("truncate")
+		assert.Error(t, err)
+	})
+}
+
+func TestFileWatcher_MaxConfigSize(t *testing.T) {
+	newWatcher := func(t *testing.T, opts Options) (*fileWatcher, string) {
+		t.Helper()
+		filePath := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+		opts.MaxConfigSize = 10
+		opts.PollInterval = 50 * time.Millisecond
+		w, err := NewFileWatcherWithOptions(filePath, opts)
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+		return w.(*fileWatcher), filePath
+	}
+	replace := func(t *testing.T, filePath, content string) {
+		t.Helper()
+		tmpPath := filePath + ".tmp"
+		require.NoError(t, os.WriteFile(tmpPath, []byte(content), 0644))
+		require.NoError(t, os.Rename(tmpPath, filePath))
+	}
+
+	t.Run("oversized file is compared by stat instead of hash", func(t *testing.T) {
+		fw, filePath := newWatcher(t, Options{Strategies: []Strategy{StrategyPollHash}})
+		before := oversizedConfigsTotal.Value()
+
+		replace(t, filePath, "a gigantic config")
+		_, ok := fw.pollChanged()
+		assert.True(t, ok)
+		assert.Equal(t, before+1, oversizedConfigsTotal.Value())
+
+		// Not hashed, so a rewrite keeping mtime and size goes unnoticed
+		stat, err := os.Stat(filePath)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filePath, []byte("a GIGANTIC config"), 0644))
+		require.NoError(t, os.Chtimes(filePath, stat.ModTime(), stat.ModTime()))
+		_, ok = fw.pollChanged()
+		assert.False(t, ok)
+		assert.Equal(t, before+1, oversizedConfigsTotal.Value(), "counted once while over the limit")
+	})
+
+	t.Run("oversized change rejected", func(t *testing.T) {
+		fw, filePath := newWatcher(t, Options{Strategies: []Strategy{StrategyPollStat}, RejectOversized: true})
+
+		replace(t, filePath, "a gigantic config")
+		_, ok := fw.pollChanged()
+		assert.False(t, ok)
+
+		replace(t, filePath, "fixed")
+		_, ok = fw.pollChanged()
+		assert.True(t, ok)
+	})
+}
+
+func TestFileWatcher_ActiveWatches(t *testing.T) {
+	t.Run("configmap mount", func(t *testing.T) {
+		// /etc/config/app.conf -> ..data/app.conf, ..data -> ..2024_01_01
+		root := t.TempDir()
+		mount := filepath.Join(root, "config")
+		require.NoError(t, os.MkdirAll(filepath.Join(mount, "..2024_01_01"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(mount, "..2024_01_01", "app.conf"), []byte("v1"), 0644))
+		require.NoError(t, os.Symlink("..2024_01_01", filepath.Join(mount, "..data")))
+		filePath := filepath.Join(mount, "app.conf")
+		require.NoError(t, os.Symlink(filepath.Join("..data", "app.conf"), filePath))
+
+		w, err := NewFileWatcher(filePath)
+		require.NoError(t, err)
+		defer w.Close()
+
+		watches := w.ActiveWatches()
+		assert.Contains(t, watches, WatchInfo{Path: mount, Dir: true, Source: "fsnotify"})
+		assert.Contains(t, watches, WatchInfo{Path: root, Dir: true, Source: "fsnotify"})
+
+		polled := watches[len(watches)-1]
+		assert.Equal(t, filePath, polled.Path)
+		assert.Equal(t, "poll-stat", polled.Source)
+		assert.Contains(t, polled.Fingerprint, "size=2 ")
+		assert.Equal(t, "fsnotify dir "+root, WatchInfo{Path: root, Dir: true, Source: "fsnotify"}.String())
+	})
+
+	t.Run("content hash", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "test.conf")
+		require.NoError(t, os.WriteFile(filePath, []byte("initial"), 0644))
+
+		w, err := NewFileWatcherWithOptions(filePath, Options{Strategies: []Strategy{StrategyPollHash}})
+		require.NoError(t, err)
+		defer w.Close()
+
+		sum := sha256.Sum256([]byte("initial"))
+		assert.Equal(t, []WatchInfo{{
+			Path:        filePath,
+			Source:      "poll-hash",
+			Fingerprint: "sha256=" + hex.EncodeToString(sum[:]),
+		}}, w.ActiveWatches())
+	})
+
+	t.Run("no-op watcher", func(t *testing.T) {
+		w, err := NewFileWatcher("")
+		require.NoError(t, err)
+		assert.Empty(t, w.ActiveWatches())
+	})
+}
+
+func TestFileWatcher_ConfigMapMarker(t *testing.T) {
+	// mount/app.conf -> <marker>/app.conf, <marker> -> v1
+	newMount := func(t *testing.T, marker string, opts Options) (*fileWatcher, string) {
+		t.Helper()
+		root := t.TempDir()
+		mount := filepath.Join(root, "config")
+		require.NoError(t, os.MkdirAll(filepath.Join(mount, "v1"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(mount, "v1", "app.conf"), []byte("v1"), 0644))
+		require.NoError(t, os.Symlink("v1", filepath.Join(mount, marker)))
+		filePath := filepath.Join(mount, "app.conf")
+		require.NoError(t, os.Symlink(filepath.Join(marker, "app.conf"), filePath))
+
+		opts.Mode = WatchFsnotify
+		w, err := NewFileWatcherWithOptions(filePath, opts)
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+		fw := w.(*fileWatcher)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		require.NoError(t, fw.Start(ctx))
+		time.Sleep(100 * time.Millisecond)
+
+		// Edit the real file, which is not watched itself, so only a
+		// marker event leads to the change being noticed
+		require.NoError(t, os.WriteFile(filepath.Join(mount, "v1", "app.conf"), []byte("v2 edited"), 0644))
+		return fw, root
+	}
+	noticed := func(fw *fileWatcher) bool {
+		select {
+		case <-fw.Changes():
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		marker   string
+		opts     Options
+		entry    func(root string) string
+		expected bool
+	}{
+		{"..data next to the file", "..data", Options{}, func(root string) string { return filepath.Join(root, "config", "..data_tmp") }, true},
+		{"..data in another directory", "..data", Options{}, func(root string) string { return filepath.Join(root, "..data") }, false},
+		{"data without DataMarker", "data", Options{}, func(root string) string { return filepath.Join(root, "config", "data") }, false},
+		{"data with DataMarker", "data", Options{DataMarker: true}, func(root string) string { return filepath.Join(root, "config", "data") }, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fw, root := newMount(t, tc.marker, tc.opts)
+			entry := tc.entry(root)
+			if _, err := os.Lstat(entry); err == nil {
+				require.NoError(t, os.Remove(entry))
+				require.NoError(t, os.Symlink("v1", entry))
+			} else {
+				require.NoError(t, os.WriteFile(entry, nil, 0644))
+			}
+			assert.Equal(t, tc.expected, noticed(fw))
+		})
+	}
+}
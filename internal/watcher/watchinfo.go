@@ -0,0 +1,93 @@
+package watcher
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// WatchInfo describes one path a watcher observes, for diagnosing a change
+// that was not detected
+type WatchInfo struct {
+	// Path is the watched path
+	Path string
+
+	// Dir reports whether Path is a directory, observed for changes to its
+	// entries, rather than a file
+	Dir bool
+
+	// Source is how the path is observed: "fsnotify", or the poll strategy
+	Source string
+
+	// Fingerprint is the state of a polled path as last observed, which the
+	// next poll compares against; empty for fsnotify watches
+	Fingerprint string
+}
+
+// String describes the watch on one line
+func (w WatchInfo) String() string {
+	kind := "file"
+	if w.Dir {
+		kind = "dir"
+	}
+	s := fmt.Sprintf("%s %s %s", w.Source, kind, w.Path)
+	if w.Fingerprint != "" {
+		s += " (" + w.Fingerprint + ")"
+	}
+	return s
+}
+
+// fsnotifyWatches lists the paths registered with watcher, sorted
+func fsnotifyWatches(watcher interface{ WatchList() []string }) []WatchInfo {
+	paths := watcher.WatchList()
+	slices.Sort(paths)
+
+	watches := make([]WatchInfo, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		watches = append(watches, WatchInfo{
+			Path:   path,
+			Dir:    err == nil && info.IsDir(),
+			Source: sourceFsnotify,
+		})
+	}
+	return watches
+}
+
+// ActiveWatches lists the paths registered with fsnotify, then the file as
+// polled by each poll strategy, with what the last poll saw
+func (fw *fileWatcher) ActiveWatches() []WatchInfo {
+	var watches []WatchInfo
+	if fw.watcher != nil && !fw.pollOnly {
+		watches = fsnotifyWatches(fw.watcher)
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	switch {
+	case fw.followTarget() && fw.polls():
+		watches = append(watches, WatchInfo{
+			Path:        fw.filePath,
+			Source:      PollTarget.String(),
+			Fingerprint: "target=" + fw.realPath,
+		})
+	case fw.uses(StrategyPollStat):
+		watches = append(watches, WatchInfo{
+			Path:   fw.filePath,
+			Source: StrategyPollStat.String(),
+			Fingerprint: fmt.Sprintf("mtime=%s size=%d inode=%d",
+				fw.lastModTime.Format(time.RFC3339Nano), fw.lastSize, fw.lastInode),
+		})
+	}
+	if fw.uses(StrategyPollHash) {
+		watches = append(watches, WatchInfo{
+			Path:        fw.filePath,
+			Source:      StrategyPollHash.String(),
+			Fingerprint: "sha256=" + hex.EncodeToString(fw.lastHash[:]),
+		})
+	}
+	return watches
+}